@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"net/netip"
+	"strconv"
+
+	"github.com/kaumnen/cipr/internal/cidr"
+	"github.com/kaumnen/cipr/internal/provider"
+)
+
+// collapsedKey is the attribute holding how many input prefixes an
+// aggregated record replaced.
+const collapsedKey = "collapsed"
+
+// aggregateRecords summarizes records into the minimal covering set per
+// provider and address family. Aggregates span several input records, so
+// only the prefix, provider and family survive; withCounts additionally
+// records the number of collapsed inputs under collapsedKey.
+func aggregateRecords(records []provider.Record, withCounts bool) []provider.Record {
+	var order []string
+	byProvider := make(map[string][]netip.Prefix)
+	for _, r := range records {
+		if _, seen := byProvider[r.Provider]; !seen {
+			order = append(order, r.Provider)
+		}
+		byProvider[r.Provider] = append(byProvider[r.Provider], r.Prefix)
+	}
+
+	out := make([]provider.Record, 0, len(records))
+	for _, name := range order {
+		for _, agg := range cidr.Summarize(byProvider[name]) {
+			rec := provider.Record{Prefix: agg.Prefix, Provider: name, Family: provider.FamilyOf(agg.Prefix)}
+			if withCounts {
+				rec.Attributes = map[string]string{collapsedKey: strconv.Itoa(agg.Count)}
+			}
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// aggregateColumns are the default columns of aggregated output.
+func aggregateColumns(withCounts bool) []provider.Column {
+	columns := []provider.Column{{Key: provider.PrefixKey, Label: "IP Prefix"}}
+	if withCounts {
+		columns = append(columns, provider.Column{Key: collapsedKey, Label: "Collapsed"})
+	}
+	return columns
+}
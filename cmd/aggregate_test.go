@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateRecords(t *testing.T) {
+	records := []provider.Record{
+		{Prefix: netip.MustParsePrefix("192.30.252.0/23"), Provider: "github", Family: provider.IPv4, Service: "hooks"},
+		{Prefix: netip.MustParsePrefix("192.30.254.0/23"), Provider: "github", Family: provider.IPv4, Service: "web"},
+		{Prefix: netip.MustParsePrefix("192.30.252.0/22"), Provider: "github", Family: provider.IPv4, Service: "api"},
+		{Prefix: netip.MustParsePrefix("2a0a:a440::/29"), Provider: "github", Family: provider.IPv6, Service: "web"},
+		{Prefix: netip.MustParsePrefix("192.30.252.0/22"), Provider: "other", Family: provider.IPv4},
+	}
+
+	got := aggregateRecords(records, false)
+	assert.Equal(t, []provider.Record{
+		{Prefix: netip.MustParsePrefix("192.30.252.0/22"), Provider: "github", Family: provider.IPv4},
+		{Prefix: netip.MustParsePrefix("2a0a:a440::/29"), Provider: "github", Family: provider.IPv6},
+		{Prefix: netip.MustParsePrefix("192.30.252.0/22"), Provider: "other", Family: provider.IPv4},
+	}, got)
+
+	got = aggregateRecords(records, true)
+	assert.Equal(t, "3", got[0].Get(collapsedKey))
+	assert.Equal(t, "1", got[1].Get(collapsedKey))
+}
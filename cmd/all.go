@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// allFilterKeys are the record fields every provider can be filtered on by
+// `cipr all`. Providers that don't populate a field never match a filter on
+// it.
+var allFilterKeys = []string{provider.RegionKey, provider.ServiceKey, provider.CountryKey}
+
+var allCmd = &cobra.Command{
+	Use:   "all",
+	Short: "Get IP ranges of every provider in one combined result",
+	Long: `Fetch the ranges of every provider (or those chosen with --provider) in
+parallel and print them as one result, each range tagged with its provider.
+
+Region, service and country filters are applied to every provider; a
+provider that doesn't publish a field (for example, country for AWS)
+contributes no ranges when that field is filtered. A provider that fails to
+load is reported on stderr and the others are still printed, unless
+--fail-fast is given.`,
+	Args: cobra.NoArgs,
+	RunE: runAll,
+}
+
+func init() {
+	rootCmd.AddCommand(allCmd)
+	allCmd.Flags().StringSlice("provider", []string{}, "Only fetch these providers (comma-separated; default all)")
+	allCmd.Flags().Bool("ipv4", false, "Get only IPv4 ranges")
+	allCmd.Flags().Bool("ipv6", false, "Get only IPv6 ranges")
+	for _, key := range allFilterKeys {
+		flag := "filter-" + key
+		allCmd.Flags().StringSlice(flag, []string{}, fmt.Sprintf("Filter results by %s (comma-separated)", key))
+		viper.BindPFlag("all-"+flag, allCmd.Flags().Lookup(flag))
+	}
+	allCmd.Flags().Int("concurrency", defaultLoadConcurrency, "Maximum number of providers fetched at once")
+	allCmd.Flags().Bool("fail-fast", false, "Stop at the first provider that fails to load")
+	viper.BindPFlag("all-provider", allCmd.Flags().Lookup("provider"))
+	viper.BindPFlag("all_ipv4", allCmd.Flags().Lookup("ipv4"))
+	viper.BindPFlag("all_ipv6", allCmd.Flags().Lookup("ipv6"))
+	viper.BindPFlag("all-concurrency", allCmd.Flags().Lookup("concurrency"))
+	viper.BindPFlag("all-fail-fast", allCmd.Flags().Lookup("fail-fast"))
+}
+
+// allColumns are the default columns of the combined result.
+var allColumns = []provider.Column{
+	{Key: provider.PrefixKey, Label: "Prefix"},
+	{Key: provider.ProviderKey, Label: "Provider"},
+	{Key: provider.RegionKey, Label: "Region"},
+	{Key: provider.ServiceKey, Label: "Service"},
+	{Key: provider.CountryKey, Label: "Country"},
+}
+
+func runAll(cmd *cobra.Command, args []string) error {
+	opts, err := resolveOutputOptions(cmd)
+	if err != nil {
+		return err
+	}
+	providers, err := selectProviders(viper.GetStringSlice("all-provider"))
+	if err != nil {
+		return err
+	}
+	source := viper.GetString("source")
+	if !utils.UsesConfiguredSources(source) {
+		return errors.New("all reads the configured sources; use a provider command for --source")
+	}
+	concurrency := viper.GetInt("all-concurrency")
+	if concurrency < 1 {
+		return fmt.Errorf("invalid --concurrency %d (must be at least 1)", concurrency)
+	}
+
+	filters := make(map[string][]string)
+	for _, key := range allFilterKeys {
+		if values := viper.GetStringSlice("all-filter-" + key); len(values) > 0 {
+			filters[key] = values
+		}
+	}
+
+	defaults := allColumns
+	if opts.aggregate {
+		defaults = aggregateColumns(opts.aggregateCounts)
+	}
+	columns, err := selectColumns("all", defaults, viper.GetStringSlice("columns"))
+	if err != nil {
+		return err
+	}
+
+	query := provider.Query{
+		Source: source,
+		IPType: resolveIPType(viper.GetBool("all_ipv4"), viper.GetBool("all_ipv6")),
+	}
+	failFast := viper.GetBool("all-fail-fast")
+	results, errs := loadProviders(cmd.Context(), providers, query, loadOptions{concurrency: concurrency, failFast: failFast})
+	if failFast {
+		if err := firstLoadError(errs); err != nil {
+			return err
+		}
+	}
+
+	combined, failed := mergeResults(providers, results, errs, filters)
+	for _, err := range errs {
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: skipping %v\n", err)
+		}
+	}
+	if len(failed) == len(providers) {
+		return fmt.Errorf("load ranges: every provider failed (%s)", strings.Join(failed, ", "))
+	}
+	if opts.aggregate {
+		combined.Records = aggregateRecords(combined.Records, opts.aggregateCounts)
+	}
+	return writeResult(cmd.OutOrStdout(), "all", columns, combined, opts)
+}
+
+// firstLoadError returns the first error that was not caused by fail-fast
+// cancellation of another provider.
+func firstLoadError(errs []error) error {
+	var canceled error
+	for _, err := range errs {
+		switch {
+		case err == nil:
+		case errors.Is(err, errLoadCanceled):
+			if canceled == nil {
+				canceled = err
+			}
+		default:
+			return err
+		}
+	}
+	return canceled
+}
+
+// mergeResults concatenates the records of the providers that loaded, in
+// provider order, keeping those matching filters. Provider metadata is kept
+// under "<provider>.<key>", and the providers that failed are listed under
+// "failed".
+func mergeResults(providers []provider.Provider, results []provider.Result, errs []error, filters map[string][]string) (provider.Result, []string) {
+	combined := provider.Result{Metadata: make(map[string]string)}
+	var failed []string
+	for i, p := range providers {
+		if errs[i] != nil {
+			failed = append(failed, p.Name())
+			continue
+		}
+		for _, r := range results[i].Records {
+			if r.Matches(filters) {
+				combined.Records = append(combined.Records, r)
+			}
+		}
+		for key, value := range results[i].Metadata {
+			combined.Metadata[p.Name()+"."+key] = value
+		}
+	}
+	if len(failed) > 0 {
+		combined.Metadata["failed"] = strings.Join(failed, ",")
+	}
+	return combined, failed
+}
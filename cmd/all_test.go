@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeResults(t *testing.T) {
+	providers := []provider.Provider{
+		stubProvider{name: "aws"},
+		stubProvider{name: "gcp"},
+		stubProvider{name: "do"},
+	}
+	aws := stubRecords("aws", "192.0.2.0/24", "2001:db8::/32")
+	aws.Records[0].Region = "eu-west-1"
+	do := stubRecords("do", "198.51.100.0/24")
+	do.Records[0].Region = "EU-WEST-1"
+	results := []provider.Result{aws, {}, do}
+	errs := []error{nil, errors.New("gcp: boom"), nil}
+
+	combined, failed := mergeResults(providers, results, errs, map[string][]string{"region": {"eu-west-1"}})
+	assert.Equal(t, []string{"gcp"}, failed)
+	require.Len(t, combined.Records, 2)
+	assert.Equal(t, "aws", combined.Records[0].Provider)
+	assert.Equal(t, "do", combined.Records[1].Provider)
+	assert.Equal(t, map[string]string{
+		"aws.sync_token": "aws-1",
+		"do.sync_token":  "do-1",
+		"failed":         "gcp",
+	}, combined.Metadata)
+}
+
+func TestRunAll(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+	dir := t.TempDir()
+	path := filepath.Join(dir, "aws.json")
+	require.NoError(t, os.WriteFile(path, []byte(awsFixture), 0o600))
+	viper.Set("source", "config")
+	viper.Set("output", "text")
+	viper.Set("verbose_mode", "mini")
+	viper.Set("aws_local_file", path)
+	viper.Set("github_local_file", filepath.Join(dir, "missing.json"))
+	viper.Set("all-provider", []string{"aws", "github"})
+	viper.Set("all-concurrency", 2)
+	viper.Set("all-filter-service", []string{"ec2"})
+	viper.Set("all_ipv4", true)
+
+	var out, errOut bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	cmd.SetErr(&errOut)
+	cmd.SetContext(context.Background())
+	require.NoError(t, runAll(cmd, nil))
+	assert.Equal(t, "3.5.140.0/22,aws,us-east-1,EC2,\n", out.String())
+	assert.Contains(t, errOut.String(), "Warning: skipping github: read")
+
+	viper.Set("all-fail-fast", true)
+	err := runAll(cmd, nil)
+	assert.ErrorContains(t, err, "github: read")
+
+	viper.Set("all-fail-fast", false)
+	viper.Set("all-provider", []string{"github"})
+	err = runAll(cmd, nil)
+	assert.ErrorContains(t, err, "every provider failed (github)")
+}
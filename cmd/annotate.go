@@ -0,0 +1,317 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/kaumnen/cipr/internal/cidr"
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// maxAnnotateLine bounds the memory used per input line.
+const maxAnnotateLine = 4 << 20
+
+var annotateCmd = &cobra.Command{
+	Use:   "annotate [file...]",
+	Short: "Tag IP addresses in log lines with their provider",
+	Long: `Stream log lines from the given files (or stdin when none, or "-", is
+given), find an IP address in each line, and tag the line with the provider,
+region and service of the most specific matching range.
+
+By default the first IPv4 or IPv6 address in the line is used. --field picks
+a whitespace-separated field (1-based, like awk) and --regex a capture group
+of a regular expression instead. In text output the tags are appended to the
+line after a tab; lines without a match are passed through unchanged. With
+--output ndjson every line becomes one JSON object. Lines longer than 4 MiB
+are passed through unannotated in text output and skipped in ndjson output.`,
+	RunE: runAnnotate,
+}
+
+func init() {
+	rootCmd.AddCommand(annotateCmd)
+	annotateCmd.Flags().StringSlice("provider", []string{}, "Only match against these providers (comma-separated; default all)")
+	annotateCmd.Flags().Int("field", 0, "Take the address from this whitespace-separated field (1-based)")
+	annotateCmd.Flags().String("regex", "", "Take the address from a capture group of this regular expression")
+	annotateCmd.Flags().Int("group", 1, "Capture group of --regex holding the address (0 is the whole match)")
+	viper.BindPFlag("annotate-provider", annotateCmd.Flags().Lookup("provider"))
+	viper.BindPFlag("annotate-field", annotateCmd.Flags().Lookup("field"))
+	viper.BindPFlag("annotate-regex", annotateCmd.Flags().Lookup("regex"))
+	viper.BindPFlag("annotate-group", annotateCmd.Flags().Lookup("group"))
+}
+
+// annotatedLine is the --output ndjson shape of one input line.
+type annotatedLine struct {
+	Line    string       `json:"line"`
+	IP      string       `json:"ip,omitempty"`
+	Matches []jsonRecord `json:"matches"`
+}
+
+func runAnnotate(cmd *cobra.Command, args []string) error {
+	format, err := resolveOutputFormat()
+	if err != nil {
+		return err
+	}
+	if format != "text" && format != "ndjson" {
+		return fmt.Errorf("annotate streams its output; use --output text or ndjson, not %s", format)
+	}
+	extract, err := newAddrExtractor(viper.GetInt("annotate-field"), viper.GetString("annotate-regex"), viper.GetInt("annotate-group"))
+	if err != nil {
+		return err
+	}
+	providers, err := selectProviders(viper.GetStringSlice("annotate-provider"))
+	if err != nil {
+		return err
+	}
+	source := viper.GetString("source")
+	if !utils.UsesConfiguredSources(source) && len(providers) != 1 {
+		return errors.New("--source other than config requires exactly one --provider")
+	}
+	trie, err := loadRecordTrie(cmd, providers, source)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(cmd.OutOrStdout())
+	a := annotator{trie: trie, extract: extract, json: format == "ndjson", out: bw, errOut: cmd.ErrOrStderr()}
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	for _, name := range args {
+		if err := annotateFile(cmd.InOrStdin(), name, a); err != nil {
+			return err
+		}
+	}
+	return flushOutput(bw)
+}
+
+func annotateFile(stdin io.Reader, name string, a annotator) error {
+	if name == "-" {
+		return a.annotate(stdin, "stdin")
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("open input: %w", err)
+	}
+	defer f.Close()
+	return a.annotate(f, name)
+}
+
+type annotator struct {
+	trie    *cidr.Trie[provider.Record]
+	extract func(line string) (netip.Addr, bool)
+	json    bool
+	out     *bufio.Writer
+	errOut  io.Writer
+}
+
+// annotate streams in line by line; at most maxAnnotateLine bytes of the
+// current line are held in memory. Longer lines are passed through
+// unannotated in text output and skipped in ndjson output, with a warning
+// either way.
+func (a annotator) annotate(in io.Reader, name string) error {
+	r := bufio.NewReaderSize(in, 64<<10)
+	var line []byte
+	for n := 1; ; n++ {
+		line = line[:0]
+		overlong := false
+		chunk, err := r.ReadSlice('\n')
+		for {
+			if !overlong && len(line)+len(chunk) > maxAnnotateLine {
+				overlong = true
+				if err := a.passThrough(line); err != nil {
+					return err
+				}
+			}
+			if overlong {
+				if err := a.passThrough(chunk); err != nil {
+					return err
+				}
+			} else {
+				line = append(line, chunk...)
+			}
+			if !errors.Is(err, bufio.ErrBufferFull) {
+				break
+			}
+			chunk, err = r.ReadSlice('\n')
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		done := err != nil
+
+		switch {
+		case overlong:
+			if err := a.endOverlong(name, n, chunk); err != nil {
+				return err
+			}
+		case len(line) > 0 || !done:
+			line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+			if err := a.annotateLine(string(line)); err != nil {
+				return err
+			}
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// passThrough copies part of an overlong line to text output unchanged.
+func (a annotator) passThrough(b []byte) error {
+	if a.json {
+		return nil
+	}
+	if _, err := a.out.Write(b); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	return nil
+}
+
+// endOverlong finishes line n of name, which exceeded maxAnnotateLine; last
+// is its final chunk.
+func (a annotator) endOverlong(name string, n int, last []byte) error {
+	errOut := a.errOut
+	if errOut == nil {
+		errOut = os.Stderr
+	}
+	if a.json {
+		fmt.Fprintf(errOut, "Warning: skipping line %d of %s: longer than %d bytes\n", n, name, maxAnnotateLine)
+		return nil
+	}
+	fmt.Fprintf(errOut, "Warning: line %d of %s is longer than %d bytes; passed through unannotated\n", n, name, maxAnnotateLine)
+	if !bytes.HasSuffix(last, []byte("\n")) {
+		if err := a.out.WriteByte('\n'); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	}
+	return nil
+}
+
+func (a annotator) annotateLine(line string) error {
+	var records []provider.Record
+	addr, found := a.extract(line)
+	if found {
+		_, records, _ = a.trie.Lookup(addr)
+	}
+
+	if a.json {
+		entry := annotatedLine{Line: line, Matches: make([]jsonRecord, 0, len(records))}
+		if found {
+			entry.IP = addr.String()
+		}
+		for _, r := range records {
+			entry.Matches = append(entry.Matches, newJSONRecord(r))
+		}
+		if err := json.NewEncoder(a.out).Encode(entry); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := a.out.WriteString(line); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	if len(records) > 0 {
+		tags := make([]string, 0, len(records))
+		for _, r := range records {
+			rec := newJSONRecord(r)
+			tags = append(tags, "provider="+rec.Provider+" prefix="+rec.Prefix+" "+describeAttributes(rec))
+		}
+		if _, err := a.out.WriteString("\t" + strings.Join(tags, " | ")); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	}
+	if err := a.out.WriteByte('\n'); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	return nil
+}
+
+// newAddrExtractor returns the function finding the address in a line:
+// from a field, a regex capture group, or the first address-looking token.
+func newAddrExtractor(field int, expr string, group int) (func(string) (netip.Addr, bool), error) {
+	switch {
+	case field != 0 && expr != "":
+		return nil, errors.New("--field and --regex are mutually exclusive")
+	case field < 0:
+		return nil, fmt.Errorf("invalid --field %d (fields start at 1)", field)
+	case field > 0:
+		return func(line string) (netip.Addr, bool) {
+			fields := strings.Fields(line)
+			if field > len(fields) {
+				return netip.Addr{}, false
+			}
+			return parseLogAddr(fields[field-1])
+		}, nil
+	case expr != "":
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --regex: %w", err)
+		}
+		if group < 0 || group > re.NumSubexp() {
+			return nil, fmt.Errorf("invalid --group %d (the expression has %d groups)", group, re.NumSubexp())
+		}
+		return func(line string) (netip.Addr, bool) {
+			m := re.FindStringSubmatchIndex(line)
+			if m == nil || m[2*group] < 0 {
+				return netip.Addr{}, false
+			}
+			return parseLogAddr(line[m[2*group]:m[2*group+1]])
+		}, nil
+	}
+	return firstAddr, nil
+}
+
+// firstAddr returns the first token of line that parses as an IP address,
+// optionally with a port. Tokens are runs of hex digits, dots and colons.
+func firstAddr(line string) (netip.Addr, bool) {
+	isAddrChar := func(r rune) bool {
+		return r == '.' || r == ':' || r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F'
+	}
+	for line != "" {
+		start := strings.IndexFunc(line, isAddrChar)
+		if start < 0 {
+			return netip.Addr{}, false
+		}
+		line = line[start:]
+		end := strings.IndexFunc(line, func(r rune) bool { return !isAddrChar(r) })
+		if end < 0 {
+			end = len(line)
+		}
+		// "::" is a valid address but far more likely a separator.
+		if addr, ok := parseLogAddr(line[:end]); ok && !addr.IsUnspecified() {
+			return addr, true
+		}
+		line = line[end:]
+	}
+	return netip.Addr{}, false
+}
+
+// parseLogAddr parses an address as it appears in logs: bare, with a port
+// ("192.0.2.1:443", "[2001:db8::1]:443"), or wrapped in quotes or brackets.
+func parseLogAddr(s string) (netip.Addr, bool) {
+	s = strings.Trim(s, `"'[](),;`)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), true
+	}
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+	if i := strings.LastIndex(s, "]:"); i > 0 {
+		if addr, err := netip.ParseAddr(strings.TrimPrefix(s[:i], "[")); err == nil {
+			return addr.Unmap(), true
+		}
+	}
+	return netip.Addr{}, false
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/kaumnen/cipr/internal/cidr"
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFirstAddr(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: `3.5.140.10 - - [18/Oct/2026:10:15:32 +0000] "GET / HTTP/1.1" 200`, want: "3.5.140.10"},
+		{line: "2026-10-18T10:15:33Z client=[2600:1f18::5]:443 status=200", want: "2600:1f18::5"},
+		{line: "ts=12:00:01 src=192.0.2.7:51234 dst=198.51.100.1", want: "192.0.2.7"},
+		{line: "mapped ::ffff:192.0.2.9 here", want: "192.0.2.9"},
+		{line: "cafe :: beef 203.0.113.4", want: "203.0.113.4"},
+		{line: "no address here"},
+		{line: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			addr, ok := firstAddr(tt.line)
+			if tt.want == "" {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, tt.want, addr.String())
+		})
+	}
+}
+
+func TestNewAddrExtractor(t *testing.T) {
+	line := `10.0.0.1 - - [18/Oct/2026:10:15:34 +0000] "GET / HTTP/1.1" 200 612 "4.148.0.1" "curl/8.0"`
+
+	byField, err := newAddrExtractor(11, "", 1)
+	require.NoError(t, err)
+	addr, ok := byField(line)
+	require.True(t, ok)
+	assert.Equal(t, "4.148.0.1", addr.String())
+	_, ok = byField("too short")
+	assert.False(t, ok)
+
+	byRegex, err := newAddrExtractor(0, `"(?P<xff>[^"]+)" "curl`, 1)
+	require.NoError(t, err)
+	addr, ok = byRegex(line)
+	require.True(t, ok)
+	assert.Equal(t, "4.148.0.1", addr.String())
+
+	_, err = newAddrExtractor(2, "x", 1)
+	assert.ErrorContains(t, err, "mutually exclusive")
+	_, err = newAddrExtractor(-1, "", 1)
+	assert.ErrorContains(t, err, "invalid --field")
+	_, err = newAddrExtractor(0, "(", 1)
+	assert.ErrorContains(t, err, "invalid --regex")
+	_, err = newAddrExtractor(0, "a(b)", 2)
+	assert.ErrorContains(t, err, "invalid --group 2")
+}
+
+func TestAnnotate(t *testing.T) {
+	var trie cidr.Trie[provider.Record]
+	for _, r := range []provider.Record{
+		{Prefix: netip.MustParsePrefix("3.0.0.0/8"), Provider: "aws", Family: provider.IPv4, Service: "AMAZON"},
+		{Prefix: netip.MustParsePrefix("3.5.140.0/22"), Provider: "aws", Family: provider.IPv4, Region: "us-east-1", Service: "EC2"},
+	} {
+		trie.Insert(r.Prefix, r)
+	}
+	input := "3.5.140.10 GET /\n192.0.2.1 GET /\nno address\n"
+
+	var out bytes.Buffer
+	bw := bufio.NewWriter(&out)
+	a := annotator{trie: &trie, extract: firstAddr, out: bw}
+	require.NoError(t, a.annotate(strings.NewReader(input), "stdin"))
+	require.NoError(t, bw.Flush())
+	assert.Equal(t,
+		"3.5.140.10 GET /\tprovider=aws prefix=3.5.140.0/22 region=us-east-1 service=EC2\n"+
+			"192.0.2.1 GET /\n"+
+			"no address\n",
+		out.String())
+
+	out.Reset()
+	a.json = true
+	require.NoError(t, a.annotate(strings.NewReader(input), "stdin"))
+	require.NoError(t, bw.Flush())
+	assert.Equal(t,
+		`{"line":"3.5.140.10 GET /","ip":"3.5.140.10","matches":[{"prefix":"3.5.140.0/22","provider":"aws","family":"ipv4","region":"us-east-1","service":"EC2"}]}`+"\n"+
+			`{"line":"192.0.2.1 GET /","ip":"192.0.2.1","matches":[]}`+"\n"+
+			`{"line":"no address","matches":[]}`+"\n",
+		out.String())
+}
+
+func TestAnnotateOverlongLines(t *testing.T) {
+	var trie cidr.Trie[provider.Record]
+	rec := provider.Record{Prefix: netip.MustParsePrefix("3.5.140.0/22"), Provider: "aws", Family: provider.IPv4, Service: "EC2"}
+	trie.Insert(rec.Prefix, rec)
+	long := "3.5.140.1 " + strings.Repeat("x", maxAnnotateLine)
+	input := long + "\n3.5.140.10 GET /\n" + long
+
+	var out, errOut bytes.Buffer
+	bw := bufio.NewWriter(&out)
+	a := annotator{trie: &trie, extract: firstAddr, out: bw, errOut: &errOut}
+	require.NoError(t, a.annotate(strings.NewReader(input), "big.log"))
+	require.NoError(t, bw.Flush())
+	assert.Equal(t, long+"\n3.5.140.10 GET /\tprovider=aws prefix=3.5.140.0/22 service=EC2\n"+long+"\n", out.String(),
+		"overlong lines pass through unannotated and the stream goes on")
+	assert.Equal(t,
+		"Warning: line 1 of big.log is longer than 4194304 bytes; passed through unannotated\n"+
+			"Warning: line 3 of big.log is longer than 4194304 bytes; passed through unannotated\n",
+		errOut.String())
+
+	out.Reset()
+	errOut.Reset()
+	a.json = true
+	require.NoError(t, a.annotate(strings.NewReader(input), "big.log"))
+	require.NoError(t, bw.Flush())
+	assert.Equal(t, `{"line":"3.5.140.10 GET /","ip":"3.5.140.10","matches":[{"prefix":"3.5.140.0/22","provider":"aws","family":"ipv4","service":"EC2"}]}`+"\n", out.String())
+	assert.Contains(t, errOut.String(), "Warning: skipping line 1 of big.log")
+	assert.Contains(t, errOut.String(), "Warning: skipping line 3 of big.log")
+}
+
+func TestAnnotateLineEndings(t *testing.T) {
+	var trie cidr.Trie[provider.Record]
+	var out bytes.Buffer
+	bw := bufio.NewWriter(&out)
+	a := annotator{trie: &trie, extract: firstAddr, out: bw}
+	require.NoError(t, a.annotate(strings.NewReader("a\r\n\nb"), "stdin"))
+	require.NoError(t, bw.Flush())
+	assert.Equal(t, "a\n\nb\n", out.String())
+}
@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect, clear and refresh the response cache",
+	Long: `Manage the cached provider responses. Entries are keyed by the source keys
+used in cipr.toml (aws, cloudflare_ipv4, ...); commands that take keys also
+accept provider names.`,
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cache entries with their size, age, TTL and source URL",
+	Args:  cobra.NoArgs,
+	RunE:  runCacheList,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear [key...]",
+	Short: "Delete cache entries (all of them when no key is given)",
+	RunE:  runCacheClear,
+}
+
+var cacheRefreshCmd = &cobra.Command{
+	Use:   "refresh [key...]",
+	Short: "Fetch providers and rewrite their cache entries regardless of age",
+	RunE:  runCacheRefresh,
+}
+
+var cachePathCmd = &cobra.Command{
+	Use:   "path [key]",
+	Short: "Print the cache directory, or the file of one entry",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runCachePath,
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheListCmd, cacheClearCmd, cacheRefreshCmd, cachePathCmd)
+	cacheRefreshCmd.Flags().Int("concurrency", 1, "Number of providers refreshed at once")
+	viper.BindPFlag("cache-refresh-concurrency", cacheRefreshCmd.Flags().Lookup("concurrency"))
+}
+
+// jsonCacheEntry is the --output json shape of a cache entry.
+type jsonCacheEntry struct {
+	Key        string `json:"key"`
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Age        string `json:"age"`
+	TTL        string `json:"ttl"`
+	Fresh      bool   `json:"fresh"`
+	Corrupt    bool   `json:"corrupt,omitempty"`
+	Unverified bool   `json:"unverified,omitempty"`
+	URL        string `json:"url,omitempty"`
+	SyncToken  string `json:"sync_token,omitempty"`
+}
+
+func runCacheList(cmd *cobra.Command, args []string) error {
+	format, err := resolveOutputFormat()
+	if err != nil {
+		return err
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("cache list supports --output text and json, not %s", format)
+	}
+	entries, err := utils.ListCache()
+	if err != nil {
+		return err
+	}
+	return writeCacheEntries(cmd.OutOrStdout(), entries, format)
+}
+
+func writeCacheEntries(w io.Writer, entries []utils.CacheEntry, format string) error {
+	if format == "json" {
+		out := make([]jsonCacheEntry, 0, len(entries))
+		for _, e := range entries {
+			out = append(out, jsonCacheEntry{
+				Key:        e.Key,
+				Path:       e.Path,
+				Size:       e.Size,
+				Age:        e.Age.String(),
+				TTL:        e.TTL.String(),
+				Fresh:      e.Fresh,
+				Corrupt:    e.Corrupt,
+				Unverified: e.Unverified,
+				URL:        e.URL,
+				SyncToken:  e.SyncToken,
+			})
+		}
+		return encodeJSON(w, out)
+	}
+
+	if len(entries) == 0 {
+		if _, err := fmt.Fprintln(w, "No cache entries."); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tSIZE\tAGE\tTTL\tSTATE\tURL")
+	for _, e := range entries {
+		state := "stale"
+		switch {
+		case e.Corrupt:
+			state = "corrupt"
+		case e.Unverified:
+			state = "unverified"
+		case e.Fresh:
+			state = "fresh"
+		}
+		url := e.URL
+		if url == "" {
+			url = "-"
+		} else {
+			url = utils.SanitizeURL(url)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", e.Key, e.Size, e.Age, e.TTL, state, url)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	return nil
+}
+
+func runCacheClear(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+	if len(args) == 0 {
+		n, err := utils.ClearAllCache()
+		if err != nil {
+			return err
+		}
+		dir, _ := utils.CacheDir()
+		fmt.Fprintf(out, "Removed %d cache file(s) from %s\n", n, dir)
+		return nil
+	}
+
+	keys, err := cacheKeys(args)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		removed, err := utils.ClearCache(key)
+		if err != nil {
+			return err
+		}
+		if removed {
+			fmt.Fprintf(out, "Removed cache for %s\n", key)
+		} else {
+			fmt.Fprintf(out, "No cache entry for %s\n", key)
+		}
+	}
+	return nil
+}
+
+func runCacheRefresh(cmd *cobra.Command, args []string) error {
+	if utils.Offline() {
+		return errors.New("cache refresh needs the network; drop --offline")
+	}
+	if viper.GetBool("no_cache") {
+		return errors.New("cache refresh writes the cache; drop --no-cache")
+	}
+	concurrency := viper.GetInt("cache-refresh-concurrency")
+	if concurrency < 1 {
+		return fmt.Errorf("invalid --concurrency %d (must be at least 1)", concurrency)
+	}
+
+	var providers []provider.Provider
+	if len(args) == 0 {
+		providers = provider.All()
+	} else {
+		keys, err := cacheKeys(args)
+		if err != nil {
+			return err
+		}
+		providers = refreshTargets(keys)
+	}
+
+	var fetched []provider.Provider
+	for _, p := range providers {
+		if usesLocalFiles(p) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Skipping %s: it reads a local file, not the cache\n", p.Name())
+			continue
+		}
+		fetched = append(fetched, p)
+	}
+
+	ctx := utils.WithCacheRefresh(cmd.Context())
+	_, errs := loadProviders(ctx, fetched, provider.Query{Source: "config", IPType: "both"}, loadOptions{concurrency: concurrency})
+	var failed []string
+	for i, p := range fetched {
+		if errs[i] != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: refresh failed: %v\n", errs[i])
+			failed = append(failed, p.Name())
+			continue
+		}
+		for _, key := range p.ConfigKeys() {
+			if entry, ok := utils.StatCache(key); ok {
+				fmt.Fprintf(cmd.OutOrStdout(), "Refreshed %s (%d bytes)\n", key, entry.Size)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("refresh failed for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func runCachePath(cmd *cobra.Command, args []string) error {
+	dir, err := utils.CacheDir()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), dir)
+		return nil
+	}
+	keys, err := cacheKeys(args)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 1 {
+		return fmt.Errorf("%s has several cache entries; name one of %s", args[0], strings.Join(keys, ", "))
+	}
+	entry, ok := utils.StatCache(keys[0])
+	if !ok {
+		return fmt.Errorf("no cache entry for %s", keys[0])
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), entry.Path)
+	return nil
+}
+
+// cacheKeys resolves provider names and source keys to source keys, in
+// argument order without duplicates.
+func cacheKeys(args []string) ([]string, error) {
+	var keys []string
+	for _, arg := range args {
+		resolved, err := provider.ResolveKeys(arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range resolved {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// refreshTargets returns what loads keys, in registry order: a provider
+// whose keys are all named, or a feedProvider for each named feed of a
+// provider that is only partly named.
+func refreshTargets(keys []string) []provider.Provider {
+	var out []provider.Provider
+	for _, p := range provider.All() {
+		var named []string
+		for _, key := range p.ConfigKeys() {
+			if slices.Contains(keys, key) {
+				named = append(named, key)
+			}
+		}
+		if len(named) == len(p.ConfigKeys()) {
+			out = append(out, p)
+			continue
+		}
+		for _, key := range named {
+			out = append(out, feedProvider{Provider: p, key: key})
+		}
+	}
+	return out
+}
+
+// feedProvider loads one feed of a multi-feed provider by reading its config
+// key as the source, as --source cloudflare_ipv4 does, so refreshing that key
+// leaves the provider's other feeds alone.
+type feedProvider struct {
+	provider.Provider
+	key string
+}
+
+func (f feedProvider) Name() string         { return f.key }
+func (f feedProvider) ConfigKeys() []string { return []string{f.key} }
+
+func (f feedProvider) Load(ctx context.Context, q provider.Query) (provider.Result, error) {
+	q.Source = f.key
+	return f.Provider.Load(ctx, q)
+}
+
+// usesLocalFiles reports whether every feed of p is read from a configured
+// local file, so loading it never touches the cache.
+func usesLocalFiles(p provider.Provider) bool {
+	for _, key := range p.ConfigKeys() {
+		if viper.GetString(key+"_local_file") == "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kaumnen/cipr/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCacheEntries(t *testing.T) {
+	entries := []utils.CacheEntry{
+		{Key: "aws", Path: "/c/aws.cache", Size: 1024, Age: 2 * time.Hour, TTL: 24 * time.Hour, Fresh: true, URL: "https://ip-ranges.amazonaws.com/ip-ranges.json"},
+		{Key: "cloudflare_ipv4", Path: "/c/cloudflare_ipv4.cache", Size: 10, Age: 48 * time.Hour, TTL: 24 * time.Hour},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeCacheEntries(&buf, entries, "text"))
+	assert.Equal(t,
+		"KEY              SIZE  AGE      TTL      STATE  URL\n"+
+			"aws              1024  2h0m0s   24h0m0s  fresh  https://ip-ranges.amazonaws.com/ip-ranges.json\n"+
+			"cloudflare_ipv4  10    48h0m0s  24h0m0s  stale  -\n",
+		buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeCacheEntries(&buf, nil, "text"))
+	assert.Equal(t, "No cache entries.\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeCacheEntries(&buf, entries[1:], "json"))
+	assert.Contains(t, buf.String(), `"fresh": false`)
+	assert.NotContains(t, buf.String(), `"url"`)
+}
+
+func TestCacheKeys(t *testing.T) {
+	keys, err := cacheKeys([]string{"cloudflare", "aws", "cloudflare_ipv6"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cloudflare_ipv4", "cloudflare_ipv6", "aws"}, keys)
+
+	providers := refreshTargets([]string{"cloudflare_ipv6", "aws"})
+	require.Len(t, providers, 2)
+	assert.Equal(t, "aws", providers[0].Name())
+	assert.Equal(t, "cloudflare_ipv6", providers[1].Name())
+	assert.Equal(t, []string{"cloudflare_ipv6"}, providers[1].ConfigKeys())
+
+	providers = refreshTargets([]string{"cloudflare_ipv4", "cloudflare_ipv6"})
+	require.Len(t, providers, 1)
+	assert.Equal(t, "cloudflare", providers[0].Name())
+
+	_, err = cacheKeys([]string{"oracle"})
+	assert.ErrorContains(t, err, `unknown provider "oracle"`)
+}
+
+func TestRunCacheClear(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	require.NoError(t, utils.WriteCacheFile("aws.cache", []byte("aws")))
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	require.NoError(t, runCacheClear(cmd, []string{"aws", "gcp"}))
+	assert.Equal(t, "Removed cache for aws\nNo cache entry for gcp\n", out.String())
+}
+
+func TestRunCacheRefreshRejectsOffline(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("offline", true)
+	err := runCacheRefresh(&cobra.Command{}, nil)
+	assert.ErrorContains(t, err, "drop --offline")
+}
+
+func TestRunCacheRefreshSingleFeed(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+
+	hits := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits[r.URL.Path]++
+		if r.URL.Path == "/ips-v4" {
+			_, _ = w.Write([]byte("173.245.48.0/20\n"))
+			return
+		}
+		_, _ = w.Write([]byte("2400:cb00::/32\n"))
+	}))
+	defer srv.Close()
+	viper.Set("cloudflare_ipv4_endpoint", srv.URL+"/ips-v4")
+	viper.Set("cloudflare_ipv6_endpoint", srv.URL+"/ips-v6")
+	viper.Set("cache-refresh-concurrency", 1)
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	cmd.SetOut(&out)
+	require.NoError(t, runCacheRefresh(cmd, []string{"cloudflare_ipv4"}))
+	assert.Equal(t, map[string]int{"/ips-v4": 1}, hits)
+	assert.Equal(t, "Refreshed cloudflare_ipv4 (16 bytes)\n", out.String())
+	_, cached := utils.StatCache("cloudflare_ipv6")
+	assert.False(t, cached)
+}
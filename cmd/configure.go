@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/kaumnen/cipr/internal/provider"
 	"github.com/kaumnen/cipr/internal/utils"
 	"github.com/pelletier/go-toml/v2"
 	"github.com/spf13/cobra"
@@ -20,16 +21,17 @@ const defaultConfiguredCacheTTL = "24h"
 var configureCmd = &cobra.Command{
 	Use:   "configure [source-key]",
 	Short: "Show or update cipr configuration",
-	Long: `Show or update cipr's managed configuration values.
-
-Source-specific settings use the keys written to cipr.toml, including aws,
-azure, cloudflare_ipv4, cloudflare_ipv6, digitalocean, gcp, github, and icloud.
-With no update flags, the effective managed configuration is displayed.`,
-	Args: cobra.MaximumNArgs(1),
-	RunE: runConfigure,
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runConfigure,
 }
 
 func init() {
+	configureCmd.Long = fmt.Sprintf(`Show or update cipr's managed configuration values.
+
+Source-specific settings use the keys written to cipr.toml: %s.
+With a source key, --proxy sets that source's <key>_proxy override ("direct"
+bypasses any proxy); without one it sets the global proxy.
+With no update flags, the effective managed configuration is displayed.`, strings.Join(configuredSourceKeys(), ", "))
 	rootCmd.AddCommand(configureCmd)
 	configureCmd.Flags().String("endpoint", "", "HTTP(S) endpoint for the selected source (empty resets to the default)")
 	configureCmd.Flags().String("local-file", "", "Local data file for the selected source (empty clears the override)")
@@ -40,7 +42,7 @@ func runConfigure(cmd *cobra.Command, args []string) error {
 	var source string
 	if len(args) == 1 {
 		source = args[0]
-		if _, ok := utils.DefaultEndpoints[source]; !ok {
+		if _, ok := provider.DefaultEndpoint(source); !ok {
 			return fmt.Errorf("unknown source key %q (valid: %s)", source, strings.Join(configuredSourceKeys(), ", "))
 		}
 	}
@@ -50,6 +52,7 @@ func runConfigure(cmd *cobra.Command, args []string) error {
 	cacheTTLChanged := cmd.Flags().Changed("cache-ttl")
 	proxyChanged := cmd.Flags().Changed("proxy")
 	debugChanged := cmd.Flags().Changed("debug")
+	offlineChanged := cmd.Flags().Changed("offline")
 
 	if (endpointChanged || localFileChanged || cacheTTLChanged) && source == "" {
 		return fmt.Errorf("a source key is required with --endpoint, --local-file, or --cache-ttl")
@@ -65,7 +68,7 @@ func runConfigure(cmd *cobra.Command, args []string) error {
 			return err
 		}
 		if endpoint == "" {
-			endpoint = utils.DefaultEndpoints[source]
+			endpoint, _ = provider.DefaultEndpoint(source)
 		}
 		if err := utils.ValidateHTTPURL(endpoint); err != nil {
 			return err
@@ -102,7 +105,14 @@ func runConfigure(cmd *cobra.Command, args []string) error {
 		if err := utils.ValidateProxyURL(proxy); err != nil {
 			return err
 		}
-		updates["proxy"] = proxy
+		if source != "" {
+			updates[source+"_proxy"] = proxy
+			// The flag is bound to the global proxy; stop it from overriding
+			// the saved global value in the output below.
+			cmd.Flag("proxy").Changed = false
+		} else {
+			updates["proxy"] = proxy
+		}
 	}
 	if debugChanged {
 		debug, err := cmd.Flags().GetBool("debug")
@@ -111,6 +121,13 @@ func runConfigure(cmd *cobra.Command, args []string) error {
 		}
 		updates["debug"] = debug
 	}
+	if offlineChanged {
+		offline, err := cmd.Flags().GetBool("offline")
+		if err != nil {
+			return err
+		}
+		updates["offline"] = offline
+	}
 
 	if len(updates) > 0 {
 		configPath := viper.ConfigFileUsed()
@@ -132,12 +149,7 @@ func runConfigure(cmd *cobra.Command, args []string) error {
 }
 
 func configuredSourceKeys() []string {
-	keys := make([]string, 0, len(utils.DefaultEndpoints))
-	for key := range utils.DefaultEndpoints {
-		keys = append(keys, key)
-	}
-	sort.Strings(keys)
-	return keys
+	return provider.ConfigKeys()
 }
 
 func showEffectiveConfiguration(w io.Writer, selectedSource string) error {
@@ -156,6 +168,9 @@ func showEffectiveConfiguration(w io.Writer, selectedSource string) error {
 	if _, err := fmt.Fprintf(w, "debug = %t\n", viper.GetBool("debug")); err != nil {
 		return fmt.Errorf("write configuration output: %w", err)
 	}
+	if _, err := fmt.Fprintf(w, "offline = %t\n", viper.GetBool("offline")); err != nil {
+		return fmt.Errorf("write configuration output: %w", err)
+	}
 
 	keys := configuredSourceKeys()
 	if selectedSource != "" {
@@ -164,7 +179,7 @@ func showEffectiveConfiguration(w io.Writer, selectedSource string) error {
 	for _, source := range keys {
 		endpoint := viper.GetString(source + "_endpoint")
 		if endpoint == "" {
-			endpoint = utils.DefaultEndpoints[source]
+			endpoint, _ = provider.DefaultEndpoint(source)
 		}
 		localFile := viper.GetString(source + "_local_file")
 		cacheTTL := viper.GetString(source + "_cache_ttl")
@@ -190,10 +205,30 @@ func showEffectiveConfiguration(w io.Writer, selectedSource string) error {
 		if _, err := fmt.Fprintf(w, "%s_cache_ttl = %q\n", source, cacheTTL); err != nil {
 			return fmt.Errorf("write configuration output: %w", err)
 		}
+		if proxy := viper.GetString(source + "_proxy"); proxy != "" {
+			if _, err := fmt.Fprintf(w, "%s_proxy = %q\n", source, utils.SanitizeURL(proxy)); err != nil {
+				return fmt.Errorf("write configuration output: %w", err)
+			}
+		}
+		if names := utils.CustomHeaderNames(source); len(names) > 0 {
+			if _, err := fmt.Fprintf(w, "%s_headers = { %s }\n", source, redactedHeaderTable(names)); err != nil {
+				return fmt.Errorf("write configuration output: %w", err)
+			}
+		}
 	}
 	return nil
 }
 
+// redactedHeaderTable renders header names as the entries of a TOML inline
+// table whose values are redacted.
+func redactedHeaderTable(names []string) string {
+	entries := make([]string, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, fmt.Sprintf("%s = %q", name, utils.Redacted))
+	}
+	return strings.Join(entries, ", ")
+}
+
 func hasProxyEnvironment() bool {
 	for _, key := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
 		if os.Getenv(key) != "" {
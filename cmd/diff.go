@@ -0,0 +1,311 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/kaumnen/cipr/internal/cidr"
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// cacheSnapshot is the diff source naming the provider's current cache
+// entry, whatever its age.
+const cacheSnapshot = "cache"
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <provider> <old> <new>",
+	Short: "Compare two snapshots of a provider's IP ranges",
+	Long: `Compare two snapshots of a provider feed and print the prefixes that were
+added, removed, or changed attributes (region, service, ...).
+
+Each snapshot is a local file, an HTTP(S) URL, "cache" for the provider's
+current cache entry regardless of its age, or "config" for the configured
+source as the provider command would load it. To compare the cache against
+a fresh download, use: cipr diff aws cache config --no-cache
+
+The exit status is 0 when the snapshots match, 1 when they differ, and 2 on
+error, so CI jobs can gate on it.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(3)(cmd, args); err != nil {
+			return diffError(cmd, err)
+		}
+		return nil
+	},
+	RunE: runDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().BoolP("unified", "u", false, "Print a unified-diff-like listing (- removed, + added) instead of the summary layout")
+	viper.BindPFlag("diff-unified", diffCmd.Flags().Lookup("unified"))
+}
+
+// prefixChange is a prefix present in both snapshots whose records differ.
+type prefixChange struct {
+	Prefix netip.Prefix
+	Old    []provider.Record
+	New    []provider.Record
+}
+
+// rangeDiff is the difference between two snapshots, each list ordered by
+// prefix.
+type rangeDiff struct {
+	Added   []provider.Record
+	Removed []provider.Record
+	Changed []prefixChange
+}
+
+func (d rangeDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	format, err := resolveOutputFormat()
+	if err != nil {
+		return diffError(cmd, err)
+	}
+	unified := viper.GetBool("diff-unified")
+	switch {
+	case format != "text" && format != "json":
+		return diffError(cmd, fmt.Errorf("diff supports --output text and json, not %s", format))
+	case unified && format != "text":
+		return diffError(cmd, fmt.Errorf("--unified cannot be combined with --output %s", format))
+	}
+
+	p, ok := provider.Lookup(strings.ToLower(args[0]))
+	if !ok {
+		return diffError(cmd, fmt.Errorf("unknown provider %q (valid: %s)", args[0], strings.Join(providerNames(), ", ")))
+	}
+	oldResult, err := loadSnapshot(cmd.Context(), p, args[1])
+	if err != nil {
+		return diffError(cmd, fmt.Errorf("load %s: %w", args[1], err))
+	}
+	newResult, err := loadSnapshot(cmd.Context(), p, args[2])
+	if err != nil {
+		return diffError(cmd, fmt.Errorf("load %s: %w", args[2], err))
+	}
+
+	d := diffRecords(oldResult.Records, newResult.Records)
+	out := cmd.OutOrStdout()
+	switch {
+	case format == "json":
+		err = writeDiffJSON(out, p.Name(), args[1], args[2], d)
+	case unified:
+		err = writeDiffUnified(out, p.Name(), args[1], args[2], d)
+	default:
+		err = writeDiffText(out, d)
+	}
+	if err != nil {
+		return diffError(cmd, err)
+	}
+	if !d.empty() {
+		return silentExit(cmd, 1)
+	}
+	return nil
+}
+
+// diffError reports err and exits with status 2, keeping 1 for "changes
+// found".
+func diffError(cmd *cobra.Command, err error) error {
+	cmd.PrintErrln("Error:", err)
+	return silentExit(cmd, 2)
+}
+
+// loadSnapshot loads every range of p from one diff source.
+func loadSnapshot(ctx context.Context, p provider.Provider, source string) (provider.Result, error) {
+	if source != cacheSnapshot {
+		return loadProvider(ctx, p, provider.Query{Source: source, IPType: "both"})
+	}
+
+	var combined provider.Result
+	for _, key := range p.ConfigKeys() {
+		data, err := utils.ReadCacheEntry(key)
+		if err != nil {
+			return provider.Result{}, err
+		}
+		result, err := p.Parse(string(data))
+		if err != nil {
+			return provider.Result{}, fmt.Errorf("parse cache entry for %s: %w", key, err)
+		}
+		combined.Records = append(combined.Records, result.Records...)
+		if combined.Metadata == nil {
+			combined.Metadata = result.Metadata
+		}
+	}
+	return combined, nil
+}
+
+// diffRecords compares two snapshots prefix by prefix. A prefix may carry
+// several records (AWS lists a prefix once per service); it counts as
+// changed when its set of records differs, ignoring order and duplicates.
+func diffRecords(oldRecords, newRecords []provider.Record) rangeDiff {
+	oldByPrefix := groupByPrefix(oldRecords)
+	newByPrefix := groupByPrefix(newRecords)
+
+	var d rangeDiff
+	for _, pfx := range sortedPrefixes(newByPrefix) {
+		before, ok := oldByPrefix[pfx]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, newByPrefix[pfx]...)
+		case !slices.Equal(recordKeys(before), recordKeys(newByPrefix[pfx])):
+			d.Changed = append(d.Changed, prefixChange{Prefix: pfx, Old: before, New: newByPrefix[pfx]})
+		}
+	}
+	for _, pfx := range sortedPrefixes(oldByPrefix) {
+		if _, ok := newByPrefix[pfx]; !ok {
+			d.Removed = append(d.Removed, oldByPrefix[pfx]...)
+		}
+	}
+	return d
+}
+
+func groupByPrefix(records []provider.Record) map[netip.Prefix][]provider.Record {
+	out := make(map[netip.Prefix][]provider.Record)
+	for _, r := range records {
+		out[r.Prefix] = append(out[r.Prefix], r)
+	}
+	return out
+}
+
+// sortedPrefixes returns the keys of m in cidr.ComparePrefixes order.
+func sortedPrefixes(m map[netip.Prefix][]provider.Record) []netip.Prefix {
+	out := make([]netip.Prefix, 0, len(m))
+	for pfx := range m {
+		out = append(out, pfx)
+	}
+	slices.SortFunc(out, cidr.ComparePrefixes)
+	return out
+}
+
+// recordKeys describes each record's attributes, sorted and deduplicated,
+// so two record sets can be compared for equality.
+func recordKeys(records []provider.Record) []string {
+	keys := make([]string, 0, len(records))
+	for _, r := range records {
+		keys = append(keys, describeAttributes(newJSONRecord(r)))
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
+
+// writeDiffText prints one line per record, marked "+" for added, "-" for
+// removed and "~" for changed prefixes, followed by a summary line.
+func writeDiffText(w io.Writer, d rangeDiff) error {
+	bw := bufio.NewWriter(w)
+	for _, r := range d.Added {
+		fmt.Fprintf(bw, "+ %s\n", diffLine(r.Prefix, describeAttributes(newJSONRecord(r))))
+	}
+	for _, r := range d.Removed {
+		fmt.Fprintf(bw, "- %s\n", diffLine(r.Prefix, describeAttributes(newJSONRecord(r))))
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(bw, "~ %s\t%s -> %s\n", c.Prefix, strings.Join(recordKeys(c.Old), " | "), strings.Join(recordKeys(c.New), " | "))
+	}
+	fmt.Fprintf(bw, "%d added, %d removed, %d changed\n", len(d.Added), len(d.Removed), len(d.Changed))
+	return flushOutput(bw)
+}
+
+// writeDiffUnified prints the removed and then the added side of each
+// prefix, in prefix order, under ---/+++ headers naming the sources.
+func writeDiffUnified(w io.Writer, providerName, oldSource, newSource string, d rangeDiff) error {
+	type hunk struct {
+		removed  []string
+		inserted []string
+	}
+	hunks := make(map[netip.Prefix]*hunk)
+	get := func(pfx netip.Prefix) *hunk {
+		if h, ok := hunks[pfx]; ok {
+			return h
+		}
+		h := &hunk{}
+		hunks[pfx] = h
+		return h
+	}
+	for _, r := range d.Removed {
+		h := get(r.Prefix)
+		h.removed = append(h.removed, describeAttributes(newJSONRecord(r)))
+	}
+	for _, r := range d.Added {
+		h := get(r.Prefix)
+		h.inserted = append(h.inserted, describeAttributes(newJSONRecord(r)))
+	}
+	for _, c := range d.Changed {
+		h := get(c.Prefix)
+		h.removed = recordKeys(c.Old)
+		h.inserted = recordKeys(c.New)
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(hunks))
+	for pfx := range hunks {
+		prefixes = append(prefixes, pfx)
+	}
+	slices.SortFunc(prefixes, cidr.ComparePrefixes)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "--- %s %s\n+++ %s %s\n", providerName, oldSource, providerName, newSource)
+	for _, pfx := range prefixes {
+		h := hunks[pfx]
+		for _, attrs := range h.removed {
+			fmt.Fprintf(bw, "-%s\n", diffLine(pfx, attrs))
+		}
+		for _, attrs := range h.inserted {
+			fmt.Fprintf(bw, "+%s\n", diffLine(pfx, attrs))
+		}
+	}
+	return flushOutput(bw)
+}
+
+func diffLine(pfx netip.Prefix, attrs string) string {
+	if attrs == "" {
+		return pfx.String()
+	}
+	return pfx.String() + "\t" + attrs
+}
+
+// jsonDiff is the --output json shape of a diff.
+type jsonDiff struct {
+	Provider string           `json:"provider"`
+	Old      string           `json:"old"`
+	New      string           `json:"new"`
+	Added    []jsonRecord     `json:"added"`
+	Removed  []jsonRecord     `json:"removed"`
+	Changed  []jsonPrefixDiff `json:"changed"`
+}
+
+type jsonPrefixDiff struct {
+	Prefix string       `json:"prefix"`
+	Old    []jsonRecord `json:"old"`
+	New    []jsonRecord `json:"new"`
+}
+
+func writeDiffJSON(w io.Writer, providerName, oldSource, newSource string, d rangeDiff) error {
+	out := jsonDiff{
+		Provider: providerName,
+		Old:      oldSource,
+		New:      newSource,
+		Added:    jsonRecords(d.Added),
+		Removed:  jsonRecords(d.Removed),
+		Changed:  make([]jsonPrefixDiff, 0, len(d.Changed)),
+	}
+	for _, c := range d.Changed {
+		out.Changed = append(out.Changed, jsonPrefixDiff{Prefix: c.Prefix.String(), Old: jsonRecords(c.Old), New: jsonRecords(c.New)})
+	}
+	return encodeJSON(w, out)
+}
+
+func jsonRecords(records []provider.Record) []jsonRecord {
+	out := make([]jsonRecord, 0, len(records))
+	for _, r := range records {
+		out = append(out, newJSONRecord(r))
+	}
+	return out
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diffFixture() (oldRecords, newRecords []provider.Record) {
+	rec := func(prefix, region, service string) provider.Record {
+		p := netip.MustParsePrefix(prefix)
+		return provider.Record{Prefix: p, Provider: "aws", Family: provider.FamilyOf(p), Region: region, Service: service}
+	}
+	oldRecords = []provider.Record{
+		rec("3.4.12.4/32", "eu-west-1", "AMAZON"),
+		rec("3.5.140.0/22", "us-east-1", "AMAZON"),
+		rec("3.5.140.0/22", "us-east-1", "EC2"),
+		rec("2600:1f18::/36", "eu-west-1", "EC2"),
+	}
+	newRecords = []provider.Record{
+		rec("2600:1f18::/36", "eu-west-2", "EC2"),
+		rec("3.5.140.0/22", "us-east-1", "EC2"),
+		rec("3.5.140.0/22", "us-east-1", "AMAZON"),
+		rec("198.51.100.0/24", "us-east-1", "EC2"),
+	}
+	return oldRecords, newRecords
+}
+
+func TestDiffRecords(t *testing.T) {
+	oldRecords, newRecords := diffFixture()
+	d := diffRecords(oldRecords, newRecords)
+
+	require.Len(t, d.Added, 1)
+	assert.Equal(t, "198.51.100.0/24", d.Added[0].Prefix.String())
+	require.Len(t, d.Removed, 1)
+	assert.Equal(t, "3.4.12.4/32", d.Removed[0].Prefix.String())
+	require.Len(t, d.Changed, 1, "reordered records of one prefix are not a change")
+	assert.Equal(t, "2600:1f18::/36", d.Changed[0].Prefix.String())
+
+	assert.True(t, diffRecords(newRecords, newRecords).empty())
+}
+
+func TestWriteDiff(t *testing.T) {
+	d := diffRecords(diffFixture())
+
+	var buf bytes.Buffer
+	require.NoError(t, writeDiffText(&buf, d))
+	assert.Equal(t,
+		"+ 198.51.100.0/24\tregion=us-east-1 service=EC2\n"+
+			"- 3.4.12.4/32\tregion=eu-west-1 service=AMAZON\n"+
+			"~ 2600:1f18::/36\tregion=eu-west-1 service=EC2 -> region=eu-west-2 service=EC2\n"+
+			"1 added, 1 removed, 1 changed\n",
+		buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeDiffUnified(&buf, "aws", "old.json", "new.json", d))
+	assert.Equal(t,
+		"--- aws old.json\n"+
+			"+++ aws new.json\n"+
+			"-3.4.12.4/32\tregion=eu-west-1 service=AMAZON\n"+
+			"+198.51.100.0/24\tregion=us-east-1 service=EC2\n"+
+			"-2600:1f18::/36\tregion=eu-west-1 service=EC2\n"+
+			"+2600:1f18::/36\tregion=eu-west-2 service=EC2\n",
+		buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeDiffJSON(&buf, "aws", "old.json", "new.json", d))
+	assert.Contains(t, buf.String(), `"added": [`)
+	assert.Contains(t, buf.String(), `"prefix": "2600:1f18::/36"`)
+}
+
+func TestRunDiff(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("output", "text")
+
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.json")
+	newPath := filepath.Join(dir, "new.json")
+	require.NoError(t, os.WriteFile(oldPath, []byte(awsFixture), 0o600))
+	require.NoError(t, os.WriteFile(newPath, []byte(strings.Replace(awsFixture, "us-east-1", "us-east-2", 1)), 0o600))
+
+	run := func(args ...string) error {
+		cmd := &cobra.Command{}
+		cmd.SetContext(context.Background())
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		return runDiff(cmd, args)
+	}
+
+	err := run("aws", oldPath, oldPath)
+	require.NoError(t, err)
+
+	err = run("aws", oldPath, newPath)
+	var exit exitCodeError
+	require.ErrorAs(t, err, &exit)
+	assert.Equal(t, 1, exit.code)
+
+	err = run("aws", cacheSnapshot, newPath)
+	require.ErrorAs(t, err, &exit)
+	assert.Equal(t, 2, exit.code, "a missing cache entry is an error, not a difference")
+
+	err = run("oracle", oldPath, newPath)
+	require.ErrorAs(t, err, &exit)
+	assert.Equal(t, 2, exit.code)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"net/netip"
+
+	"github.com/kaumnen/cipr/internal/cidr"
+	"github.com/kaumnen/cipr/internal/provider"
+)
+
+// dropExcluded removes the records matching any of the --exclude-* values.
+func dropExcluded(records []provider.Record, excludes map[string][]string) []provider.Record {
+	if len(excludes) == 0 {
+		return records
+	}
+	out := records[:0:0]
+	for _, r := range records {
+		if !r.MatchesAny(excludes) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// subtractExcluded splits all into the records selected by filters and the
+// ones matching excludes, then carves the excluded address space out of the
+// selected records. A selected record may turn into several smaller ones
+// that keep its attributes, or vanish when it is fully covered.
+func subtractExcluded(all []provider.Record, filters, excludes map[string][]string) []provider.Record {
+	var remove []netip.Prefix
+	var selected []provider.Record
+	for _, r := range all {
+		switch {
+		case r.MatchesAny(excludes):
+			remove = append(remove, r.Prefix)
+		case r.Matches(filters):
+			selected = append(selected, r)
+		}
+	}
+	if len(remove) == 0 {
+		return selected
+	}
+
+	summarized := cidr.Summarize(remove)
+	remove = make([]netip.Prefix, 0, len(summarized))
+	for _, agg := range summarized {
+		remove = append(remove, agg.Prefix)
+	}
+
+	out := make([]provider.Record, 0, len(selected))
+	for _, r := range selected {
+		for _, p := range cidr.Subtract(r.Prefix, remove) {
+			piece := r
+			piece.Prefix = p
+			out = append(out, piece)
+		}
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func excludeFixture() []provider.Record {
+	return []provider.Record{
+		{Prefix: netip.MustParsePrefix("3.0.0.0/15"), Region: "us-east-1", Service: "AMAZON"},
+		{Prefix: netip.MustParsePrefix("3.0.0.0/16"), Region: "us-east-1", Service: "EC2"},
+		{Prefix: netip.MustParsePrefix("3.2.0.0/16"), Region: "eu-west-1", Service: "AMAZON"},
+		{Prefix: netip.MustParsePrefix("3.2.128.0/17"), Region: "eu-west-1", Service: "S3"},
+	}
+}
+
+func prefixStrings(records []provider.Record) []string {
+	out := make([]string, 0, len(records))
+	for _, r := range records {
+		out = append(out, r.Prefix.String())
+	}
+	return out
+}
+
+func TestDropExcluded(t *testing.T) {
+	records := excludeFixture()
+
+	assert.Equal(t, records, dropExcluded(records, nil))
+	got := dropExcluded(records, map[string][]string{"service": {"ec2"}, "region": {"eu-west-1"}})
+	assert.Equal(t, []string{"3.0.0.0/15"}, prefixStrings(got))
+	assert.Len(t, records, 4, "input must not be modified")
+}
+
+func TestSubtractExcluded(t *testing.T) {
+	got := subtractExcluded(excludeFixture(),
+		map[string][]string{"service": {"AMAZON"}},
+		map[string][]string{"service": {"EC2", "S3"}})
+
+	assert.Equal(t, []string{"3.1.0.0/16", "3.2.0.0/17"}, prefixStrings(got))
+	assert.Equal(t, "us-east-1", got[0].Region, "pieces keep the attributes of their record")
+	assert.Equal(t, "AMAZON", got[1].Service)
+}
+
+func TestSubtractExcludedWithoutMatches(t *testing.T) {
+	got := subtractExcluded(excludeFixture(), map[string][]string{"region": {"eu-west-1"}}, map[string][]string{"service": {"ROUTE53"}})
+	assert.Equal(t, []string{"3.2.0.0/16", "3.2.128.0/17"}, prefixStrings(got))
+}
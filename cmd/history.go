@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Inspect and prune stored snapshots of provider feeds",
+	Long: `With history = true in the config, every feed cipr downloads is also kept as
+a timestamped snapshot (identical consecutive downloads are stored once).
+Snapshots can be listed, printed, pruned, and read back as a source:
+
+  cipr aws --source history:aws@2026-09-01
+  cipr diff aws history:aws@2026-09-01 history:aws@latest
+
+A date selects the snapshot in effect at the end of that day (UTC); an RFC
+3339 time or a hash prefix from "cipr history list" also work.`,
+}
+
+var historyListCmd = &cobra.Command{
+	Use:   "list <provider>",
+	Short: "List the stored snapshots of a provider",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runHistoryList,
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <provider> [latest|date|time|hash]",
+	Short: "Print the raw feed stored in a snapshot",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE:  runHistoryShow,
+}
+
+var historyPruneCmd = &cobra.Command{
+	Use:   "prune <provider>",
+	Short: "Delete snapshots beyond the retention limits",
+	Long: `Delete the snapshots of a provider beyond history_keep or older than
+history_max_age, or the limits given as flags. The newest snapshot is never
+deleted.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistoryPrune,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyListCmd, historyShowCmd, historyPruneCmd)
+	historyPruneCmd.Flags().Int("keep", 0, "Keep at most this many snapshots (default history_keep)")
+	historyPruneCmd.Flags().Duration("max-age", 0, "Delete snapshots older than this, e.g. 2160h (default history_max_age)")
+}
+
+// historySnapshot is the --output json shape of a snapshot.
+type historySnapshot struct {
+	Key  string `json:"key"`
+	Time string `json:"time"`
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+}
+
+func runHistoryList(cmd *cobra.Command, args []string) error {
+	format, err := resolveOutputFormat()
+	if err != nil {
+		return err
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("history list supports --output text and json, not %s", format)
+	}
+	keys, err := provider.ResolveKeys(args[0])
+	if err != nil {
+		return err
+	}
+
+	var snapshots []utils.Snapshot
+	for _, key := range keys {
+		s, err := utils.ListSnapshots(key)
+		if err != nil {
+			return err
+		}
+		snapshots = append(snapshots, s...)
+	}
+	return writeSnapshots(cmd.OutOrStdout(), snapshots, format)
+}
+
+func writeSnapshots(w io.Writer, snapshots []utils.Snapshot, format string) error {
+	if format == "json" {
+		out := make([]historySnapshot, 0, len(snapshots))
+		for _, s := range snapshots {
+			out = append(out, historySnapshot{Key: s.Key, Time: s.Time.Format(time.RFC3339), Hash: s.Hash, Size: s.Size})
+		}
+		return encodeJSON(w, out)
+	}
+
+	bw := bufio.NewWriter(w)
+	if len(snapshots) == 0 {
+		fmt.Fprintln(bw, "No snapshots stored."+historyEnabledHint())
+		return flushOutput(bw)
+	}
+	for _, s := range snapshots {
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%d\n", s.Key, s.Time.Format(time.RFC3339), s.Hash, s.Size)
+	}
+	return flushOutput(bw)
+}
+
+func runHistoryShow(cmd *cobra.Command, args []string) error {
+	keys, err := provider.ResolveKeys(args[0])
+	if err != nil {
+		return err
+	}
+	if len(keys) > 1 {
+		return fmt.Errorf("%s has several feeds; name one of %s", args[0], strings.Join(keys, ", "))
+	}
+	at := "latest"
+	if len(args) == 2 {
+		at = args[1]
+	}
+
+	s, err := utils.FindSnapshot(keys[0], at)
+	if err != nil {
+		return err
+	}
+	data, err := utils.ReadSnapshot(s)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s snapshot %s from %s\n", s.Key, s.Hash, s.Time.Format(time.RFC3339))
+	if _, err := cmd.OutOrStdout().Write(data); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	return nil
+}
+
+func runHistoryPrune(cmd *cobra.Command, args []string) error {
+	keys, err := provider.ResolveKeys(args[0])
+	if err != nil {
+		return err
+	}
+	keep, maxAge := utils.HistoryRetention()
+	if cmd.Flags().Changed("keep") {
+		keep, _ = cmd.Flags().GetInt("keep")
+	}
+	if cmd.Flags().Changed("max-age") {
+		maxAge, _ = cmd.Flags().GetDuration("max-age")
+	}
+	if keep < 0 {
+		return fmt.Errorf("invalid --keep %d (must be 0 or more)", keep)
+	}
+
+	for _, key := range keys {
+		removed, err := utils.PruneSnapshots(key, keep, maxAge, time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Removed %d %s snapshot(s)\n", len(removed), key)
+	}
+	return nil
+}
+
+// historyEnabledHint is appended to empty listings when recording is off.
+func historyEnabledHint() string {
+	if utils.HistoryEnabled() {
+		return ""
+	}
+	return " (history is disabled; set history = true in the config)"
+}
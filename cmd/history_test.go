@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kaumnen/cipr/internal/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSnapshots(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	snapshots := []utils.Snapshot{
+		{Key: "aws", Time: time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC), Hash: "0123456789abcdef", Size: 42},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeSnapshots(&buf, snapshots, "text"))
+	assert.Equal(t, "aws\t2026-09-01T12:00:00Z\t0123456789abcdef\t42\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeSnapshots(&buf, nil, "text"))
+	assert.Equal(t, "No snapshots stored. (history is disabled; set history = true in the config)\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeSnapshots(&buf, snapshots, "json"))
+	assert.Contains(t, buf.String(), `"hash": "0123456789abcdef"`)
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+)
+
+// defaultLoadConcurrency bounds how many providers load at once when the
+// caller does not choose.
+const defaultLoadConcurrency = 4
+
+// loadOptions control how loadProviders schedules its work.
+type loadOptions struct {
+	// concurrency is the maximum number of providers loading at once;
+	// values below 1 use defaultLoadConcurrency.
+	concurrency int
+	// failFast cancels the remaining loads after the first failure.
+	failFast bool
+}
+
+// errLoadCanceled marks providers that were not loaded, or were interrupted,
+// because another provider failed under failFast.
+var errLoadCanceled = errors.New("canceled after another provider failed")
+
+// loadProviders loads query from each provider concurrently. results[i] and
+// errs[i] belong to providers[i]; a failing provider does not stop the others
+// unless opts.failFast is set, in which case the providers it cut short
+// report errLoadCanceled.
+func loadProviders(ctx context.Context, providers []provider.Provider, query provider.Query, opts loadOptions) ([]provider.Result, []error) {
+	results := make([]provider.Result, len(providers))
+	errs := make([]error, len(providers))
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	limit := opts.concurrency
+	if limit < 1 {
+		limit = defaultLoadConcurrency
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = loadCanceled(ctx, p)
+			continue
+		}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			result, err := loadProvider(ctx, p, query)
+			if err != nil {
+				if context.Cause(ctx) == errLoadCanceled {
+					errs[i] = loadCanceled(ctx, p)
+					return
+				}
+				errs[i] = fmt.Errorf("%s: %w", p.Name(), err)
+				if opts.failFast {
+					cancel(errLoadCanceled)
+				}
+				return
+			}
+			results[i] = result
+		})
+	}
+	wg.Wait()
+	return results, errs
+}
+
+func loadCanceled(ctx context.Context, p provider.Provider) error {
+	if cause := context.Cause(ctx); cause != errLoadCanceled {
+		return fmt.Errorf("%s: %w", p.Name(), cause)
+	}
+	return fmt.Errorf("%s: %w", p.Name(), errLoadCanceled)
+}
+
+// loadProvider loads the records query selects from p and flags the result
+// when any of its feeds was served from an expired cache entry because
+// fetching failed: metadata "stale" is "true" and "stale_age" holds the age
+// of the oldest such entry.
+func loadProvider(ctx context.Context, p provider.Provider, query provider.Query) (provider.Result, error) {
+	ctx, stale := utils.WithStaleReport(ctx)
+	result, err := p.Load(ctx, query)
+	if err != nil {
+		return result, err
+	}
+	result.Records = query.Select(result.Records)
+	result.Metadata = markStale(result.Metadata, stale)
+	return result, nil
+}
+
+// streamProvider passes each record query selects from s to yield as the
+// feed is decoded and returns the source metadata, flagged like
+// loadProvider's.
+func streamProvider(ctx context.Context, s provider.Streamer, query provider.Query, yield func(provider.Record) error) (map[string]string, error) {
+	ctx, stale := utils.WithStaleReport(ctx)
+	metadata, err := s.Stream(ctx, query, func(r provider.Record) error {
+		if !query.Selects(r) {
+			return nil
+		}
+		return yield(r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return markStale(metadata, stale), nil
+}
+
+func markStale(metadata map[string]string, stale *utils.StaleReport) map[string]string {
+	age, ok := stale.Oldest()
+	if !ok {
+		return metadata
+	}
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata["stale"] = "true"
+	metadata["stale_age"] = age.String()
+	return metadata
+}
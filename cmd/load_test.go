@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubProvider overrides the parts of provider.Provider that loadProviders
+// uses; the embedded nil interface panics if anything else is called.
+type stubProvider struct {
+	provider.Provider
+	name string
+	load func(context.Context) (provider.Result, error)
+}
+
+func (s stubProvider) Name() string { return s.name }
+
+func (s stubProvider) Load(ctx context.Context, _ provider.Query) (provider.Result, error) {
+	return s.load(ctx)
+}
+
+func stubRecords(name string, prefixes ...string) provider.Result {
+	result := provider.Result{Metadata: map[string]string{"sync_token": name + "-1"}}
+	for _, p := range prefixes {
+		pfx := netip.MustParsePrefix(p)
+		result.Records = append(result.Records, provider.Record{Prefix: pfx, Provider: name, Family: provider.FamilyOf(pfx)})
+	}
+	return result
+}
+
+func TestLoadProvidersBoundsConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	load := func(context.Context) (provider.Result, error) {
+		n := running.Add(1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return stubRecords("x", "192.0.2.0/24"), nil
+	}
+	providers := make([]provider.Provider, 6)
+	for i := range providers {
+		providers[i] = stubProvider{name: "p", load: load}
+	}
+
+	results, errs := loadProviders(context.Background(), providers, provider.Query{}, loadOptions{concurrency: 2})
+	for i := range providers {
+		require.NoError(t, errs[i])
+		assert.Len(t, results[i].Records, 1)
+	}
+	assert.LessOrEqual(t, peak.Load(), int32(2))
+}
+
+func TestLoadProvidersKeepsGoingAfterFailure(t *testing.T) {
+	providers := []provider.Provider{
+		stubProvider{name: "bad", load: func(context.Context) (provider.Result, error) { return provider.Result{}, errors.New("boom") }},
+		stubProvider{name: "good", load: func(context.Context) (provider.Result, error) { return stubRecords("good", "192.0.2.0/24"), nil }},
+	}
+	results, errs := loadProviders(context.Background(), providers, provider.Query{}, loadOptions{})
+	assert.EqualError(t, errs[0], "bad: boom")
+	require.NoError(t, errs[1])
+	assert.Len(t, results[1].Records, 1)
+}
+
+func TestLoadProvidersFailFastCancelsOthers(t *testing.T) {
+	providers := []provider.Provider{
+		stubProvider{name: "slow", load: func(ctx context.Context) (provider.Result, error) {
+			select {
+			case <-ctx.Done():
+				return provider.Result{}, ctx.Err()
+			case <-time.After(5 * time.Second):
+				return stubRecords("slow", "192.0.2.0/24"), nil
+			}
+		}},
+		stubProvider{name: "bad", load: func(context.Context) (provider.Result, error) { return provider.Result{}, errors.New("boom") }},
+		stubProvider{name: "queued", load: func(context.Context) (provider.Result, error) {
+			t.Error("queued provider should not start after a fail-fast failure")
+			return provider.Result{}, nil
+		}},
+	}
+
+	_, errs := loadProviders(context.Background(), providers, provider.Query{}, loadOptions{concurrency: 2, failFast: true})
+	assert.ErrorIs(t, errs[0], errLoadCanceled)
+	assert.EqualError(t, errs[1], "bad: boom")
+	assert.ErrorIs(t, errs[2], errLoadCanceled)
+	assert.Equal(t, errs[1], firstLoadError(errs))
+}
+
+func TestLoadProviderFlagsStaleCache(t *testing.T) {
+	cacheHome := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheHome)
+	t.Cleanup(func() { viper.Reset() })
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	viper.Set("aws_endpoint", srv.URL)
+	viper.Set("aws_stale_if_error", "72h")
+	viper.Set("aws_retries", 0)
+
+	path := filepath.Join(cacheHome, "cipr", "aws.cache")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte(awsFixture), 0o600))
+	sum := sha256.Sum256([]byte(awsFixture))
+	meta := fmt.Sprintf(`{"fetched_at": %q, "sha256": %q, "length": %d}`,
+		time.Now().Add(-30*time.Hour).UTC().Format(time.RFC3339Nano), hex.EncodeToString(sum[:]), len(awsFixture))
+	require.NoError(t, os.WriteFile(strings.TrimSuffix(path, ".cache")+".meta", []byte(meta), 0o600))
+
+	result, err := loadProvider(context.Background(), mustLookup(t, "aws"), provider.Query{Source: "config", IPType: "both"})
+	require.NoError(t, err)
+	assert.Len(t, result.Records, 3)
+	assert.Equal(t, "true", result.Metadata["stale"])
+	assert.Equal(t, "30h0m0s", result.Metadata["stale_age"])
+}
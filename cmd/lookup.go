@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kaumnen/cipr/internal/cidr"
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var lookupCmd = &cobra.Command{
+	Use:   "lookup [ip...]",
+	Short: "Find which provider and service owns an IP address",
+	Long: `Look up IP addresses in the ranges of every provider and print each
+matching range with its provider and attributes, most specific match first.
+
+Addresses are read from the arguments, or one per line from stdin when no
+arguments (or "-") are given. Ranges are loaded from the configured sources
+and the cache.`,
+	RunE: runLookup,
+}
+
+func init() {
+	rootCmd.AddCommand(lookupCmd)
+	lookupCmd.Flags().StringSlice("provider", []string{}, "Only look in these providers (comma-separated; default all)")
+	viper.BindPFlag("lookup-provider", lookupCmd.Flags().Lookup("provider"))
+}
+
+// lookupMatch is the --output json shape of one looked-up address.
+type lookupMatch struct {
+	IP      string       `json:"ip"`
+	Matches []jsonRecord `json:"matches"`
+}
+
+func runLookup(cmd *cobra.Command, args []string) error {
+	format, err := resolveOutputFormat()
+	if err != nil {
+		return err
+	}
+	if format != "text" && format != "json" && format != "ndjson" {
+		return fmt.Errorf("lookup supports --output text, json and ndjson, not %s", format)
+	}
+
+	addrs, err := lookupAddrs(cmd.InOrStdin(), args)
+	if err != nil {
+		return err
+	}
+	providers, err := selectProviders(viper.GetStringSlice("lookup-provider"))
+	if err != nil {
+		return err
+	}
+	source := viper.GetString("source")
+	if !utils.UsesConfiguredSources(source) && len(providers) != 1 {
+		return errors.New("--source other than config requires exactly one --provider")
+	}
+
+	trie, err := loadRecordTrie(cmd, providers, source)
+	if err != nil {
+		return err
+	}
+
+	matches := make([]lookupMatch, 0, len(addrs))
+	for _, addr := range addrs {
+		matches = append(matches, lookupMatch{IP: addr.String(), Matches: matchRecords(trie, addr)})
+	}
+	return writeLookup(cmd.OutOrStdout(), matches, format)
+}
+
+// lookupAddrs parses args, or stdin when args is empty or "-". Blank lines
+// and lines starting with # are skipped.
+func lookupAddrs(stdin io.Reader, args []string) ([]netip.Addr, error) {
+	if len(args) > 0 && !(len(args) == 1 && args[0] == "-") {
+		addrs := make([]netip.Addr, 0, len(args))
+		for _, arg := range args {
+			addr, err := netip.ParseAddr(strings.TrimSpace(arg))
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP address %q", arg)
+			}
+			addrs = append(addrs, addr.Unmap())
+		}
+		return addrs, nil
+	}
+
+	var addrs []netip.Addr
+	scanner := bufio.NewScanner(stdin)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		addr, err := netip.ParseAddr(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address %q on stdin line %d", text, line)
+		}
+		addrs = append(addrs, addr.Unmap())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read stdin: %w", err)
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no IP addresses given")
+	}
+	return addrs, nil
+}
+
+// loadRecordTrie loads every provider's ranges into a trie. Providers that
+// fail to load are reported on stderr and skipped; it is an error only when
+// none could be loaded.
+func loadRecordTrie(cmd *cobra.Command, providers []provider.Provider, source string) (*cidr.Trie[provider.Record], error) {
+	var trie cidr.Trie[provider.Record]
+	var failed []string
+	results, errs := loadProviders(cmd.Context(), providers, provider.Query{Source: source, IPType: "both"}, loadOptions{})
+	for i, p := range providers {
+		if errs[i] != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: skipping %v\n", errs[i])
+			failed = append(failed, p.Name())
+			continue
+		}
+		for _, r := range results[i].Records {
+			trie.Insert(r.Prefix, r)
+		}
+	}
+	if len(failed) == len(providers) {
+		return nil, fmt.Errorf("load ranges: every provider failed (%s)", strings.Join(failed, ", "))
+	}
+	return &trie, nil
+}
+
+// selectProviders resolves provider names, dropping repeats; an empty list
+// selects all.
+func selectProviders(names []string) ([]provider.Provider, error) {
+	if len(names) == 0 {
+		return provider.All(), nil
+	}
+	out := make([]provider.Provider, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		p, ok := provider.Lookup(strings.ToLower(strings.TrimSpace(name)))
+		if !ok {
+			return nil, fmt.Errorf("unknown provider %q (valid: %s)", name, strings.Join(providerNames(), ", "))
+		}
+		if seen[p.Name()] {
+			continue
+		}
+		seen[p.Name()] = true
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func providerNames() []string {
+	var names []string
+	for _, p := range provider.All() {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+// matchRecords returns the records containing addr, longest prefix first.
+// Records sharing a prefix keep their load order.
+func matchRecords(trie *cidr.Trie[provider.Record], addr netip.Addr) []jsonRecord {
+	out := []jsonRecord{}
+	for _, records := range trie.Matches(addr) {
+		for _, r := range records {
+			out = append(out, newJSONRecord(r))
+		}
+	}
+	return out
+}
+
+func writeLookup(w io.Writer, results []lookupMatch, format string) error {
+	switch format {
+	case "json":
+		return encodeJSON(w, results)
+	case "ndjson":
+		bw := bufio.NewWriter(w)
+		for _, r := range results {
+			if err := json.NewEncoder(bw).Encode(r); err != nil {
+				return fmt.Errorf("write output: %w", err)
+			}
+		}
+		return flushOutput(bw)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, r := range results {
+		if len(r.Matches) == 0 {
+			if _, err := fmt.Fprintf(bw, "%s\tno match\n", r.IP); err != nil {
+				return fmt.Errorf("write output: %w", err)
+			}
+			continue
+		}
+		for _, m := range r.Matches {
+			if _, err := fmt.Fprintf(bw, "%s\t%s\t%s\t%s\n", r.IP, m.Provider, m.Prefix, describeAttributes(m)); err != nil {
+				return fmt.Errorf("write output: %w", err)
+			}
+		}
+	}
+	return flushOutput(bw)
+}
+
+// describeAttributes renders the non-empty attributes of r as key=value
+// pairs, common fields first. Values containing spaces are quoted.
+func describeAttributes(r jsonRecord) string {
+	var parts []string
+	add := func(k, v string) {
+		if v == "" {
+			return
+		}
+		if strings.ContainsAny(v, " \t\"") {
+			v = strconv.Quote(v)
+		}
+		parts = append(parts, k+"="+v)
+	}
+	add(provider.RegionKey, r.Region)
+	add(provider.ServiceKey, r.Service)
+	add(provider.CountryKey, r.Country)
+	keys := make([]string, 0, len(r.Attributes))
+	for k := range r.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add(k, r.Attributes[k])
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kaumnen/cipr/internal/cidr"
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupAddrs(t *testing.T) {
+	addrs, err := lookupAddrs(nil, []string{"192.0.2.1", " 2001:db8::1", "::ffff:198.51.100.7"})
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Addr{
+		netip.MustParseAddr("192.0.2.1"),
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("198.51.100.7"),
+	}, addrs)
+
+	addrs, err = lookupAddrs(strings.NewReader("# from logs\n192.0.2.1\n\n  2001:db8::1  \n"), []string{"-"})
+	require.NoError(t, err)
+	assert.Len(t, addrs, 2)
+
+	_, err = lookupAddrs(nil, []string{"192.0.2.0/24"})
+	assert.ErrorContains(t, err, `invalid IP address "192.0.2.0/24"`)
+
+	_, err = lookupAddrs(strings.NewReader("192.0.2.1\nnope\n"), nil)
+	assert.ErrorContains(t, err, "stdin line 2")
+
+	_, err = lookupAddrs(strings.NewReader(""), nil)
+	assert.ErrorContains(t, err, "no IP addresses")
+}
+
+func TestMatchRecordsMostSpecificFirst(t *testing.T) {
+	records := []provider.Record{
+		{Prefix: netip.MustParsePrefix("3.0.0.0/8"), Provider: "aws", Service: "AMAZON"},
+		{Prefix: netip.MustParsePrefix("3.5.140.0/22"), Provider: "aws", Service: "EC2"},
+		{Prefix: netip.MustParsePrefix("3.5.0.0/16"), Provider: "other"},
+		{Prefix: netip.MustParsePrefix("4.0.0.0/8"), Provider: "aws"},
+	}
+	var trie cidr.Trie[provider.Record]
+	for _, r := range records {
+		trie.Insert(r.Prefix, r)
+	}
+	matches := matchRecords(&trie, netip.MustParseAddr("3.5.140.10"))
+	require.Len(t, matches, 3)
+	assert.Equal(t, "3.5.140.0/22", matches[0].Prefix)
+	assert.Equal(t, "3.5.0.0/16", matches[1].Prefix)
+	assert.Equal(t, "3.0.0.0/8", matches[2].Prefix)
+
+	assert.Empty(t, matchRecords(&trie, netip.MustParseAddr("2001:db8::1")))
+}
+
+func TestWriteLookupText(t *testing.T) {
+	results := []lookupMatch{
+		{IP: "34.1.208.5", Matches: []jsonRecord{{Prefix: "34.1.208.0/20", Provider: "gcp", Region: "africa-south1", Service: "Google Cloud", Attributes: map[string]string{"scope": "africa-south1"}}}},
+		{IP: "192.0.2.1", Matches: []jsonRecord{}},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, writeLookup(&buf, results, "text"))
+	assert.Equal(t,
+		"34.1.208.5\tgcp\t34.1.208.0/20\tregion=africa-south1 service=\"Google Cloud\" scope=africa-south1\n"+
+			"192.0.2.1\tno match\n",
+		buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeLookup(&buf, results[1:], "ndjson"))
+	assert.Equal(t, `{"ip":"192.0.2.1","matches":[]}`+"\n", buf.String())
+}
+
+func TestSelectProviders(t *testing.T) {
+	all, err := selectProviders(nil)
+	require.NoError(t, err)
+	assert.Len(t, all, len(provider.All()))
+
+	some, err := selectProviders([]string{"GitHub", "aws"})
+	require.NoError(t, err)
+	assert.Equal(t, "github", some[0].Name())
+	assert.Equal(t, "aws", some[1].Name())
+
+	repeated, err := selectProviders([]string{"aws", " AWS", "aws"})
+	require.NoError(t, err)
+	assert.Len(t, repeated, 1, "each provider is loaded once")
+
+	_, err = selectProviders([]string{"oracle"})
+	assert.ErrorContains(t, err, `unknown provider "oracle"`)
+}
+
+func TestRunLookup(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+	path := filepath.Join(t.TempDir(), "aws.json")
+	require.NoError(t, os.WriteFile(path, []byte(awsFixture), 0o600))
+	viper.Set("source", "config")
+	viper.Set("aws_local_file", path)
+	viper.Set("lookup-provider", []string{"aws"})
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	require.NoError(t, runLookup(cmd, []string{"3.5.140.10", "2600:1f18::1"}))
+	assert.Equal(t,
+		"3.5.140.10\taws\t3.5.140.0/22\tregion=us-east-1 service=EC2 network_border_group=us-east-1\n"+
+			"2600:1f18::1\taws\t2600:1f18::/36\tregion=eu-west-1 service=EC2 network_border_group=eu-west-1\n",
+		out.String())
+}
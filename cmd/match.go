@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/kaumnen/cipr/internal/cidr"
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var matchCmd = &cobra.Command{
+	Use:   "match",
+	Short: "Filter IP addresses on stdin by provider membership",
+	Long: `Read IP addresses from stdin, one per line, and print those that fall
+inside the ranges of the selected providers (or, with --invert, those that
+do not). Lines that are not IP addresses are reported on stderr and skipped.
+
+Unlike lookup and annotate, which warn about a provider that fails to load
+and carry on without it, match fails if any selected provider cannot be
+loaded: a partial set would misclassify addresses silently, and with
+--invert print addresses that belong to the missing provider.
+
+With --index, the summarized prefix set is stored next to the cache and
+reused while the cached provider data it was built from is unchanged.`,
+	Args: cobra.NoArgs,
+	RunE: runMatch,
+}
+
+func init() {
+	rootCmd.AddCommand(matchCmd)
+	matchCmd.Flags().StringSlice("provider", []string{}, "Only match against these providers (comma-separated; default all)")
+	matchCmd.Flags().Bool("invert", false, "Print the addresses that are not in any range")
+	matchCmd.Flags().Bool("index", false, "Reuse a serialized index stored next to the cache")
+	viper.BindPFlag("match-provider", matchCmd.Flags().Lookup("provider"))
+	viper.BindPFlag("match-invert", matchCmd.Flags().Lookup("invert"))
+	viper.BindPFlag("match-index", matchCmd.Flags().Lookup("index"))
+}
+
+func runMatch(cmd *cobra.Command, args []string) error {
+	providers, err := selectProviders(viper.GetStringSlice("match-provider"))
+	if err != nil {
+		return err
+	}
+	source := viper.GetString("source")
+	if !utils.UsesConfiguredSources(source) && len(providers) != 1 {
+		return errors.New("--source other than config requires exactly one --provider")
+	}
+
+	useIndex := viper.GetBool("match-index") && utils.UsesConfiguredSources(source)
+	set, err := loadMatchSet(cmd.Context(), cmd.ErrOrStderr(), providers, source, useIndex)
+	if err != nil {
+		return err
+	}
+	return filterAddrs(cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr(), set, viper.GetBool("match-invert"))
+}
+
+// filterAddrs copies the lines of in whose address is (or, with invert, is
+// not) in set to out. Memory use does not grow with the input.
+func filterAddrs(in io.Reader, out, errOut io.Writer, set *cidr.Trie[struct{}], invert bool) error {
+	bw := bufio.NewWriter(out)
+	scanner := bufio.NewScanner(in)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(text)
+		if err != nil {
+			fmt.Fprintf(errOut, "Warning: skipping line %d: invalid IP address %q\n", line, text)
+			continue
+		}
+		if set.Contains(addr) == invert {
+			continue
+		}
+		if _, err := bw.WriteString(text + "\n"); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read stdin: %w", err)
+	}
+	return flushOutput(bw)
+}
+
+// matchIndex is the serialized form of a match set: the summarized prefixes
+// plus the fingerprint of the sources they were built from.
+type matchIndex struct {
+	Fingerprint string
+	Prefixes    []netip.Prefix
+}
+
+// loadMatchSet builds the membership set of providers, from the index when
+// useIndex is set and the index is current. Any provider failing to load is
+// an error; see the match help for why.
+func loadMatchSet(ctx context.Context, errOut io.Writer, providers []provider.Provider, source string, useIndex bool) (*cidr.Trie[struct{}], error) {
+	name := matchIndexName(providers)
+	if useIndex {
+		if prefixes, ok := readMatchIndex(name, providers); ok {
+			utils.Debugf("match: using index %s (%d prefixes)", name, len(prefixes))
+			return newMatchSet(prefixes), nil
+		}
+		utils.Debugf("match: index %s missing or outdated", name)
+	}
+
+	results, errs := loadProviders(ctx, providers, provider.Query{Source: source, IPType: "both"}, loadOptions{})
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("load ranges: %w", err)
+	}
+	var all []netip.Prefix
+	for _, result := range results {
+		for _, r := range result.Records {
+			all = append(all, r.Prefix)
+		}
+	}
+	summarized := cidr.Summarize(all)
+	prefixes := make([]netip.Prefix, 0, len(summarized))
+	for _, agg := range summarized {
+		prefixes = append(prefixes, agg.Prefix)
+	}
+
+	if useIndex {
+		if err := writeMatchIndex(name, providers, prefixes); err != nil {
+			fmt.Fprintln(errOut, "Warning: index write failed:", err)
+		}
+	}
+	return newMatchSet(prefixes), nil
+}
+
+func newMatchSet(prefixes []netip.Prefix) *cidr.Trie[struct{}] {
+	var set cidr.Trie[struct{}]
+	for _, p := range prefixes {
+		set.Insert(p, struct{}{})
+	}
+	return &set
+}
+
+func matchIndexName(providers []provider.Provider) string {
+	names := make([]string, 0, len(providers))
+	for _, p := range providers {
+		names = append(names, p.Name())
+	}
+	sum := sha256.Sum256([]byte(strings.Join(names, ",")))
+	return "match-" + hex.EncodeToString(sum[:8]) + ".idx"
+}
+
+// sourcesFingerprint combines the fingerprints of every config key the
+// providers read. ok is false if any of them would be fetched again.
+func sourcesFingerprint(providers []provider.Provider) (string, bool) {
+	var parts []string
+	for _, p := range providers {
+		for _, key := range p.ConfigKeys() {
+			fp, ok := utils.SourceFingerprint(key)
+			if !ok {
+				return "", false
+			}
+			parts = append(parts, key+"="+fp)
+		}
+	}
+	return strings.Join(parts, ";"), true
+}
+
+func readMatchIndex(name string, providers []provider.Provider) ([]netip.Prefix, bool) {
+	fingerprint, ok := sourcesFingerprint(providers)
+	if !ok {
+		return nil, false
+	}
+	data, ok := utils.ReadCacheFile(name)
+	if !ok {
+		return nil, false
+	}
+	var index matchIndex
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&index); err != nil {
+		utils.Debugf("match: discarding unreadable index %s: %v", name, err)
+		return nil, false
+	}
+	if index.Fingerprint != fingerprint {
+		return nil, false
+	}
+	return index.Prefixes, true
+}
+
+func writeMatchIndex(name string, providers []provider.Provider, prefixes []netip.Prefix) error {
+	fingerprint, ok := sourcesFingerprint(providers)
+	if !ok {
+		utils.Debugf("match: not writing index %s, sources are not cached", name)
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(matchIndex{Fingerprint: fingerprint, Prefixes: prefixes}); err != nil {
+		return fmt.Errorf("encode index: %w", err)
+	}
+	return utils.WriteCacheFile(name, buf.Bytes())
+}
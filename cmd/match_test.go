@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterAddrs(t *testing.T) {
+	set := newMatchSet([]netip.Prefix{
+		netip.MustParsePrefix("3.5.140.0/22"),
+		netip.MustParsePrefix("2600:1f18::/36"),
+	})
+	input := "3.5.140.10\n1.1.1.1\n\nnot-an-ip\n 2600:1f18::5 \n::ffff:3.5.141.1\n"
+
+	var out, errOut bytes.Buffer
+	require.NoError(t, filterAddrs(strings.NewReader(input), &out, &errOut, set, false))
+	assert.Equal(t, "3.5.140.10\n2600:1f18::5\n::ffff:3.5.141.1\n", out.String())
+	assert.Contains(t, errOut.String(), `line 4: invalid IP address "not-an-ip"`)
+
+	out.Reset()
+	require.NoError(t, filterAddrs(strings.NewReader(input), &out, &bytes.Buffer{}, set, true))
+	assert.Equal(t, "1.1.1.1\n", out.String())
+}
+
+func TestRunMatchWritesAndReusesIndex(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+	path := filepath.Join(t.TempDir(), "aws.json")
+	require.NoError(t, os.WriteFile(path, []byte(awsFixture), 0o600))
+	viper.Set("source", "config")
+	viper.Set("aws_local_file", path)
+	viper.Set("match-provider", []string{"aws"})
+	viper.Set("match-index", true)
+
+	run := func() string {
+		var out bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetContext(context.Background())
+		cmd.SetIn(strings.NewReader("3.5.140.10\n192.0.2.1\n"))
+		cmd.SetOut(&out)
+		cmd.SetErr(&bytes.Buffer{})
+		require.NoError(t, runMatch(cmd, nil))
+		return out.String()
+	}
+	assert.Equal(t, "3.5.140.10\n", run())
+
+	aws := []provider.Provider{mustLookup(t, "aws")}
+	prefixes, ok := readMatchIndex(matchIndexName(aws), aws)
+	require.True(t, ok, "index should be written after the first run")
+	assert.Len(t, prefixes, 3)
+	assert.Equal(t, "3.5.140.10\n", run())
+
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	_, ok = readMatchIndex(matchIndexName(aws), aws)
+	assert.False(t, ok, "changed source invalidates the index")
+}
+
+func TestRunMatchFailsOnProviderError(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+	path := filepath.Join(t.TempDir(), "aws.json")
+	require.NoError(t, os.WriteFile(path, []byte(awsFixture), 0o600))
+	viper.Set("source", "config")
+	viper.Set("aws_local_file", path)
+	viper.Set("github_local_file", filepath.Join(t.TempDir(), "missing.json"))
+	viper.Set("match-provider", []string{"aws", "github"})
+	viper.Set("match-invert", true)
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	cmd.SetIn(strings.NewReader("192.0.2.1\n"))
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	err := runMatch(cmd, nil)
+	assert.ErrorContains(t, err, "load ranges")
+	assert.Empty(t, out.String(), "a partial set must not be used")
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// outputOptions are the rendering settings shared by every command that
+// prints ranges.
+type outputOptions struct {
+	format    string
+	verbosity string
+	template  *template.Template
+
+	aggregate       bool
+	aggregateCounts bool
+}
+
+func resolveOutputOptions(cmd *cobra.Command) (outputOptions, error) {
+	var opts outputOptions
+	var err error
+	if opts.verbosity, err = resolveVerbosity(cmd); err != nil {
+		return opts, err
+	}
+	if opts.format, err = resolveOutputFormat(); err != nil {
+		return opts, err
+	}
+	if opts.template, err = loadOutputTemplate(viper.GetString("template"), viper.GetString("template_file")); err != nil {
+		return opts, err
+	}
+	if opts.template != nil && opts.format != "text" {
+		return opts, fmt.Errorf("--template cannot be combined with --output %s", opts.format)
+	}
+
+	opts.aggregateCounts = viper.GetBool("aggregate_counts")
+	opts.aggregate = viper.GetBool("aggregate") || opts.aggregateCounts
+	if opts.aggregateCounts && opts.verbosity == "none" {
+		// The count is the point of the flag; keep it visible in text output.
+		opts.verbosity = "mini"
+	}
+	return opts, nil
+}
+
+// writeResult renders result in the format selected by opts. columns apply
+// to the text, csv and tsv formats.
+func writeResult(w io.Writer, providerName string, columns []provider.Column, result provider.Result, opts outputOptions) error {
+	if opts.template != nil {
+		return writeTemplate(w, opts.template, providerName, result)
+	}
+	switch opts.format {
+	case "json":
+		return writeJSON(w, providerName, result)
+	case "ndjson":
+		return writeNDJSON(w, providerName, result)
+	}
+	if comma, ok := csvDelimiter(opts.format); ok {
+		return writeCSV(w, columns, result.Records, comma)
+	}
+	return writeIPRanges(w, outputFields(columns, result.Records), opts.verbosity)
+}
+
+// writeValues renders --list values of dim in the format selected by opts.
+func writeValues(w io.Writer, dim provider.Dimension, values []string, opts outputOptions) error {
+	switch opts.format {
+	case "json":
+		return writeJSONValues(w, values)
+	case "ndjson":
+		return writeNDJSONValues(w, values)
+	}
+	if comma, ok := csvDelimiter(opts.format); ok {
+		return writeCSVValues(w, dim, values, comma)
+	}
+	return writeListedValues(w, values)
+}
+
+// outputField is one labelled attribute of a record. The first field is the
+// prefix unless --columns picked a different order.
+type outputField struct {
+	Label string
+	Value string
+}
+
+// outputFields lays out each record's values in column order.
+func outputFields(columns []provider.Column, records []provider.Record) [][]outputField {
+	out := make([][]outputField, 0, len(records))
+	for _, r := range records {
+		fields := make([]outputField, 0, len(columns))
+		for _, c := range columns {
+			fields = append(fields, outputField{Label: c.Label, Value: r.Get(c.Key)})
+		}
+		out = append(out, fields)
+	}
+	return out
+}
+
+// writeIPRanges renders ranges to w at the given verbosity: "none" prints the
+// first field only, "mini" a comma-separated list of field values, and "full" the
+// labelled fields. Unknown verbosity levels fall back to "none".
+func writeIPRanges(w io.Writer, ranges [][]outputField, verbosity string) error {
+	bw := bufio.NewWriter(w)
+	if len(ranges) == 0 {
+		if _, err := fmt.Fprintln(bw, "No IP ranges to display."); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+		return flushOutput(bw)
+	}
+
+	for _, fields := range ranges {
+		if _, err := fmt.Fprintln(bw, formatIPRange(fields, verbosity)); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	}
+	return flushOutput(bw)
+}
+
+func formatIPRange(fields []outputField, verbosity string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(fields))
+	switch verbosity {
+	case "mini":
+		for _, f := range fields {
+			parts = append(parts, f.Value)
+		}
+		return strings.Join(parts, ",")
+	case "full":
+		for _, f := range fields {
+			parts = append(parts, f.Label+": "+f.Value)
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return fields[0].Value
+	}
+}
+
+// writeListedValues prints one value per line, or a placeholder when values
+// is empty.
+func writeListedValues(w io.Writer, values []string) error {
+	bw := bufio.NewWriter(w)
+	if len(values) == 0 {
+		if _, err := fmt.Fprintln(bw, "No values to display."); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+		return flushOutput(bw)
+	}
+	for _, v := range values {
+		if _, err := fmt.Fprintln(bw, v); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	}
+	return flushOutput(bw)
+}
+
+func flushOutput(bw *bufio.Writer) error {
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	return nil
+}
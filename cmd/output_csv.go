@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/kaumnen/cipr/internal/provider"
+)
+
+// writeCSV writes a header row of column keys followed by one row per
+// record. comma is ',' for --output csv and '\t' for --output tsv; quoting
+// follows RFC 4180 in both cases.
+func writeCSV(w io.Writer, columns []provider.Column, records []provider.Record, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		row[i] = c.Key
+	}
+	if err := cw.Write(row); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	for _, r := range records {
+		for i, c := range columns {
+			row[i] = r.Get(c.Key)
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	}
+	return flushCSV(cw)
+}
+
+// writeCSVValues writes --list values under a single header naming the
+// listed dimension.
+func writeCSVValues(w io.Writer, dim provider.Dimension, values []string, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	if err := cw.Write([]string{dim.Key}); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	for _, v := range values {
+		if err := cw.Write([]string{v}); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	}
+	return flushCSV(cw)
+}
+
+func flushCSV(cw *csv.Writer) error {
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	return nil
+}
+
+// csvDelimiter returns the field separator of a csv-like output format.
+func csvDelimiter(format string) (rune, bool) {
+	switch format {
+	case "csv":
+		return ',', true
+	case "tsv":
+		return '\t', true
+	}
+	return 0, false
+}
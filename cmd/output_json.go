@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/kaumnen/cipr/internal/provider"
+)
+
+// jsonReport is the document written by --output json. Metadata carries the
+// provider's feed metadata (sync token, creation date, change number, ...).
+type jsonReport struct {
+	Provider string            `json:"provider"`
+	Metadata map[string]string `json:"metadata"`
+	Ranges   []jsonRecord      `json:"ranges"`
+}
+
+type jsonRecord struct {
+	Prefix     string            `json:"prefix"`
+	Provider   string            `json:"provider"`
+	Family     string            `json:"family"`
+	Region     string            `json:"region,omitempty"`
+	Service    string            `json:"service,omitempty"`
+	Country    string            `json:"country,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+func newJSONRecord(r provider.Record) jsonRecord {
+	return jsonRecord{
+		Prefix:     r.Prefix.String(),
+		Provider:   r.Provider,
+		Family:     string(r.Family),
+		Region:     r.Region,
+		Service:    r.Service,
+		Country:    r.Country,
+		Attributes: r.Attributes,
+	}
+}
+
+// jsonMetadata drops empty values so absent feed fields do not show up as "".
+func jsonMetadata(metadata map[string]string) map[string]string {
+	out := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if v != "" {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// writeJSON writes result as an indented jsonReport.
+func writeJSON(w io.Writer, providerName string, result provider.Result) error {
+	report := jsonReport{
+		Provider: providerName,
+		Metadata: jsonMetadata(result.Metadata),
+		Ranges:   make([]jsonRecord, 0, len(result.Records)),
+	}
+	for _, r := range result.Records {
+		report.Ranges = append(report.Ranges, newJSONRecord(r))
+	}
+	return encodeJSON(w, report)
+}
+
+// writeJSONValues writes --list values as a JSON array of strings.
+func writeJSONValues(w io.Writer, values []string) error {
+	if values == nil {
+		values = []string{}
+	}
+	return encodeJSON(w, values)
+}
+
+func encodeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	return nil
+}
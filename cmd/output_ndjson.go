@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/kaumnen/cipr/internal/provider"
+)
+
+// ndjsonMetadata is the last line written by --output ndjson. Range lines
+// never carry a "metadata" key, so consumers can tell the two apart.
+type ndjsonMetadata struct {
+	Provider string            `json:"provider"`
+	Count    int               `json:"count"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// writeNDJSON writes result as one flat JSON object per record followed by
+// an ndjsonMetadata line. Provider attributes become top-level fields next
+// to prefix, provider and family.
+func writeNDJSON(w io.Writer, providerName string, result provider.Result) error {
+	nw := newNDJSONWriter(w)
+	for _, r := range result.Records {
+		if err := nw.record(r); err != nil {
+			return err
+		}
+	}
+	return nw.finish(providerName, result.Metadata)
+}
+
+// ndjsonWriter writes records one at a time, so a streaming provider's
+// records reach the output while the feed is still being decoded.
+type ndjsonWriter struct {
+	bw    *bufio.Writer
+	line  []byte
+	count int
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{bw: bufio.NewWriter(w)}
+}
+
+func (nw *ndjsonWriter) record(r provider.Record) error {
+	nw.line = appendNDJSONRecord(nw.line[:0], r)
+	if _, err := nw.bw.Write(nw.line); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	nw.count++
+	return nil
+}
+
+// finish writes the ndjsonMetadata line and flushes the output.
+func (nw *ndjsonWriter) finish(providerName string, metadata map[string]string) error {
+	meta, err := json.Marshal(ndjsonMetadata{
+		Provider: providerName,
+		Count:    nw.count,
+		Metadata: jsonMetadata(metadata),
+	})
+	if err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	if _, err := nw.bw.Write(append(meta, '\n')); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	return flushOutput(nw.bw)
+}
+
+// streamNDJSON writes the records of a streaming provider as they are
+// decoded, skipping those excludes matches. If the feed turns out invalid
+// part way through, the records already written stay and the metadata line
+// is missing, which is how consumers tell a truncated stream apart.
+func streamNDJSON(ctx context.Context, w io.Writer, s provider.Streamer, providerName string, query provider.Query, excludes map[string][]string) error {
+	nw := newNDJSONWriter(w)
+	metadata, err := streamProvider(ctx, s, query, func(r provider.Record) error {
+		if r.MatchesAny(excludes) {
+			return nil
+		}
+		return nw.record(r)
+	})
+	if err != nil {
+		if flushErr := flushOutput(nw.bw); flushErr != nil {
+			return errors.Join(err, flushErr)
+		}
+		return err
+	}
+	return nw.finish(providerName, metadata)
+}
+
+// appendNDJSONRecord appends r as a single-line JSON object with a stable
+// key order: the common fields first, then attributes sorted by name.
+func appendNDJSONRecord(dst []byte, r provider.Record) []byte {
+	dst = append(dst, '{')
+	dst = appendJSONField(dst, provider.PrefixKey, r.Prefix.String(), true)
+	dst = appendJSONField(dst, provider.ProviderKey, r.Provider, false)
+	dst = appendJSONField(dst, provider.FamilyKey, string(r.Family), false)
+	for _, key := range []string{provider.RegionKey, provider.ServiceKey, provider.CountryKey} {
+		if v := r.Get(key); v != "" {
+			dst = appendJSONField(dst, key, v, false)
+		}
+	}
+
+	keys := make([]string, 0, len(r.Attributes))
+	for k := range r.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		dst = appendJSONField(dst, k, r.Attributes[k], false)
+	}
+	return append(dst, '}', '\n')
+}
+
+func appendJSONField(dst []byte, key, value string, first bool) []byte {
+	if !first {
+		dst = append(dst, ',')
+	}
+	dst = appendJSONString(dst, key)
+	dst = append(dst, ':')
+	return appendJSONString(dst, value)
+}
+
+func appendJSONString(dst []byte, s string) []byte {
+	// Marshalling a string cannot fail.
+	b, _ := json.Marshal(s)
+	return append(dst, b...)
+}
+
+// writeNDJSONValues writes --list values as one JSON string per line.
+func writeNDJSONValues(w io.Writer, values []string) error {
+	bw := bufio.NewWriter(w)
+	var line []byte
+	for _, v := range values {
+		line = append(appendJSONString(line[:0], v), '\n')
+		if _, err := bw.Write(line); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	}
+	return flushOutput(bw)
+}
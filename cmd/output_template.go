@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/kaumnen/cipr/internal/provider"
+)
+
+// templateSummary is the data passed to the optional "header" and "footer"
+// templates.
+type templateSummary struct {
+	Provider string
+	Count    int
+	Metadata map[string]string
+}
+
+var templateFuncs = template.FuncMap{
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"join":    templateJoin,
+	"family":  templateFamily,
+	"netmask": templateNetmask,
+}
+
+// loadOutputTemplate parses the --template text or the contents of
+// --template-file. It returns nil when neither is set.
+func loadOutputTemplate(text, file string) (*template.Template, error) {
+	if text != "" && file != "" {
+		return nil, errors.New("--template and --template-file are mutually exclusive")
+	}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read template file: %w", err)
+		}
+		text = string(data)
+	}
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("range").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// writeTemplate executes tmpl once per record. When the template defines
+// "header" or "footer" blocks they are executed with a templateSummary before
+// and after the records. Every block's output is terminated with a newline
+// unless it already ends with one; empty output is skipped.
+func writeTemplate(w io.Writer, tmpl *template.Template, providerName string, result provider.Result) error {
+	bw := bufio.NewWriter(w)
+	summary := templateSummary{
+		Provider: providerName,
+		Count:    len(result.Records),
+		Metadata: result.Metadata,
+	}
+
+	var buf bytes.Buffer
+	execute := func(name string, data any) error {
+		buf.Reset()
+		if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+			return fmt.Errorf("execute template: %w", err)
+		}
+		if buf.Len() == 0 {
+			return nil
+		}
+		if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+		if _, err := bw.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+		return nil
+	}
+
+	if tmpl.Lookup("header") != nil {
+		if err := execute("header", summary); err != nil {
+			return err
+		}
+	}
+	for _, r := range result.Records {
+		if err := execute(tmpl.Name(), r); err != nil {
+			return err
+		}
+	}
+	if tmpl.Lookup("footer") != nil {
+		if err := execute("footer", summary); err != nil {
+			return err
+		}
+	}
+	return flushOutput(bw)
+}
+
+// templateJoin joins its arguments with sep. Slice arguments are flattened,
+// so both {{join "," .Region .Service}} and {{.Values | join ","}} work.
+func templateJoin(sep string, items ...any) string {
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case []string:
+			parts = append(parts, v...)
+		case string:
+			parts = append(parts, v)
+		default:
+			parts = append(parts, fmt.Sprint(v))
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// templatePrefix accepts the values templates hold: a netip.Prefix, a
+// netip.Addr, or their string forms.
+func templatePrefix(v any) (netip.Prefix, error) {
+	switch p := v.(type) {
+	case netip.Prefix:
+		return p, nil
+	case netip.Addr:
+		return netip.PrefixFrom(p, p.BitLen()), nil
+	case string:
+		if !strings.Contains(p, "/") {
+			addr, err := netip.ParseAddr(p)
+			if err != nil {
+				return netip.Prefix{}, err
+			}
+			return netip.PrefixFrom(addr, addr.BitLen()), nil
+		}
+		return netip.ParsePrefix(p)
+	}
+	return netip.Prefix{}, fmt.Errorf("unsupported prefix value of type %T", v)
+}
+
+// templateFamily returns "ipv4" or "ipv6".
+func templateFamily(v any) (string, error) {
+	p, err := templatePrefix(v)
+	if err != nil {
+		return "", err
+	}
+	return string(provider.FamilyOf(p)), nil
+}
+
+// templateNetmask returns the prefix's mask in address notation, e.g.
+// "255.255.252.0" for a /22.
+func templateNetmask(v any) (string, error) {
+	p, err := templatePrefix(v)
+	if err != nil {
+		return "", err
+	}
+	mask := make([]byte, p.Addr().BitLen()/8)
+	for i := 0; i < p.Bits(); i++ {
+		mask[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(mask)
+	return addr.String(), nil
+}
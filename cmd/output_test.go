@@ -0,0 +1,330 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteIPRanges(t *testing.T) {
+	awsColumns := mustLookup(t, "aws").Columns()
+	prefixes := []provider.Record{
+		{Prefix: netip.MustParsePrefix("3.5.140.0/22"), Region: "us-east-1", Service: "AMAZON", Attributes: map[string]string{"network_border_group": "us-east-1"}},
+		{Prefix: netip.MustParsePrefix("2600:1f18:480:d000::/56"), Region: "us-west-2", Service: "ROUTE53", Attributes: map[string]string{"network_border_group": "us-west-2"}},
+	}
+	doColumns := mustLookup(t, "do").Columns()
+	cloudflareColumns := mustLookup(t, "cloudflare").Columns()
+
+	tests := []struct {
+		name      string
+		ranges    [][]outputField
+		verbosity string
+		want      string
+	}{
+		{
+			name:      "empty",
+			verbosity: "none",
+			want:      "No IP ranges to display.\n",
+		},
+		{
+			name:      "none prints prefixes only",
+			ranges:    outputFields(awsColumns, prefixes),
+			verbosity: "none",
+			want:      "3.5.140.0/22\n2600:1f18:480:d000::/56\n",
+		},
+		{
+			name:      "mini prints comma-separated values",
+			ranges:    outputFields(awsColumns, prefixes[:1]),
+			verbosity: "mini",
+			want:      "3.5.140.0/22,us-east-1,AMAZON,us-east-1\n",
+		},
+		{
+			name:      "full prints labelled values",
+			ranges:    outputFields(awsColumns, prefixes),
+			verbosity: "full",
+			want: "IP Prefix: 3.5.140.0/22, Region: us-east-1, Service: AMAZON, Network Border Group: us-east-1\n" +
+				"IP Prefix: 2600:1f18:480:d000::/56, Region: us-west-2, Service: ROUTE53, Network Border Group: us-west-2\n",
+		},
+		{
+			name:      "unknown verbosity falls back to none",
+			ranges:    outputFields(awsColumns, prefixes[:1]),
+			verbosity: "bogus",
+			want:      "3.5.140.0/22\n",
+		},
+		{
+			name: "digitalocean full",
+			ranges: outputFields(doColumns, []provider.Record{
+				{Prefix: netip.MustParsePrefix("192.168.1.0/24"), Country: "US", Region: "California", Attributes: map[string]string{"city": "San Francisco", "zip": "94107"}},
+			}),
+			verbosity: "full",
+			want:      "IP Range: 192.168.1.0/24, Country: US, Region: California, City: San Francisco, ZIP: 94107\n",
+		},
+		{
+			name:      "cloudflare mini behaves like none",
+			ranges:    outputFields(cloudflareColumns, []provider.Record{{Prefix: netip.MustParsePrefix("1.1.1.0/24")}}),
+			verbosity: "mini",
+			want:      "1.1.1.0/24\n",
+		},
+		{
+			name:      "cloudflare full",
+			ranges:    outputFields(cloudflareColumns, []provider.Record{{Prefix: netip.MustParsePrefix("1.1.1.0/24")}, {Prefix: netip.MustParsePrefix("2606:4700::/32")}}),
+			verbosity: "full",
+			want:      "Cloudflare IP: 1.1.1.0/24\nCloudflare IP: 2606:4700::/32\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeIPRanges(&buf, tt.ranges, tt.verbosity))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestWriteListedValues(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeListedValues(&buf, []string{"eu-west-1", "us-east-1"}))
+	assert.Equal(t, "eu-west-1\nus-east-1\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeListedValues(&buf, nil))
+	assert.Equal(t, "No values to display.\n", buf.String())
+}
+
+func mustLookup(t *testing.T, name string) provider.Provider {
+	t.Helper()
+	p, ok := provider.Lookup(name)
+	require.True(t, ok, "provider %q not registered", name)
+	return p
+}
+
+func TestWriteJSON(t *testing.T) {
+	result := provider.Result{
+		Records: []provider.Record{
+			{
+				Prefix:     netip.MustParsePrefix("192.168.1.0/24"),
+				Provider:   "do",
+				Family:     provider.IPv4,
+				Region:     "California",
+				Country:    "US",
+				Attributes: map[string]string{"city": "San Francisco, CA", "zip": "94107"},
+			},
+			{Prefix: netip.MustParsePrefix("2606:4700::/32"), Provider: "do", Family: provider.IPv6},
+		},
+		Metadata: map[string]string{"sync_token": "123", "create_date": ""},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeJSON(&buf, "do", result))
+
+	var got jsonReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, "do", got.Provider)
+	assert.Equal(t, map[string]string{"sync_token": "123"}, got.Metadata)
+	require.Len(t, got.Ranges, 2)
+	assert.Equal(t, jsonRecord{
+		Prefix:     "192.168.1.0/24",
+		Provider:   "do",
+		Family:     "ipv4",
+		Region:     "California",
+		Country:    "US",
+		Attributes: map[string]string{"city": "San Francisco, CA", "zip": "94107"},
+	}, got.Ranges[0])
+	assert.NotContains(t, buf.String(), `"service"`)
+}
+
+func TestWriteJSONEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeJSON(&buf, "github", provider.Result{}))
+	assert.JSONEq(t, `{"provider": "github", "metadata": {}, "ranges": []}`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeJSONValues(&buf, nil))
+	assert.JSONEq(t, `[]`, buf.String())
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	result := provider.Result{
+		Records: []provider.Record{
+			{
+				Prefix:     netip.MustParsePrefix("192.168.1.0/24"),
+				Provider:   "do",
+				Family:     provider.IPv4,
+				Region:     "California",
+				Country:    "US",
+				Attributes: map[string]string{"zip": "94107", "city": `San "Fran", CA`},
+			},
+			{Prefix: netip.MustParsePrefix("2606:4700::/32"), Provider: "do", Family: provider.IPv6},
+		},
+		Metadata: map[string]string{"sync_token": "123"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeNDJSON(&buf, "do", result))
+	assert.Equal(t,
+		`{"prefix":"192.168.1.0/24","provider":"do","family":"ipv4","region":"California","country":"US","city":"San \"Fran\", CA","zip":"94107"}`+"\n"+
+			`{"prefix":"2606:4700::/32","provider":"do","family":"ipv6"}`+"\n"+
+			`{"provider":"do","count":2,"metadata":{"sync_token":"123"}}`+"\n",
+		buf.String())
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		assert.True(t, json.Valid([]byte(line)), line)
+	}
+}
+
+func TestWriteNDJSONValues(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeNDJSONValues(&buf, []string{"eu-west-1", "us-east-1"}))
+	assert.Equal(t, "\"eu-west-1\"\n\"us-east-1\"\n", buf.String())
+}
+
+func TestWriteCSV(t *testing.T) {
+	columns := mustLookup(t, "do").Columns()
+	records := []provider.Record{
+		{Prefix: netip.MustParsePrefix("5.101.96.0/21"), Country: "NL", Region: "NL-NH", Attributes: map[string]string{"city": "Amsterdam", "zip": "1098 XH"}},
+		{Prefix: netip.MustParsePrefix("192.168.1.0/24"), Country: "US", Region: "CA", Attributes: map[string]string{"city": `San Francisco, "SF"`, "zip": "94107"}},
+	}
+
+	tests := []struct {
+		name  string
+		comma rune
+		want  string
+	}{
+		{
+			name:  "csv",
+			comma: ',',
+			want: "prefix,country,region,city,zip\n" +
+				"5.101.96.0/21,NL,NL-NH,Amsterdam,1098 XH\n" +
+				"192.168.1.0/24,US,CA,\"San Francisco, \"\"SF\"\"\",94107\n",
+		},
+		{
+			name:  "tsv",
+			comma: '\t',
+			want: "prefix\tcountry\tregion\tcity\tzip\n" +
+				"5.101.96.0/21\tNL\tNL-NH\tAmsterdam\t1098 XH\n" +
+				"192.168.1.0/24\tUS\tCA\t\"San Francisco, \"\"SF\"\"\"\t94107\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeCSV(&buf, columns, records, tt.comma))
+			assert.Equal(t, tt.want, buf.String())
+
+			r := csv.NewReader(strings.NewReader(buf.String()))
+			r.Comma = tt.comma
+			rows, err := r.ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, `San Francisco, "SF"`, rows[2][3])
+		})
+	}
+}
+
+func TestWriteCSVValues(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeCSVValues(&buf, provider.Dimension{Key: "region"}, []string{"eu-west-1"}, ','))
+	assert.Equal(t, "region\neu-west-1\n", buf.String())
+}
+
+func TestWriteTemplate(t *testing.T) {
+	result := provider.Result{
+		Records: []provider.Record{
+			{Prefix: netip.MustParsePrefix("3.5.140.0/22"), Provider: "aws", Family: provider.IPv4, Region: "us-east-1", Service: "EC2", Attributes: map[string]string{"network_border_group": "us-east-1"}},
+			{Prefix: netip.MustParsePrefix("2600:1f18::/36"), Provider: "aws", Family: provider.IPv6, Region: "eu-west-1", Service: "EC2"},
+		},
+		Metadata: map[string]string{"sync_token": "42"},
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "one line per range",
+			text: "allow {{.Prefix}}; # {{.Region}}",
+			want: "allow 3.5.140.0/22; # us-east-1\nallow 2600:1f18::/36; # eu-west-1\n",
+		},
+		{
+			name: "helpers",
+			text: `{{.Prefix}} {{family .Prefix}} {{netmask .Prefix}} {{upper .Region}} {{join "/" .Service .Attributes.network_border_group}}`,
+			want: "3.5.140.0/22 ipv4 255.255.252.0 US-EAST-1 EC2/us-east-1\n" +
+				"2600:1f18::/36 ipv6 ffff:ffff:f000:: EU-WEST-1 EC2/\n",
+		},
+		{
+			name: "header and footer",
+			text: `{{define "header"}}# {{.Provider}} {{.Count}} {{.Metadata.sync_token}}{{end}}` +
+				`{{define "footer"}}# end{{end}}` +
+				"{{.Prefix}}\n",
+			want: "# aws 2 42\n3.5.140.0/22\n2600:1f18::/36\n# end\n",
+		},
+		{
+			name: "empty output is skipped",
+			text: `{{if eq .Family "ipv4"}}{{.Prefix}}{{end}}`,
+			want: "3.5.140.0/22\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := loadOutputTemplate(tt.text, "")
+			require.NoError(t, err)
+			var buf bytes.Buffer
+			require.NoError(t, writeTemplate(&buf, tmpl, "aws", result))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestLoadOutputTemplate(t *testing.T) {
+	tmpl, err := loadOutputTemplate("", "")
+	require.NoError(t, err)
+	assert.Nil(t, tmpl)
+
+	path := filepath.Join(t.TempDir(), "ranges.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte("{{.Prefix}}\n"), 0o600))
+	tmpl, err = loadOutputTemplate("", path)
+	require.NoError(t, err)
+	require.NotNil(t, tmpl)
+
+	_, err = loadOutputTemplate("{{.Prefix}}", path)
+	assert.ErrorContains(t, err, "mutually exclusive")
+
+	_, err = loadOutputTemplate("{{.Prefix", "")
+	assert.ErrorContains(t, err, "parse template")
+
+	_, err = loadOutputTemplate("", filepath.Join(t.TempDir(), "missing.tmpl"))
+	assert.ErrorContains(t, err, "read template file")
+}
+
+func TestTemplateNetmask(t *testing.T) {
+	tests := map[string]string{
+		"10.0.0.0/8":     "255.0.0.0",
+		"192.0.2.0/31":   "255.255.255.254",
+		"0.0.0.0/0":      "0.0.0.0",
+		"192.0.2.1":      "255.255.255.255",
+		"2001:db8::/32":  "ffff:ffff::",
+		"2001:db8::/128": "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+	}
+	for in, want := range tests {
+		got, err := templateNetmask(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	_, err := templateNetmask("bogus")
+	assert.Error(t, err)
+	_, err = templateNetmask(42)
+	assert.Error(t, err)
+}
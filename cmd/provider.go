@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	for _, p := range provider.All() {
+		rootCmd.AddCommand(newProviderCommand(p))
+	}
+}
+
+// newProviderCommand builds the subcommand for p. Flags are bound to viper
+// under keys prefixed with the provider name (aws_ipv4, aws-filter-region,
+// aws-list, ...).
+func newProviderCommand(p provider.Provider) *cobra.Command {
+	name := p.Name()
+	cmd := &cobra.Command{
+		Use:   name,
+		Short: p.Short(),
+		Long:  p.Long(),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runProvider(cmd, p)
+		},
+	}
+
+	cmd.Flags().Bool("ipv4", false, "Get only IPv4 ranges")
+	cmd.Flags().Bool("ipv6", false, "Get only IPv6 ranges")
+	viper.BindPFlag(name+"_ipv4", cmd.Flags().Lookup("ipv4"))
+	viper.BindPFlag(name+"_ipv6", cmd.Flags().Lookup("ipv6"))
+
+	dims := p.FilterDimensions()
+	if _, ok := p.(provider.CompositeFilterer); ok {
+		flags := make([]string, 0, len(dims))
+		for _, dim := range dims {
+			flags = append(flags, dimensionFlag(dim))
+		}
+		cmd.Flags().String("filter", "", "Filter results. Syntax: "+strings.Join(flags, ","))
+		viper.BindPFlag(name+"-filter", cmd.Flags().Lookup("filter"))
+	}
+	for _, dim := range dims {
+		flag := "filter-" + dimensionFlag(dim)
+		cmd.Flags().StringSlice(flag, []string{}, dim.Usage)
+		viper.BindPFlag(name+"-"+flag, cmd.Flags().Lookup(flag))
+	}
+	for _, dim := range dims {
+		flag := "exclude-" + dimensionFlag(dim)
+		cmd.Flags().StringSlice(flag, []string{}, fmt.Sprintf("Drop ranges whose %s matches (comma-separated)", strings.ReplaceAll(dim.Key, "_", " ")))
+		viper.BindPFlag(name+"-"+flag, cmd.Flags().Lookup(flag))
+	}
+
+	if listDims := p.ListDimensions(); len(listDims) > 0 {
+		usage := fmt.Sprintf("List unique values for a dimension instead of IP ranges. Valid: %s. Composes with --filter-* flags; ignores --ipv4/--ipv6.",
+			strings.Join(dimensionPlurals(listDims), ", "))
+		cmd.Flags().String("list", "", usage)
+		viper.BindPFlag(name+"-list", cmd.Flags().Lookup("list"))
+	}
+	return cmd
+}
+
+func runProvider(cmd *cobra.Command, p provider.Provider) error {
+	opts, err := resolveOutputOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	name := p.Name()
+	query := provider.Query{
+		Source:  viper.GetString("source"),
+		IPType:  resolveIPType(viper.GetBool(name+"_ipv4"), viper.GetBool(name+"_ipv6")),
+		Filters: make(map[string][]string),
+	}
+	for _, dim := range p.FilterDimensions() {
+		if values := viper.GetStringSlice(name + "-filter-" + dimensionFlag(dim)); len(values) > 0 {
+			query.Filters[dim.Key] = values
+		}
+	}
+	if composite, ok := p.(provider.CompositeFilterer); ok {
+		if filter := viper.GetString(name + "-filter"); filter != "" {
+			if len(query.Filters) > 0 {
+				return errors.New("--filter cannot be used with individual filter flags")
+			}
+			query.Filters = composite.FiltersFromComposite(filter)
+		}
+	}
+	excludes := make(map[string][]string)
+	for _, dim := range p.FilterDimensions() {
+		if values := viper.GetStringSlice(name + "-exclude-" + dimensionFlag(dim)); len(values) > 0 {
+			excludes[dim.Key] = values
+		}
+	}
+
+	if list := viper.GetString(name + "-list"); list != "" {
+		if opts.template != nil {
+			return errors.New("--template cannot be used with --list")
+		}
+		if opts.aggregate {
+			return errors.New("--aggregate cannot be used with --list")
+		}
+		dim, err := listDimension(p, list)
+		if err != nil {
+			return err
+		}
+		query.IPType = "both"
+		result, err := loadProvider(cmd.Context(), p, query)
+		if err != nil {
+			return err
+		}
+		records := dropExcluded(result.Records, excludes)
+		return writeValues(cmd.OutOrStdout(), dim, provider.ListValues(records, dim), opts)
+	}
+
+	defaults := p.Columns()
+	if opts.aggregate {
+		defaults = aggregateColumns(opts.aggregateCounts)
+	}
+	columns, err := selectColumns(name, defaults, viper.GetStringSlice("columns"))
+	if err != nil {
+		return err
+	}
+	if s, ok := p.(provider.Streamer); ok && streamable(opts, excludes) {
+		return streamNDJSON(cmd.Context(), cmd.OutOrStdout(), s, name, query, excludes)
+	}
+	result, err := loadExcluding(cmd, p, query, excludes)
+	if err != nil {
+		return err
+	}
+	if opts.aggregate {
+		result.Records = aggregateRecords(result.Records, opts.aggregateCounts)
+	}
+	return writeResult(cmd.OutOrStdout(), name, columns, result, opts)
+}
+
+// loadExcluding loads the ranges selected by query without those matching
+// excludes. With --subtract the excluded address space is carved out of the
+// remaining prefixes; that needs the excluded records too, so the feed is
+// loaded unfiltered once and filtered here.
+// streamable reports whether records can be written as the feed is
+// decoded: only ndjson output writes them one at a time, and --aggregate and
+// --subtract need every record before the first can be written.
+func streamable(opts outputOptions, excludes map[string][]string) bool {
+	if opts.format != "ndjson" || opts.template != nil || opts.aggregate {
+		return false
+	}
+	return len(excludes) == 0 || !viper.GetBool("subtract")
+}
+
+func loadExcluding(cmd *cobra.Command, p provider.Provider, query provider.Query, excludes map[string][]string) (provider.Result, error) {
+	if len(excludes) == 0 {
+		return loadProvider(cmd.Context(), p, query)
+	}
+	if !viper.GetBool("subtract") {
+		result, err := loadProvider(cmd.Context(), p, query)
+		if err != nil {
+			return provider.Result{}, err
+		}
+		result.Records = dropExcluded(result.Records, excludes)
+		return result, nil
+	}
+
+	filters := query.Filters
+	query.Filters = nil
+	result, err := loadProvider(cmd.Context(), p, query)
+	if err != nil {
+		return provider.Result{}, err
+	}
+	result.Records = subtractExcluded(result.Records, filters, excludes)
+	return result, nil
+}
+
+func listDimension(p provider.Provider, list string) (provider.Dimension, error) {
+	dims := p.ListDimensions()
+	for _, dim := range dims {
+		if dim.Plural == list {
+			return dim, nil
+		}
+	}
+	return provider.Dimension{}, fmt.Errorf("invalid --list value %q (valid: %s)", list, strings.Join(dimensionPlurals(dims), ", "))
+}
+
+// commonColumns are available on every provider in addition to its own
+// columns.
+var commonColumns = []provider.Column{
+	{Key: provider.ProviderKey, Label: "Provider"},
+	{Key: provider.FamilyKey, Label: "Family"},
+	{Key: provider.RegionKey, Label: "Region"},
+	{Key: provider.ServiceKey, Label: "Service"},
+	{Key: provider.CountryKey, Label: "Country"},
+}
+
+// selectColumns resolves a --columns value against defaults plus the common
+// columns. An empty selection keeps defaults.
+func selectColumns(name string, defaults []provider.Column, keys []string) ([]provider.Column, error) {
+	if len(keys) == 0 {
+		return defaults, nil
+	}
+
+	available := slices.Clone(defaults)
+	for _, c := range commonColumns {
+		if !hasColumn(available, c.Key) {
+			available = append(available, c)
+		}
+	}
+
+	selected := make([]provider.Column, 0, len(keys))
+	for _, key := range keys {
+		key = strings.ToLower(strings.TrimSpace(key))
+		i := slices.IndexFunc(available, func(c provider.Column) bool { return c.Key == key })
+		if i < 0 {
+			valid := make([]string, 0, len(available))
+			for _, c := range available {
+				valid = append(valid, c.Key)
+			}
+			return nil, fmt.Errorf("invalid --columns value %q for %s (valid: %s)", key, name, strings.Join(valid, ", "))
+		}
+		selected = append(selected, available[i])
+	}
+	return selected, nil
+}
+
+func hasColumn(columns []provider.Column, key string) bool {
+	return slices.ContainsFunc(columns, func(c provider.Column) bool { return c.Key == key })
+}
+
+func dimensionFlag(dim provider.Dimension) string {
+	return strings.ReplaceAll(dim.Key, "_", "-")
+}
+
+func dimensionPlurals(dims []provider.Dimension) []string {
+	plurals := make([]string, 0, len(dims))
+	for _, dim := range dims {
+		plurals = append(plurals, dim.Plural)
+	}
+	return plurals
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderCommandsAreRegistered(t *testing.T) {
+	for _, name := range []string{"aws", "azure", "cloudflare", "do", "gcp", "github", "icloud"} {
+		t.Run(name, func(t *testing.T) {
+			cmd, _, err := rootCmd.Find([]string{name})
+			require.NoError(t, err)
+			assert.Equal(t, name, cmd.Name())
+			assert.NotNil(t, cmd.Flags().Lookup("ipv4"))
+			assert.NotNil(t, cmd.Flags().Lookup("ipv6"))
+		})
+	}
+}
+
+func TestNewProviderCommandFlags(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+
+	aws := newProviderCommand(mustLookup(t, "aws"))
+	for _, flag := range []string{"filter", "filter-region", "filter-service", "filter-network-border-group", "list"} {
+		assert.NotNil(t, aws.Flags().Lookup(flag), flag)
+	}
+
+	cloudflare := newProviderCommand(mustLookup(t, "cloudflare"))
+	assert.Nil(t, cloudflare.Flags().Lookup("filter"))
+	assert.Nil(t, cloudflare.Flags().Lookup("list"))
+}
+
+func TestListDimension(t *testing.T) {
+	aws := mustLookup(t, "aws")
+
+	dim, err := listDimension(aws, "network-border-groups")
+	require.NoError(t, err)
+	assert.Equal(t, "network_border_group", dim.Key)
+
+	_, err = listDimension(aws, "zones")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid --list value "zones"`)
+	assert.Contains(t, err.Error(), "regions, services, network-border-groups")
+}
+
+const awsFixture = `{
+  "syncToken": "1",
+  "createDate": "2024-10-18-23-03-11",
+  "prefixes": [
+    {"ip_prefix": "3.4.12.4/32", "region": "eu-west-1", "service": "AMAZON", "network_border_group": "eu-west-1"},
+    {"ip_prefix": "3.5.140.0/22", "region": "us-east-1", "service": "EC2", "network_border_group": "us-east-1"}
+  ],
+  "ipv6_prefixes": [
+    {"ipv6_prefix": "2600:1f18::/36", "region": "eu-west-1", "service": "EC2", "network_border_group": "eu-west-1"}
+  ]
+}`
+
+func TestRunProvider(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "aws.json")
+	require.NoError(t, os.WriteFile(source, []byte(awsFixture), 0o600))
+
+	tests := []struct {
+		name     string
+		settings map[string]any
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "filters ranges",
+			settings: map[string]any{"aws-filter-region": []string{"eu-west-1"}, "aws_ipv4": true},
+			want:     "3.4.12.4/32\n",
+		},
+		{
+			name:     "lists values",
+			settings: map[string]any{"aws-list": "services"},
+			want:     "AMAZON\nEC2\n",
+		},
+		{
+			name:     "json output",
+			settings: map[string]any{"aws-filter-region": []string{"eu-west-1"}, "aws_ipv6": true, "output": "json"},
+			want: `{
+  "provider": "aws",
+  "metadata": {
+    "create_date": "2024-10-18-23-03-11",
+    "sync_token": "1"
+  },
+  "ranges": [
+    {
+      "prefix": "2600:1f18::/36",
+      "provider": "aws",
+      "family": "ipv6",
+      "region": "eu-west-1",
+      "service": "EC2",
+      "attributes": {
+        "network_border_group": "eu-west-1"
+      }
+    }
+  ]
+}
+`,
+		},
+		{
+			name:     "json list",
+			settings: map[string]any{"aws-list": "services", "output": "json"},
+			want:     "[\n  \"AMAZON\",\n  \"EC2\"\n]\n",
+		},
+		{
+			name:     "csv output with selected columns",
+			settings: map[string]any{"aws_ipv4": true, "output": "csv", "columns": []string{"prefix", "network_border_group", "family"}},
+			want:     "prefix,network_border_group,family\n3.4.12.4/32,eu-west-1,ipv4\n3.5.140.0/22,us-east-1,ipv4\n",
+		},
+		{
+			name:     "template output",
+			settings: map[string]any{"aws_ipv4": true, "template": "allow {{.Prefix}}; # {{.Region}}"},
+			want:     "allow 3.4.12.4/32; # eu-west-1\nallow 3.5.140.0/22; # us-east-1\n",
+		},
+		{
+			name:     "rejects template with structured output",
+			settings: map[string]any{"template": "{{.Prefix}}", "output": "json"},
+			wantErr:  "--template cannot be combined with --output json",
+		},
+		{
+			name:     "aggregate with counts",
+			settings: map[string]any{"aws-filter-service": []string{"EC2"}, "aggregate_counts": true},
+			want:     "3.5.140.0/22,1\n2600:1f18::/36,1\n",
+		},
+		{
+			name:     "rejects aggregate with list",
+			settings: map[string]any{"aws-list": "regions", "aggregate": true},
+			wantErr:  "--aggregate cannot be used with --list",
+		},
+		{
+			name:     "exclude drops records",
+			settings: map[string]any{"aws-exclude-service": []string{"ec2"}},
+			want:     "3.4.12.4/32\n",
+		},
+		{
+			name:     "subtract carves excluded prefixes",
+			settings: map[string]any{"aws-filter-region": []string{"eu-west-1"}, "aws-exclude-service": []string{"EC2"}, "subtract": true},
+			want:     "3.4.12.4/32\n",
+		},
+		{
+			name:     "rejects composite filter with individual filters",
+			settings: map[string]any{"aws-filter": "eu-west-1", "aws-filter-service": []string{"EC2"}},
+			wantErr:  "--filter cannot be used with individual filter flags",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { viper.Reset() })
+			viper.Set("source", source)
+			for k, v := range tt.settings {
+				viper.Set(k, v)
+			}
+
+			var out bytes.Buffer
+			cmd := &cobra.Command{}
+			cmd.SetContext(context.Background())
+			cmd.SetOut(&out)
+			err := runProvider(cmd, mustLookup(t, "aws"))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestSelectColumns(t *testing.T) {
+	gcp := mustLookup(t, "gcp")
+
+	columns, err := selectColumns("gcp", gcp.Columns(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, gcp.Columns(), columns)
+
+	columns, err = selectColumns("gcp", gcp.Columns(), []string{"service", " Prefix", "family", "region"})
+	require.NoError(t, err)
+	keys := make([]string, 0, len(columns))
+	for _, c := range columns {
+		keys = append(keys, c.Key)
+	}
+	assert.Equal(t, []string{"service", "prefix", "family", "region"}, keys)
+
+	_, err = selectColumns("gcp", gcp.Columns(), []string{"prefix", "zip"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid --columns value "zip" for gcp`)
+}
+
+func TestRunProviderStreamsNDJSON(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "icloud.csv")
+	run := func(t *testing.T, csv string, settings map[string]any) (string, error) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(source, []byte(csv), 0o600))
+		t.Cleanup(func() { viper.Reset() })
+		viper.Set("source", source)
+		viper.Set("output", "ndjson")
+		for k, v := range settings {
+			viper.Set(k, v)
+		}
+		var out bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetContext(context.Background())
+		cmd.SetOut(&out)
+		err := runProvider(cmd, mustLookup(t, "icloud"))
+		return out.String(), err
+	}
+
+	t.Run("filters and excludes while streaming", func(t *testing.T) {
+		out, err := run(t, "172.224.224.0/27,GB,GB-EN,London\n172.224.225.0/27,GB,GB-SC,Glasgow\n2a02:26f7::/64,GB,GB-EN,London\n",
+			map[string]any{"icloud_ipv4": true, "icloud-exclude-city": []string{"glasgow"}})
+		require.NoError(t, err)
+		assert.Equal(t, `{"prefix":"172.224.224.0/27","provider":"icloud","family":"ipv4","region":"GB-EN","country":"GB","city":"London"}
+{"provider":"icloud","count":1,"metadata":{}}
+`, out)
+	})
+
+	t.Run("records decoded before an invalid row are already written", func(t *testing.T) {
+		out, err := run(t, "172.224.224.0/27,GB,GB-EN,London\nnot-a-cidr,GB\n", nil)
+		assert.ErrorContains(t, err, "row 2")
+		assert.Equal(t, `{"prefix":"172.224.224.0/27","provider":"icloud","family":"ipv4","region":"GB-EN","country":"GB","city":"London"}
+`, out)
+	})
+}
@@ -0,0 +1,13 @@
+package cmd
+
+// Provider packages register themselves with internal/provider from init.
+// Importing a package here is all it takes to expose it as a subcommand.
+import (
+	_ "github.com/kaumnen/cipr/internal/aws"
+	_ "github.com/kaumnen/cipr/internal/azure"
+	_ "github.com/kaumnen/cipr/internal/cloudflare"
+	_ "github.com/kaumnen/cipr/internal/digitalocean"
+	_ "github.com/kaumnen/cipr/internal/gcp"
+	_ "github.com/kaumnen/cipr/internal/github"
+	_ "github.com/kaumnen/cipr/internal/icloud"
+)
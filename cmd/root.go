@@ -1,12 +1,13 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 
+	"github.com/kaumnen/cipr/internal/provider"
 	"github.com/kaumnen/cipr/internal/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -31,6 +32,14 @@ var rootCmd = &cobra.Command{
 		if err := utils.ValidateProxyURL(viper.GetString("proxy")); err != nil {
 			return err
 		}
+		for _, key := range provider.ConfigKeys() {
+			if err := utils.ValidateProxyURL(viper.GetString(key + "_proxy")); err != nil {
+				return fmt.Errorf("%s_proxy: %w", key, err)
+			}
+		}
+		if viper.GetBool("offline") && viper.GetBool("no_cache") {
+			return errors.New("--offline cannot be combined with --no-cache")
+		}
 		return nil
 	},
 	Long: `cipr is a CLI tool for retrieving IP ranges from various cloud providers
@@ -45,10 +54,27 @@ with cloud infrastructure.`,
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
+		var exit exitCodeError
+		if errors.As(err, &exit) {
+			os.Exit(exit.code)
+		}
 		os.Exit(1)
 	}
 }
 
+// exitCodeError ends the process with code. Commands return it through
+// silentExit to report an outcome, such as "differences found", in the exit
+// status rather than as an error message.
+type exitCodeError struct{ code int }
+
+func (e exitCodeError) Error() string { return fmt.Sprintf("exit status %d", e.code) }
+
+// silentExit makes cmd exit with code without printing an error.
+func silentExit(cmd *cobra.Command, code int) error {
+	cmd.SilenceErrors = true
+	return exitCodeError{code: code}
+}
+
 func init() {
 	if version != "" {
 		utils.UserAgent = "cipr/" + version
@@ -58,15 +84,31 @@ func init() {
 
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Enable verbose output (equivalent to --verbose-mode=full)")
 	rootCmd.PersistentFlags().String("verbose-mode", "none", "Verbosity level: none, mini, full. Overrides --verbose")
-	rootCmd.PersistentFlags().String("source", "config", "Data source: config, an HTTP(S) URL, or a local file path")
+	rootCmd.PersistentFlags().StringP("output", "o", "text", "Output format: "+strings.Join(outputFormats, ", "))
+	rootCmd.PersistentFlags().StringSlice("columns", nil, "Columns to print and their order for text, csv and tsv output, e.g. prefix,region (default: all provider columns)")
+	rootCmd.PersistentFlags().String("template", "", `Render each range with a Go text/template, e.g. 'allow {{.Prefix}}; # {{.Region}}'`)
+	rootCmd.PersistentFlags().String("template-file", "", "Read the output template from a file")
+	rootCmd.PersistentFlags().Bool("aggregate", false, "Summarize ranges into the minimal covering set of prefixes per address family")
+	rootCmd.PersistentFlags().Bool("aggregate-counts", false, "With --aggregate, show how many input prefixes collapsed into each output prefix (implies --aggregate)")
+	rootCmd.PersistentFlags().Bool("subtract", false, "With --exclude-* flags, carve the excluded prefixes out of the remaining ranges instead of only dropping matching records")
+	rootCmd.PersistentFlags().String("source", "config", "Data source: config, an HTTP(S) URL, a local file path, or a stored snapshot (history:<provider>@<date>)")
 	rootCmd.PersistentFlags().Bool("no-cache", false, "Bypass cache (skip read and write)")
-	rootCmd.PersistentFlags().String("proxy", "", "HTTP(S) proxy URL (defaults to standard proxy environment variables)")
+	rootCmd.PersistentFlags().Bool("offline", false, "Never use the network: serve configured sources from the cache regardless of age, or from local files")
+	rootCmd.PersistentFlags().String("proxy", "", `Proxy URL (http, https, socks5 or socks5h, with optional user:password@) or "direct" (defaults to standard proxy environment variables)`)
 	rootCmd.PersistentFlags().Bool("debug", false, "Enable diagnostic logging on stderr")
 
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
 	viper.BindPFlag("verbose_mode", rootCmd.PersistentFlags().Lookup("verbose-mode"))
+	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+	viper.BindPFlag("columns", rootCmd.PersistentFlags().Lookup("columns"))
+	viper.BindPFlag("template", rootCmd.PersistentFlags().Lookup("template"))
+	viper.BindPFlag("template_file", rootCmd.PersistentFlags().Lookup("template-file"))
+	viper.BindPFlag("aggregate", rootCmd.PersistentFlags().Lookup("aggregate"))
+	viper.BindPFlag("aggregate_counts", rootCmd.PersistentFlags().Lookup("aggregate-counts"))
+	viper.BindPFlag("subtract", rootCmd.PersistentFlags().Lookup("subtract"))
 	viper.BindPFlag("source", rootCmd.PersistentFlags().Lookup("source"))
 	viper.BindPFlag("no_cache", rootCmd.PersistentFlags().Lookup("no-cache"))
+	viper.BindPFlag("offline", rootCmd.PersistentFlags().Lookup("offline"))
 	viper.BindPFlag("proxy", rootCmd.PersistentFlags().Lookup("proxy"))
 	viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
@@ -148,6 +190,23 @@ func isValidVerbosity(v string) bool {
 	return false
 }
 
+// outputFormats are the accepted --output values. "text" is the classic
+// line output shaped by --verbose-mode.
+var outputFormats = []string{"text", "json", "ndjson", "csv", "tsv"}
+
+func resolveOutputFormat() (string, error) {
+	format := viper.GetString("output")
+	if format == "" {
+		return "text", nil
+	}
+	for _, f := range outputFormats {
+		if format == f {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("invalid output format %q (allowed: %s)", format, strings.Join(outputFormats, ", "))
+}
+
 func createDefaultConfig(configPath string) error {
 	dir := filepath.Dir(configPath)
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
@@ -169,23 +228,59 @@ func createDefaultConfig(configPath string) error {
 #   <provider>_cache_ttl  = how long to reuse a cached hosted response. Go duration
 #                           string ("24h", "30m"). "0s" disables caching for this
 #                           provider; defaults to 24h if unset or unparseable.
+#   <provider>_stale_if_error = how long past its TTL a cached response may still
+#                           be used when fetching fails (overrides the global
+#                           stale_if_error, e.g. "72h"). Unset disables it.
+#   <provider>_retries    = extra attempts after a network error, 429 or 5xx
+#                           (overrides the global retries, default 2). Likewise
+#                           <provider>_retry_delay, _retry_max_delay, _retry_jitter
+#                           and _http_timeout override the global keys below.
+#   [<provider>_headers]  = table of extra request headers for the provider's
+#                           endpoint; values may reference ${ENV_VARS}, e.g.
+#                           Authorization = "Bearer ${ARTIFACTORY_TOKEN}".
+#                           Values are redacted in "cipr configure" and logs.
+#                           They are sent only to the endpoint's host and are
+#                           dropped when a redirect leaves it.
+#
+# Failed requests are retried with exponential backoff starting at retry_delay
+# (default "500ms") and capped at retry_max_delay (default "10s"), randomized by
+# retry_jitter (0 to 1, default 0.2). A Retry-After header sets the delay; one
+# beyond retry_max_delay ends the retries. http_timeout (default "30s") bounds
+# each attempt and must be positive. Only timeouts, refused or reset connections
+# and truncated responses are retried; TLS, proxy and URL errors fail at once.
+#
+# proxy accepts http://, https://, socks5:// and socks5h:// URLs (user:password@
+# for authentication; with SOCKS the proxy resolves hostnames), or "direct" to
+# ignore HTTP(S)_PROXY. <provider>_proxy overrides it for one provider, e.g.
+# github_proxy = "socks5h://127.0.0.1:1080" or aws_proxy = "direct".
+#
+# TLS: tls_ca_file (PEM bundle trusted on top of the system roots, e.g. for a
+# re-signing proxy), tls_client_cert + tls_client_key (PEM pair for mutual TLS),
+# tls_min_version ("1.2" by default, or "1.3") and tls_insecure_skip_verify
+# (disables certificate checks; avoid). Each can be set per provider as
+# <provider>_tls_ca_file etc., and they also apply to an https:// proxy.
+#
+# history = true keeps a timestamped snapshot of every fetched feed under
+# $XDG_DATA_HOME/cipr/history for "cipr history" and --source history:aws@<date>.
+# history_keep (default 100, 0 = unlimited) and history_max_age (Go duration,
+# e.g. "2160h") bound how many snapshots are retained per feed.
+#
+# github_token authenticates requests to api.github.com (GITHUB_TOKEN or GH_TOKEN
+# in the environment take precedence). It is never sent to other hosts.
 
 proxy = ""
 debug = false
+offline = false
+history = false
 
 `
 	if _, err := fmt.Fprint(file, header); err != nil {
 		return fmt.Errorf("write config file: %w", err)
 	}
 
-	keys := make([]string, 0, len(utils.DefaultEndpoints))
-	for k := range utils.DefaultEndpoints {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	for _, k := range keys {
-		_, err := fmt.Fprintf(file, "%s_endpoint = %q\n%s_local_file = \"\"\n%s_cache_ttl = \"24h\"\n\n", k, utils.DefaultEndpoints[k], k, k)
+	for _, k := range provider.ConfigKeys() {
+		endpoint, _ := provider.DefaultEndpoint(k)
+		_, err := fmt.Fprintf(file, "%s_endpoint = %q\n%s_local_file = \"\"\n%s_cache_ttl = \"24h\"\n\n", k, endpoint, k, k)
 		if err != nil {
 			return fmt.Errorf("write config file: %w", err)
 		}
@@ -69,9 +69,20 @@ func TestEnsureConfigFileLeavesMissingCustomConfigForNormalCommands(t *testing.T
 	assert.True(t, os.IsNotExist(err))
 }
 
-func TestUsesConfiguredSources(t *testing.T) {
-	assert.True(t, usesConfiguredSources("config"))
-	assert.True(t, usesConfiguredSources("hosted"))
-	assert.False(t, usesConfiguredSources("https://example.test/ranges"))
-	assert.False(t, usesConfiguredSources("ranges.txt"))
+func TestResolveOutputFormat(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+
+	got, err := resolveOutputFormat()
+	require.NoError(t, err)
+	assert.Equal(t, "text", got)
+
+	viper.Set("output", "json")
+	got, err = resolveOutputFormat()
+	require.NoError(t, err)
+	assert.Equal(t, "json", got)
+
+	viper.Set("output", "yaml")
+	_, err = resolveOutputFormat()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid output format "yaml"`)
 }
@@ -1,15 +1,12 @@
 package aws
 
 import (
-	"bytes"
 	"context"
-	"io"
-	"os"
 	"path/filepath"
 	"sort"
-	"strings"
 	"testing"
 
+	"github.com/kaumnen/cipr/internal/provider"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -46,64 +43,61 @@ func TestSeparateFilters(t *testing.T) {
 	}
 }
 
-func TestFiltrateIPRanges(t *testing.T) {
+func TestLoadSelectsFilters(t *testing.T) {
 	testCases := []struct {
 		name     string
-		ipType   string
-		filters  Filters
-		expected []IPPrefix
+		query    provider.Query
+		expected []string
 	}{
 		{
-			name:    "ipv4 - filters: us-east-1 region, EBS service",
-			ipType:  "ipv4",
-			filters: Filters{Region: []string{"us-east-1"}, Service: []string{"EBS"}},
-			expected: []IPPrefix{
-				IPv4Prefix{IPAddress: "44.192.140.112/28", Region: "us-east-1", Service: "EBS", NetworkBorderGroup: "us-east-1"},
-				IPv4Prefix{IPAddress: "44.192.140.128/29", Region: "us-east-1", Service: "EBS", NetworkBorderGroup: "us-east-1"},
-				IPv4Prefix{IPAddress: "44.222.159.166/31", Region: "us-east-1", Service: "EBS", NetworkBorderGroup: "us-east-1"},
-				IPv4Prefix{IPAddress: "44.222.159.176/28", Region: "us-east-1", Service: "EBS", NetworkBorderGroup: "us-east-1"},
+			name:  "ipv4 - filters: us-east-1 region, EBS service",
+			query: provider.Query{IPType: "ipv4", Filters: map[string][]string{"region": {"us-east-1"}, "service": {"EBS"}}},
+			expected: []string{
+				"44.192.140.112/28",
+				"44.192.140.128/29",
+				"44.222.159.166/31",
+				"44.222.159.176/28",
 			},
 		},
 		{
-			name:   "ipv6 - filters: eu-central-1 region, S3 service, eu-central-1 network border group",
-			ipType: "ipv6",
-			filters: Filters{
-				Region:             []string{"eu-central-1"},
-				Service:            []string{"S3"},
-				NetworkBorderGroup: []string{"eu-central-1"},
-			},
-			expected: []IPPrefix{
-				IPv6Prefix{IPv6Address: "2a05:d070:4000::/40", Region: "eu-central-1", Service: "S3", NetworkBorderGroup: "eu-central-1"},
-				IPv6Prefix{IPv6Address: "2a05:d079:4000::/40", Region: "eu-central-1", Service: "S3", NetworkBorderGroup: "eu-central-1"},
-				IPv6Prefix{IPv6Address: "2a05:d034:4000::/40", Region: "eu-central-1", Service: "S3", NetworkBorderGroup: "eu-central-1"},
-				IPv6Prefix{IPv6Address: "2a05:d07a:4000::/40", Region: "eu-central-1", Service: "S3", NetworkBorderGroup: "eu-central-1"},
-				IPv6Prefix{IPv6Address: "2a05:d078:4000::/40", Region: "eu-central-1", Service: "S3", NetworkBorderGroup: "eu-central-1"},
-				IPv6Prefix{IPv6Address: "2a05:d050:4000::/40", Region: "eu-central-1", Service: "S3", NetworkBorderGroup: "eu-central-1"},
+			name: "ipv6 - filters: eu-central-1 region, S3 service, eu-central-1 network border group",
+			query: provider.Query{IPType: "ipv6", Filters: map[string][]string{
+				"region":               {"eu-central-1"},
+				"service":              {"S3"},
+				"network_border_group": {"eu-central-1"},
+			}},
+			expected: []string{
+				"2a05:d070:4000::/40",
+				"2a05:d079:4000::/40",
+				"2a05:d034:4000::/40",
+				"2a05:d07a:4000::/40",
+				"2a05:d078:4000::/40",
+				"2a05:d050:4000::/40",
 			},
 		},
+		{
+			name:     "composite filter matches case-insensitively",
+			query:    provider.Query{IPType: "ipv4", Filters: filtersFromComposite("US-EAST-1,ebs")},
+			expected: []string{"44.192.140.112/28", "44.192.140.128/29", "44.222.159.166/31", "44.222.159.176/28"},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			rawData := mockGetReq()
-
-			result, err := filtrateIPRanges(rawData, tc.ipType, tc.filters)
-			assert.NoError(t, err)
+			tc.query.Source = filepath.Join("..", "testdata", "mock_ip_ranges_response.json")
+			result, err := Provider{}.Load(context.Background(), tc.query)
+			require.NoError(t, err)
 
-			assert.Equal(t, len(tc.expected), len(result), "Test case '%s' failed: expected %d items, got %d",
-				tc.name, len(tc.expected), len(result))
-
-			for i, expectedIP := range tc.expected {
-				assert.Equal(t, expectedIP.GetIPAddress(), result[i].GetIPAddress(), "IP address mismatch at index %d", i)
-				assert.Equal(t, expectedIP.GetRegion(), result[i].GetRegion(), "Region mismatch at index %d", i)
-				assert.Equal(t, expectedIP.GetService(), result[i].GetService(), "Service mismatch at index %d", i)
-				assert.Equal(t, expectedIP.GetNetworkBorderGroup(), result[i].GetNetworkBorderGroup(), "Network border group mismatch at index %d", i)
+			var got []string
+			for _, rec := range tc.query.Select(result.Records) {
+				got = append(got, rec.Prefix.String())
 			}
+			assert.Equal(t, tc.expected, got)
 		})
 	}
 }
 
-func TestFiltrateIPRanges_Validation(t *testing.T) {
+func TestParseIPRanges_Validation(t *testing.T) {
 	tests := []struct {
 		name string
 		raw  string
@@ -115,170 +109,28 @@ func TestFiltrateIPRanges_Validation(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := filtrateIPRanges(tt.raw, "ipv4", Filters{})
+			_, err := parseIPRanges(tt.raw)
 			require.Error(t, err)
 			assert.Contains(t, err.Error(), tt.want)
 		})
 	}
 }
 
-func TestMatchesFilterMultipleValues(t *testing.T) {
-	prefix := IPv4Prefix{Region: "eu-central-1", Service: "S3", NetworkBorderGroup: "eu-central-1"}
-
-	assert.True(t, matchesFilter(prefix, Filters{
-		Region:             []string{"eu-west-1", "EU-CENTRAL-1"},
-		Service:            []string{"EC2", "s3"},
-		NetworkBorderGroup: []string{"eu-central-1", "global"},
-	}))
-	assert.False(t, matchesFilter(prefix, Filters{
-		Region:  []string{"eu-west-1", "eu-central-1"},
-		Service: []string{"EC2", "DYNAMODB"},
-	}))
-}
-
-func TestPrintIPRanges(t *testing.T) {
-	testCases := []struct {
-		name           string
-		IPranges       []IPPrefix
-		verbosity      string
-		expectedOutput string
-	}{
-		{
-			name:           "Empty IP range list",
-			IPranges:       []IPPrefix{},
-			verbosity:      "none",
-			expectedOutput: "No IP ranges to display.\n",
-		},
-		{
-			name: "IPv4 - Verbosity None",
-			IPranges: []IPPrefix{
-				IPv4Prefix{IPAddress: "52.216.160.0/23", Region: "us-east-1", Service: "S3", NetworkBorderGroup: "us-east-1"},
-				IPv4Prefix{IPAddress: "54.240.224.0/21", Region: "us-west-1", Service: "dynamodb", NetworkBorderGroup: "us-west-1"},
-			},
-			verbosity:      "none",
-			expectedOutput: "52.216.160.0/23\n54.240.224.0/21\n",
-		},
-		{
-			name: "IPv6 - Verbosity Mini",
-			IPranges: []IPPrefix{
-				IPv6Prefix{IPv6Address: "2600:1f18:400:d000::/56", Region: "us-east-1", Service: "EC2", NetworkBorderGroup: "us-east-1"},
-			},
-			verbosity:      "mini",
-			expectedOutput: "2600:1f18:400:d000::/56,us-east-1,EC2,us-east-1\n",
-		},
-		{
-			name: "Mixed IPv4/IPv6 - Verbosity Full",
-			IPranges: []IPPrefix{
-				IPv4Prefix{IPAddress: "3.5.140.0/22", Region: "us-east-1", Service: "AMAZON", NetworkBorderGroup: "us-east-1"},
-				IPv6Prefix{IPv6Address: "2600:1f18:480:d000::/56", Region: "us-west-2", Service: "ROUTE53", NetworkBorderGroup: "us-west-2"},
-			},
-			verbosity:      "full",
-			expectedOutput: "IP Prefix: 3.5.140.0/22, Region: us-east-1, Service: AMAZON, Network Border Group: us-east-1\nIP Prefix: 2600:1f18:480:d000::/56, Region: us-west-2, Service: ROUTE53, Network Border Group: us-west-2\n",
-		},
-		{
-			name: "Invalid Verbosity - Defaults to None",
-			IPranges: []IPPrefix{
-				IPv4Prefix{IPAddress: "15.181.152.0/22", Region: "eu-west-1", Service: "CLOUDFRONT", NetworkBorderGroup: "eu-west-1"},
-			},
-			verbosity:      "invalid",
-			expectedOutput: "15.181.152.0/22\n",
-		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			oldStdout := os.Stdout
-			r, w, _ := os.Pipe()
-			os.Stdout = w
-
-			printIPRanges(tc.IPranges, tc.verbosity)
-
-			w.Close()
-			os.Stdout = oldStdout
-
-			var buf bytes.Buffer
-			io.Copy(&buf, r)
-			output := buf.String()
-
-			assert.Equal(t, tc.expectedOutput, output, "Output mismatch for test case: %s", tc.name)
-		})
-	}
-}
-
-func captureStdout(t *testing.T, fn func()) string {
-	t.Helper()
-	oldStdout := os.Stdout
-	r, w, err := os.Pipe()
+func TestParseIPRangesKeepsMetadata(t *testing.T) {
+	result, err := parseIPRanges(`{"syncToken":"1700000000","createDate":"2026-01-01-00-00-00","prefixes":[{"ip_prefix":"192.0.2.0/24"}]}`)
 	require.NoError(t, err)
-	os.Stdout = w
-
-	fn()
-
-	w.Close()
-	os.Stdout = oldStdout
-
-	var buf bytes.Buffer
-	_, _ = io.Copy(&buf, r)
-	return buf.String()
-}
-
-func TestPrintListedValues(t *testing.T) {
-	prefixes := []IPPrefix{
-		IPv4Prefix{Region: "us-east-1", Service: "EC2", NetworkBorderGroup: "us-east-1"},
-		IPv4Prefix{Region: "us-east-1", Service: "S3", NetworkBorderGroup: "us-east-1"},
-		IPv6Prefix{Region: "eu-west-1", Service: "EC2", NetworkBorderGroup: "eu-west-1"},
-		IPv4Prefix{Region: "", Service: "AMAZON", NetworkBorderGroup: ""},
-	}
-
-	t.Run("regions sorted and deduped", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(prefixes, "regions"))
-		})
-		assert.Equal(t, "eu-west-1\nus-east-1\n", out)
-	})
-
-	t.Run("services sorted and deduped", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(prefixes, "services"))
-		})
-		assert.Equal(t, "AMAZON\nEC2\nS3\n", out)
-	})
-
-	t.Run("network-border-groups sorted and deduped", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(prefixes, "network-border-groups"))
-		})
-		assert.Equal(t, "eu-west-1\nus-east-1\n", out)
-	})
-
-	t.Run("empty input prints placeholder", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(nil, "regions"))
-		})
-		assert.Equal(t, "No values to display.\n", out)
-	})
-
-	t.Run("unknown dim returns error", func(t *testing.T) {
-		err := printListedValues(prefixes, "bogus")
-		require.Error(t, err)
-		assert.Contains(t, err.Error(), "unknown list dimension")
-	})
+	assert.Equal(t, "1700000000", result.SyncToken)
+	assert.Equal(t, "2026-01-01-00-00-00", result.CreateDate)
+	assert.Len(t, result.Prefixes, 1)
 }
 
-func TestGetIPRanges_List(t *testing.T) {
-	cfg := Config{
-		Source:    filepath.Join("..", "testdata", "mock_ip_ranges_response.json"),
-		IPType:    "",
-		Filter:    "",
-		List:      "regions",
-		Verbosity: "none",
-	}
-
-	out := captureStdout(t, func() {
-		require.NoError(t, GetIPRanges(context.Background(), cfg))
+func TestProviderListRegions(t *testing.T) {
+	result, err := Provider{}.Load(context.Background(), provider.Query{
+		Source: filepath.Join("..", "testdata", "mock_ip_ranges_response.json"),
+		IPType: "both",
 	})
-
-	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	require.NoError(t, err)
+	lines := provider.ListValues(result.Records, Provider{}.ListDimensions()[0])
 	require.NotEmpty(t, lines)
 	assert.True(t, sort.StringsAreSorted(lines), "regions output not sorted: %v", lines)
 
@@ -290,3 +142,16 @@ func TestGetIPRanges_List(t *testing.T) {
 		seen[l] = struct{}{}
 	}
 }
+
+func TestProviderParseMapsAttributes(t *testing.T) {
+	raw := `{"syncToken": "1", "createDate": "x", "prefixes": [{"ip_prefix": "3.5.140.0/22", "region": "us-east-1", "service": "AMAZON", "network_border_group": "us-east-1-wl1"}]}`
+	result, err := Provider{}.Parse(raw)
+	require.NoError(t, err)
+	require.Len(t, result.Records, 1)
+
+	rec := result.Records[0]
+	assert.Equal(t, "aws", rec.Provider)
+	assert.Equal(t, "us-east-1", rec.Region)
+	assert.Equal(t, "AMAZON", rec.Service)
+	assert.Equal(t, map[string]string{"network_border_group": "us-east-1-wl1"}, rec.Attributes)
+}
@@ -0,0 +1,87 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+)
+
+const defaultEndpoint = "https://ip-ranges.amazonaws.com/ip-ranges.json"
+
+func init() {
+	provider.Register(Provider{})
+}
+
+// Provider exposes the AWS ip-ranges.json feed through the provider registry.
+type Provider struct{}
+
+func (Provider) Name() string  { return "aws" }
+func (Provider) Short() string { return "Get AWS IP ranges." }
+func (Provider) Long() string  { return `Get AWS IPv4 and IPv6 ranges with optional filtering.` }
+
+func (Provider) ConfigKeys() []string { return []string{"aws"} }
+
+func (Provider) DefaultEndpoints() map[string]string {
+	return map[string]string{"aws": defaultEndpoint}
+}
+
+func (Provider) Columns() []provider.Column {
+	return []provider.Column{
+		{Key: provider.PrefixKey, Label: "IP Prefix"},
+		{Key: "region", Label: "Region"},
+		{Key: "service", Label: "Service"},
+		{Key: "network_border_group", Label: "Network Border Group"},
+	}
+}
+
+func (Provider) FilterDimensions() []provider.Dimension {
+	return []provider.Dimension{
+		{Key: "region", Plural: "regions", Usage: "Filter results by AWS region (comma-separated)"},
+		{Key: "service", Plural: "services", Usage: "Filter results by AWS service (comma-separated)"},
+		{Key: "network_border_group", Plural: "network-border-groups", Usage: "Filter results by AWS network border group (comma-separated)"},
+	}
+}
+
+func (p Provider) ListDimensions() []provider.Dimension { return p.FilterDimensions() }
+
+func (Provider) FiltersFromComposite(filter string) map[string][]string {
+	return filtersFromComposite(filter)
+}
+
+func (Provider) Parse(raw string) (provider.Result, error) {
+	result, err := parseIPRanges(raw)
+	if err != nil {
+		return provider.Result{}, err
+	}
+	return toProviderResult(result)
+}
+
+func (p Provider) Load(ctx context.Context, q provider.Query) (provider.Result, error) {
+	raw, err := utils.GetRawData(ctx, provider.SourceFor(q.Source, "aws"))
+	if err != nil {
+		return provider.Result{}, err
+	}
+	return p.Parse(raw)
+}
+
+func toProviderResult(result Result) (provider.Result, error) {
+	out := provider.Result{
+		Records: make([]provider.Record, 0, len(result.Prefixes)),
+		Metadata: map[string]string{
+			"sync_token":  result.SyncToken,
+			"create_date": result.CreateDate,
+		},
+	}
+	for _, p := range result.Prefixes {
+		rec, err := provider.NewRecord("aws", p.GetIPAddress())
+		if err != nil {
+			return provider.Result{}, err
+		}
+		rec.Region = p.GetRegion()
+		rec.Service = p.GetService()
+		rec.Attributes = map[string]string{"network_border_group": p.GetNetworkBorderGroup()}
+		out.Records = append(out.Records, rec)
+	}
+	return out, nil
+}
@@ -9,7 +9,6 @@ import (
 	"os"
 	"regexp"
 	"strings"
-	"time"
 
 	"github.com/kaumnen/cipr/internal/utils"
 	"github.com/spf13/viper"
@@ -21,24 +20,12 @@ type Prefix struct {
 	Service string
 }
 
-type Config struct {
-	Source    string
-	IPType    string
-	Filter    string
-	Filters   Filters
-	List      string
-	Verbosity string
-}
-
-type Filters struct {
-	Region  []string
-	Service []string
-}
-
-type rawData struct {
-	Cloud        string  `json:"cloud"`
-	ChangeNumber int     `json:"changeNumber"`
-	Values       []value `json:"values"`
+// Result is the content of one ServiceTags document together with the
+// document's own metadata.
+type Result struct {
+	Cloud        string
+	ChangeNumber int
+	Prefixes     []Prefix
 }
 
 type value struct {
@@ -79,53 +66,6 @@ func scrapeMissError(pageURL string, body []byte) error {
 		"page layout may have changed. %s", pageURL, recoveryHint)
 }
 
-func GetIPRanges(ctx context.Context, config Config) error {
-	raw, err := fetchRawData(ctx, config.Source)
-	if err != nil {
-		return err
-	}
-
-	filters := config.Filters
-	if config.Filter != "" {
-		filters = filtersFromComposite(config.Filter)
-	}
-	prefixes, err := filtrateIPRanges(raw, config.IPType, filters)
-	if err != nil {
-		return err
-	}
-	if config.List != "" {
-		return printListedValues(prefixes, config.List)
-	}
-	printIPRanges(prefixes, config.Verbosity)
-	return nil
-}
-
-func printListedValues(prefixes []Prefix, dim string) error {
-	var get func(Prefix) string
-	switch dim {
-	case "regions":
-		get = func(p Prefix) string { return p.Region }
-	case "services":
-		get = func(p Prefix) string { return p.Service }
-	default:
-		return fmt.Errorf("unknown list dimension %q (valid: regions, services)", dim)
-	}
-
-	values := make([]string, 0, len(prefixes))
-	for _, p := range prefixes {
-		values = append(values, get(p))
-	}
-	values = utils.DedupeSorted(values)
-	if len(values) == 0 {
-		fmt.Println("No values to display.")
-		return nil
-	}
-	for _, v := range values {
-		fmt.Println(v)
-	}
-	return nil
-}
-
 // fetchRawData mirrors utils.GetRawData's source dispatch but adds a
 // browser-UA scrape step when the resolved endpoint is the Microsoft
 // details.aspx page (Microsoft serves an anti-bot stub to non-browser UAs).
@@ -143,7 +83,7 @@ func fetchRawData(ctx context.Context, source string) (string, error) {
 		}
 		endpointURL = viper.GetString(source + "_endpoint")
 		if endpointURL == "" {
-			endpointURL = utils.DefaultEndpoints[source]
+			endpointURL, _ = utils.DefaultEndpoint(source)
 		}
 		cacheKey = source
 	default:
@@ -170,6 +110,9 @@ func fetchRawData(ctx context.Context, source string) (string, error) {
 }
 
 func scrapeJSONURL(ctx context.Context, pageURL string) (string, error) {
+	if err := utils.CheckOnline(pageURL); err != nil {
+		return "", err
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("build request for %s: %w", pageURL, err)
@@ -178,21 +121,14 @@ func scrapeJSONURL(ctx context.Context, pageURL string) (string, error) {
 
 	fmt.Fprintln(os.Stderr, "Resolving Azure ServiceTags JSON URL from:", utils.SanitizeURL(pageURL))
 
-	client, err := utils.NewHTTPClient()
+	resp, err := utils.DoRequest(ctx, req)
 	if err != nil {
-		return "", err
-	}
-	started := time.Now()
-	resp, err := client.Do(req)
-	if err != nil {
-		utils.Debugf("http: GET %s failed after %s", utils.SanitizeURL(pageURL), time.Since(started).Round(time.Millisecond))
 		return "", fmt.Errorf("fetch %s: %w", pageURL, err)
 	}
 	defer resp.Body.Close()
-	utils.Debugf("http: GET %s returned %d after %s", utils.SanitizeURL(pageURL), resp.StatusCode, time.Since(started).Round(time.Millisecond))
 
 	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("unexpected status %d from %s", resp.StatusCode, pageURL)
+		return "", utils.StatusError(resp, pageURL)
 	}
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAzurePageBytes+1))
@@ -232,11 +168,13 @@ func separateFilters(filterFlagValues string) []string {
 	return filterSlice
 }
 
-func filtersFromComposite(filter string) Filters {
+// filtersFromComposite maps the positional --filter syntax "region,service"
+// to record filters.
+func filtersFromComposite(filter string) map[string][]string {
 	parts := separateFilters(filter)
-	return Filters{
-		Region:  wildcardToEmpty(parts[0]),
-		Service: wildcardToEmpty(parts[1]),
+	return map[string][]string{
+		"region":  wildcardToEmpty(parts[0]),
+		"service": wildcardToEmpty(parts[1]),
 	}
 }
 
@@ -247,80 +185,110 @@ func wildcardToEmpty(value string) []string {
 	return []string{value}
 }
 
-func filtrateIPRanges(raw, ipType string, filters Filters) ([]Prefix, error) {
-	var data rawData
-	if err := json.Unmarshal([]byte(raw), &data); err != nil {
-		return nil, fmt.Errorf("parse azure service-tags json: %w", err)
+func parseIPRanges(raw string) (Result, error) {
+	var prefixes []Prefix
+	result, err := scanServiceTags(raw, func(p Prefix) error {
+		prefixes = append(prefixes, p)
+		return nil
+	})
+	if err != nil {
+		return Result{}, err
 	}
+	result.Prefixes = prefixes
+	return result, nil
+}
 
-	totalPrefixes := 0
-	for _, v := range data.Values {
+// scanServiceTags decodes a ServiceTags document one service at a time and
+// passes each prefix to yield before decoding the next service, so the
+// document's values are never held at once. It returns the document's
+// metadata without Prefixes. An error from yield is returned as is.
+func scanServiceTags(raw string, yield func(Prefix) error) (Result, error) {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	var result Result
+	total := 0
+	yieldValue := func(v value) error {
 		for i, addr := range v.Properties.AddressPrefixes {
-			totalPrefixes++
 			if !utils.IsCIDR(addr) {
-				return nil, fmt.Errorf("validate azure service %q prefix %d: %q is not a valid CIDR", v.Name, i+1, addr)
+				return fmt.Errorf("validate azure service %q prefix %d: %q is not a valid CIDR", v.Name, i+1, addr)
+			}
+			total++
+			if err := yield(Prefix{Address: addr, Region: v.Properties.Region, Service: v.Properties.SystemService}); err != nil {
+				return err
 			}
 		}
-	}
-	if totalPrefixes == 0 {
-		return nil, fmt.Errorf("validate azure service-tags json: no IP ranges found")
+		return nil
 	}
 
-	var result []Prefix
-	for _, v := range data.Values {
-		if !matchesFilter(v.Properties, filters) {
-			continue
+	if err := expectDelim(dec, '{'); err != nil {
+		return Result{}, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return Result{}, fmt.Errorf("parse azure service-tags json: %w", err)
 		}
-		for _, addr := range v.Properties.AddressPrefixes {
-			if !ipVersionMatches(addr, ipType) {
-				continue
-			}
-			result = append(result, Prefix{
-				Address: addr,
-				Region:  v.Properties.Region,
-				Service: v.Properties.SystemService,
-			})
+		switch key {
+		case "cloud":
+			err = decodeField(dec, &result.Cloud)
+		case "changeNumber":
+			err = decodeField(dec, &result.ChangeNumber)
+		case "values":
+			err = scanValues(dec, yieldValue)
+		default:
+			err = decodeField(dec, new(json.RawMessage))
+		}
+		if err != nil {
+			return Result{}, err
 		}
 	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return Result{}, err
+	}
+	if total == 0 {
+		return Result{}, fmt.Errorf("validate azure service-tags json: no IP ranges found")
+	}
 	return result, nil
 }
 
-func matchesFilter(p properties, filters Filters) bool {
-	return (len(filters.Region) == 0 || utils.ContainsIgnoreCase(filters.Region, p.Region)) &&
-		(len(filters.Service) == 0 || utils.ContainsIgnoreCase(filters.Service, p.SystemService))
-}
-
-func ipVersionMatches(addr, ipType string) bool {
-	switch ipType {
-	case "ipv4":
-		return utils.IsIPv4(addr)
-	case "ipv6":
-		return utils.IsIPv6(addr)
+// scanValues decodes the "values" array element by element. A null array
+// holds no values.
+func scanValues(dec *json.Decoder, yield func(value) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("parse azure service-tags json: %w", err)
 	}
-	return true
-}
-
-func printIPRanges(prefixes []Prefix, verbosity string) {
-	if len(prefixes) == 0 {
-		fmt.Println("No IP ranges to display.")
-		return
+	if tok == nil {
+		return nil
 	}
-
-	var printFunc func(Prefix)
-	switch verbosity {
-	case "mini":
-		printFunc = func(p Prefix) {
-			fmt.Printf("%s,%s,%s\n", p.Address, p.Region, p.Service)
+	if tok != json.Delim('[') {
+		return fmt.Errorf("parse azure service-tags json: values is %v, not an array", tok)
+	}
+	for dec.More() {
+		var v value
+		if err := decodeField(dec, &v); err != nil {
+			return err
 		}
-	case "full":
-		printFunc = func(p Prefix) {
-			fmt.Printf("IP Prefix: %s, Region: %s, Service: %s\n", p.Address, p.Region, p.Service)
+		if err := yield(v); err != nil {
+			return err
 		}
-	default:
-		printFunc = func(p Prefix) { fmt.Println(p.Address) }
 	}
+	return expectDelim(dec, ']')
+}
 
-	for _, p := range prefixes {
-		printFunc(p)
+func decodeField(dec *json.Decoder, v any) error {
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("parse azure service-tags json: %w", err)
 	}
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("parse azure service-tags json: %w", err)
+	}
+	if tok != want {
+		return fmt.Errorf("parse azure service-tags json: found %v, expected %v", tok, want)
+	}
+	return nil
 }
@@ -3,13 +3,14 @@ package azure
 import (
 	"bytes"
 	"context"
-	"io"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/kaumnen/cipr/internal/provider"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -44,19 +45,32 @@ func TestSeparateFilters(t *testing.T) {
 	}
 }
 
-func TestFiltrateIPRanges(t *testing.T) {
+// selectPrefixes parses raw and returns the prefixes of the records q
+// selects.
+func selectPrefixes(t *testing.T, raw string, q provider.Query) []Prefix {
+	t.Helper()
+	result, err := Provider{}.Parse(raw)
+	require.NoError(t, err)
+	var prefixes []Prefix
+	for _, rec := range q.Select(result.Records) {
+		prefixes = append(prefixes, Prefix{Address: rec.Prefix.String(), Region: rec.Region, Service: rec.Service})
+	}
+	return prefixes
+}
+
+func TestSelectIPRanges(t *testing.T) {
 	raw := loadFixture(t)
 
 	cases := []struct {
 		name     string
 		ipType   string
-		filters  Filters
+		filters  map[string][]string
 		expected []Prefix
 	}{
 		{
 			name:    "ipv4 no filter",
 			ipType:  "ipv4",
-			filters: Filters{},
+			filters: nil,
 			expected: []Prefix{
 				{Address: "13.66.143.220/30", Region: "", Service: "ActionGroup"},
 				{Address: "20.50.32.0/19", Region: "westeurope", Service: "AzureStorage"},
@@ -67,7 +81,7 @@ func TestFiltrateIPRanges(t *testing.T) {
 		{
 			name:    "ipv6 no filter",
 			ipType:  "ipv6",
-			filters: Filters{},
+			filters: nil,
 			expected: []Prefix{
 				{Address: "2603:1000:4::10c/126", Region: "", Service: "ActionGroup"},
 				{Address: "2603:1020:206::/48", Region: "westeurope", Service: "AzureStorage"},
@@ -76,71 +90,72 @@ func TestFiltrateIPRanges(t *testing.T) {
 		{
 			name:     "filter by region",
 			ipType:   "ipv4",
-			filters:  Filters{Region: []string{"westeurope"}},
+			filters:  map[string][]string{"region": {"westeurope"}},
 			expected: []Prefix{{Address: "20.50.32.0/19", Region: "westeurope", Service: "AzureStorage"}},
 		},
 		{
 			name:     "filter by service",
 			ipType:   "ipv4",
-			filters:  Filters{Service: []string{"ActionGroup"}},
+			filters:  map[string][]string{"service": {"ActionGroup"}},
 			expected: []Prefix{{Address: "13.66.143.220/30", Region: "", Service: "ActionGroup"}},
 		},
 		{
 			name:     "filter by region and service combined",
 			ipType:   "ipv6",
-			filters:  Filters{Region: []string{"westeurope"}, Service: []string{"AzureStorage"}},
+			filters:  map[string][]string{"region": {"westeurope"}, "service": {"AzureStorage"}},
 			expected: []Prefix{{Address: "2603:1020:206::/48", Region: "westeurope", Service: "AzureStorage"}},
 		},
 		{
 			name:     "filter case-insensitive",
 			ipType:   "ipv4",
-			filters:  Filters{Region: []string{"WESTEUROPE"}, Service: []string{"azurestorage"}},
+			filters:  map[string][]string{"region": {"WESTEUROPE"}, "service": {"azurestorage"}},
 			expected: []Prefix{{Address: "20.50.32.0/19", Region: "westeurope", Service: "AzureStorage"}},
 		},
 		{
 			name:     "no matches",
 			ipType:   "ipv4",
-			filters:  Filters{Region: []string{"southpole"}},
+			filters:  map[string][]string{"region": {"southpole"}},
 			expected: nil,
 		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			got, err := filtrateIPRanges(raw, tc.ipType, tc.filters)
-			assert.NoError(t, err)
+			got := selectPrefixes(t, raw, provider.Query{IPType: tc.ipType, Filters: tc.filters})
 			assert.Equal(t, tc.expected, got)
 		})
 	}
 }
 
-func TestFiltrateIPRangesInvalidJSON(t *testing.T) {
-	_, err := filtrateIPRanges("not json", "ipv4", Filters{})
+func TestParseIPRangesInvalidJSON(t *testing.T) {
+	_, err := parseIPRanges("not json")
 	assert.Error(t, err)
 }
 
-func TestFiltrateIPRangesValidation(t *testing.T) {
+func TestParseIPRangesValidation(t *testing.T) {
 	t.Run("empty payload", func(t *testing.T) {
-		_, err := filtrateIPRanges(`{}`, "ipv4", Filters{})
+		_, err := parseIPRanges(`{}`)
 		assert.ErrorContains(t, err, "no IP ranges found")
 	})
 
 	t.Run("invalid CIDR", func(t *testing.T) {
 		raw := `{"values":[{"name":"BadService","properties":{"addressPrefixes":["not-a-cidr"]}}]}`
-		_, err := filtrateIPRanges(raw, "ipv4", Filters{})
+		_, err := parseIPRanges(raw)
 		assert.ErrorContains(t, err, `service "BadService" prefix 1`)
 	})
 }
 
-func TestFiltrateIPRangesMultipleValues(t *testing.T) {
-	raw := loadFixture(t)
+func TestSelectIPRangesComposite(t *testing.T) {
+	got := selectPrefixes(t, loadFixture(t), provider.Query{IPType: "ipv4", Filters: filtersFromComposite("WESTEUROPE,azurestorage")})
+	assert.Equal(t, []Prefix{{Address: "20.50.32.0/19", Region: "westeurope", Service: "AzureStorage"}}, got)
+}
 
-	got, err := filtrateIPRanges(raw, "ipv4", Filters{
-		Region:  []string{"eastus", "WESTEUROPE"},
-		Service: []string{"azurestorage", "MissingService"},
-	})
+func TestParseIPRangesKeepsMetadata(t *testing.T) {
+	raw := `{"cloud":"Public","changeNumber":42,"values":[{"name":"X","properties":{"addressPrefixes":["192.0.2.0/24"]}}]}`
+	got, err := parseIPRanges(raw)
 	require.NoError(t, err)
-	assert.Equal(t, []Prefix{{Address: "20.50.32.0/19", Region: "westeurope", Service: "AzureStorage"}}, got)
+	assert.Equal(t, "Public", got.Cloud)
+	assert.Equal(t, 42, got.ChangeNumber)
 }
 
 func TestJSONURLRegex(t *testing.T) {
@@ -204,128 +219,15 @@ func TestScrapeMissError(t *testing.T) {
 	}
 }
 
-func TestPrintIPRanges(t *testing.T) {
-	cases := []struct {
-		name      string
-		prefixes  []Prefix
-		verbosity string
-		want      string
-	}{
-		{
-			name:      "empty",
-			prefixes:  []Prefix{},
-			verbosity: "none",
-			want:      "No IP ranges to display.\n",
-		},
-		{
-			name: "none verbosity",
-			prefixes: []Prefix{
-				{Address: "20.50.32.0/19", Region: "westeurope", Service: "AzureStorage"},
-			},
-			verbosity: "none",
-			want:      "20.50.32.0/19\n",
-		},
-		{
-			name: "mini verbosity",
-			prefixes: []Prefix{
-				{Address: "20.50.32.0/19", Region: "westeurope", Service: "AzureStorage"},
-			},
-			verbosity: "mini",
-			want:      "20.50.32.0/19,westeurope,AzureStorage\n",
-		},
-		{
-			name: "full verbosity",
-			prefixes: []Prefix{
-				{Address: "2603:1020:206::/48", Region: "westeurope", Service: "AzureStorage"},
-			},
-			verbosity: "full",
-			want:      "IP Prefix: 2603:1020:206::/48, Region: westeurope, Service: AzureStorage\n",
-		},
-		{
-			name: "unknown verbosity falls back to addresses only",
-			prefixes: []Prefix{
-				{Address: "13.66.143.220/30", Region: "", Service: "ActionGroup"},
-			},
-			verbosity: "bogus",
-			want:      "13.66.143.220/30\n",
-		},
-	}
-	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			oldStdout := os.Stdout
-			r, w, _ := os.Pipe()
-			os.Stdout = w
-
-			printIPRanges(tc.prefixes, tc.verbosity)
-
-			w.Close()
-			os.Stdout = oldStdout
-
-			var buf bytes.Buffer
-			io.Copy(&buf, r)
-			assert.Equal(t, tc.want, buf.String())
-		})
-	}
-}
-
-func captureStdout(t *testing.T, fn func()) string {
-	t.Helper()
-	oldStdout := os.Stdout
-	r, w, err := os.Pipe()
-	require.NoError(t, err)
-	os.Stdout = w
-
-	fn()
-
-	w.Close()
-	os.Stdout = oldStdout
-
-	var buf bytes.Buffer
-	_, _ = io.Copy(&buf, r)
-	return buf.String()
-}
-
-func TestPrintListedValues(t *testing.T) {
-	prefixes := []Prefix{
-		{Address: "13.66.143.220/30", Region: "", Service: "ActionGroup"},
-		{Address: "20.50.32.0/19", Region: "westeurope", Service: "AzureStorage"},
-		{Address: "20.42.0.0/15", Region: "eastus", Service: ""},
-		{Address: "2603:1020:206::/48", Region: "westeurope", Service: "AzureStorage"},
-	}
-
-	t.Run("regions drops empty and dedupes", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(prefixes, "regions"))
-		})
-		assert.Equal(t, "eastus\nwesteurope\n", out)
-	})
-
-	t.Run("services drops empty and dedupes", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(prefixes, "services"))
-		})
-		assert.Equal(t, "ActionGroup\nAzureStorage\n", out)
-	})
-
-	t.Run("unknown dim returns error", func(t *testing.T) {
-		err := printListedValues(prefixes, "bogus")
-		require.Error(t, err)
-		assert.Contains(t, err.Error(), "unknown list dimension")
-	})
-}
-
-func TestGetIPRanges_List(t *testing.T) {
-	cfg := Config{
-		Source:    filepath.Join("..", "testdata", "azure_servicetags_sample.json"),
-		IPType:    "",
-		List:      "services",
-		Verbosity: "none",
-	}
-
-	out := captureStdout(t, func() {
-		require.NoError(t, GetIPRanges(context.Background(), cfg))
+func TestProviderListServices(t *testing.T) {
+	p := Provider{}
+	result, err := p.Load(context.Background(), provider.Query{
+		Source: filepath.Join("..", "testdata", "azure_servicetags_sample.json"),
+		IPType: "both",
 	})
-	assert.Equal(t, "ActionGroup\nAzureStorage\n", out)
+	require.NoError(t, err)
+	values := provider.ListValues(result.Records, p.ListDimensions()[1])
+	assert.Equal(t, []string{"ActionGroup", "AzureStorage"}, values)
 }
 
 // Regression: azure's two-stage hosted fetch (HTML scrape -> JSON download)
@@ -401,3 +303,50 @@ func TestScrapeJSONURLUsesConfiguredProxy(t *testing.T) {
 	assert.Equal(t, want, got)
 	assert.Equal(t, 1, hits)
 }
+
+func TestFetchRawData_Offline(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer srv.Close()
+	viper.Set("azure_endpoint", srv.URL+"/download/details.aspx?id=56519")
+	viper.Set("offline", true)
+
+	_, err := fetchRawData(context.Background(), "azure")
+	assert.ErrorContains(t, err, "cipr configure azure --local-file")
+
+	_, err = fetchRawData(context.Background(), srv.URL+"/download/details.aspx?id=56519")
+	assert.ErrorContains(t, err, "offline mode: refusing to fetch")
+	assert.Zero(t, hits)
+}
+
+func TestProviderStream(t *testing.T) {
+	p := Provider{}
+	q := provider.Query{Source: filepath.Join("..", "testdata", "azure_servicetags_sample.json"), IPType: "both"}
+	want, err := p.Load(context.Background(), q)
+	require.NoError(t, err)
+
+	var got []provider.Record
+	metadata, err := p.Stream(context.Background(), q, func(rec provider.Record) error {
+		got = append(got, rec)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, want.Records, got)
+	assert.Equal(t, want.Metadata, metadata)
+
+	t.Run("yield error stops decoding", func(t *testing.T) {
+		stop := errors.New("stop")
+		calls := 0
+		_, err := p.Stream(context.Background(), q, func(provider.Record) error {
+			calls++
+			return stop
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,103 @@
+package azure
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/kaumnen/cipr/internal/provider"
+)
+
+const defaultEndpoint = "https://www.microsoft.com/en-us/download/details.aspx?id=56519"
+
+func init() {
+	provider.Register(Provider{})
+}
+
+// Provider exposes the Azure Public cloud service tags through the provider
+// registry.
+type Provider struct{}
+
+func (Provider) Name() string  { return "azure" }
+func (Provider) Short() string { return "Get Azure IP ranges." }
+func (Provider) Long() string {
+	return `Get Azure IPv4 and IPv6 ranges from the Public cloud service tags, with optional filtering.`
+}
+
+func (Provider) ConfigKeys() []string { return []string{"azure"} }
+
+func (Provider) DefaultEndpoints() map[string]string {
+	return map[string]string{"azure": defaultEndpoint}
+}
+
+func (Provider) Columns() []provider.Column {
+	return []provider.Column{
+		{Key: provider.PrefixKey, Label: "IP Prefix"},
+		{Key: "region", Label: "Region"},
+		{Key: "service", Label: "Service"},
+	}
+}
+
+func (Provider) FilterDimensions() []provider.Dimension {
+	return []provider.Dimension{
+		{Key: "region", Plural: "regions", Usage: "Filter results by Azure region (comma-separated, e.g. westeurope,eastus)"},
+		{Key: "service", Plural: "services", Usage: "Filter results by Azure system service (comma-separated, e.g. AzureStorage,AzureKeyVault)"},
+	}
+}
+
+func (p Provider) ListDimensions() []provider.Dimension { return p.FilterDimensions() }
+
+func (Provider) FiltersFromComposite(filter string) map[string][]string {
+	return filtersFromComposite(filter)
+}
+
+func (Provider) Parse(raw string) (provider.Result, error) {
+	var out provider.Result
+	metadata, err := decode(raw, func(rec provider.Record) error {
+		out.Records = append(out.Records, rec)
+		return nil
+	})
+	if err != nil {
+		return provider.Result{}, err
+	}
+	out.Metadata = metadata
+	return out, nil
+}
+
+func (p Provider) Load(ctx context.Context, q provider.Query) (provider.Result, error) {
+	raw, err := fetchRawData(ctx, provider.SourceFor(q.Source, "azure"))
+	if err != nil {
+		return provider.Result{}, err
+	}
+	return p.Parse(raw)
+}
+
+// Stream yields the records of the ServiceTags document one service at a
+// time.
+func (Provider) Stream(ctx context.Context, q provider.Query, yield func(provider.Record) error) (map[string]string, error) {
+	raw, err := fetchRawData(ctx, provider.SourceFor(q.Source, "azure"))
+	if err != nil {
+		return nil, err
+	}
+	return decode(raw, yield)
+}
+
+// decode turns each prefix of raw into a record for yield and returns the
+// document's metadata.
+func decode(raw string, yield func(provider.Record) error) (map[string]string, error) {
+	result, err := scanServiceTags(raw, func(p Prefix) error {
+		rec, err := provider.NewRecord("azure", p.Address)
+		if err != nil {
+			return err
+		}
+		rec.Region = p.Region
+		rec.Service = p.Service
+		return yield(rec)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"cloud":         result.Cloud,
+		"change_number": strconv.Itoa(result.ChangeNumber),
+	}, nil
+}
@@ -0,0 +1,80 @@
+// Package cidr implements set operations on IP prefixes: summarization into
+// a minimal covering set and subtraction.
+package cidr
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// Aggregate is one prefix of a summarized set together with the number of
+// input prefixes it absorbed.
+type Aggregate struct {
+	Prefix netip.Prefix
+	Count  int
+}
+
+// Summarize returns the minimal set of prefixes covering exactly the
+// addresses of in. Identical prefixes are deduplicated, prefixes inside a
+// larger one are dropped, and adjacent siblings are merged into their parent
+// repeatedly. Host bits are masked off. The result is sorted with IPv4 before
+// IPv6; invalid prefixes are ignored.
+func Summarize(in []netip.Prefix) []Aggregate {
+	prefixes := make([]netip.Prefix, 0, len(in))
+	for _, p := range in {
+		if p.IsValid() {
+			prefixes = append(prefixes, p.Masked())
+		}
+	}
+	slices.SortFunc(prefixes, ComparePrefixes)
+
+	out := make([]Aggregate, 0, len(prefixes))
+	for _, p := range prefixes {
+		if n := len(out); n > 0 && covers(out[n-1].Prefix, p) {
+			out[n-1].Count++
+			continue
+		}
+		out = append(out, Aggregate{Prefix: p, Count: 1})
+		for len(out) >= 2 {
+			a, b := out[len(out)-2], out[len(out)-1]
+			parent, ok := mergeSiblings(a.Prefix, b.Prefix)
+			if !ok {
+				break
+			}
+			out = out[:len(out)-2]
+			out = append(out, Aggregate{Prefix: parent, Count: a.Count + b.Count})
+		}
+	}
+	return out
+}
+
+// ComparePrefixes orders IPv4 before IPv6, then by address, then shorter
+// prefixes first, so a covering prefix always precedes the prefixes it
+// contains.
+func ComparePrefixes(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
+
+// covers reports whether outer contains every address of inner.
+func covers(outer, inner netip.Prefix) bool {
+	return outer.Addr().BitLen() == inner.Addr().BitLen() &&
+		outer.Bits() <= inner.Bits() &&
+		outer.Contains(inner.Addr())
+}
+
+// mergeSiblings returns the parent of a and b when they are the two halves
+// of it, with a the lower half.
+func mergeSiblings(a, b netip.Prefix) (netip.Prefix, bool) {
+	if a.Bits() != b.Bits() || a.Bits() == 0 || a == b ||
+		a.Addr().BitLen() != b.Addr().BitLen() {
+		return netip.Prefix{}, false
+	}
+	parent := netip.PrefixFrom(a.Addr(), a.Bits()-1).Masked()
+	if parent.Addr() != a.Addr() || !parent.Contains(b.Addr()) {
+		return netip.Prefix{}, false
+	}
+	return parent, true
+}
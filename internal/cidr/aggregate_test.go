@@ -0,0 +1,138 @@
+package cidr
+
+import (
+	"math/rand/v2"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func prefixes(ss ...string) []netip.Prefix {
+	out := make([]netip.Prefix, 0, len(ss))
+	for _, s := range ss {
+		out = append(out, netip.MustParsePrefix(s))
+	}
+	return out
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []netip.Prefix
+		want []Aggregate
+	}{
+		{name: "empty", in: nil, want: []Aggregate{}},
+		{
+			name: "duplicates",
+			in:   prefixes("3.5.140.0/22", "3.5.140.0/22"),
+			want: []Aggregate{{netip.MustParsePrefix("3.5.140.0/22"), 2}},
+		},
+		{
+			name: "covered prefix dropped",
+			in:   prefixes("10.1.2.0/24", "10.0.0.0/8", "10.200.0.0/16"),
+			want: []Aggregate{{netip.MustParsePrefix("10.0.0.0/8"), 3}},
+		},
+		{
+			name: "adjacent siblings merge repeatedly",
+			in:   prefixes("192.0.2.0/26", "192.0.2.64/26", "192.0.2.128/25"),
+			want: []Aggregate{{netip.MustParsePrefix("192.0.2.0/24"), 3}},
+		},
+		{
+			name: "adjacent but not siblings stay apart",
+			in:   prefixes("192.0.2.64/26", "192.0.2.128/26"),
+			want: []Aggregate{
+				{netip.MustParsePrefix("192.0.2.64/26"), 1},
+				{netip.MustParsePrefix("192.0.2.128/26"), 1},
+			},
+		},
+		{
+			name: "host bits masked",
+			in:   prefixes("192.0.2.1/24", "192.0.2.0/24"),
+			want: []Aggregate{{netip.MustParsePrefix("192.0.2.0/24"), 2}},
+		},
+		{
+			name: "families kept apart and ordered",
+			in:   prefixes("2001:db8::/33", "0.0.0.0/1", "2001:db8:8000::/33", "128.0.0.0/1"),
+			want: []Aggregate{
+				{netip.MustParsePrefix("0.0.0.0/0"), 2},
+				{netip.MustParsePrefix("2001:db8::/32"), 2},
+			},
+		},
+		{
+			name: "ipv4 zero prefix does not merge with ipv6",
+			in:   prefixes("0.0.0.0/0", "::/0"),
+			want: []Aggregate{
+				{netip.MustParsePrefix("0.0.0.0/0"), 1},
+				{netip.MustParsePrefix("::/0"), 1},
+			},
+		},
+		{
+			name: "merge after covered prefix",
+			in:   prefixes("10.0.0.0/25", "10.0.0.0/26", "10.0.0.128/25"),
+			want: []Aggregate{{netip.MustParsePrefix("10.0.0.0/24"), 3}},
+		},
+		{
+			name: "invalid ignored",
+			in:   []netip.Prefix{{}, netip.MustParsePrefix("10.0.0.0/8")},
+			want: []Aggregate{{netip.MustParsePrefix("10.0.0.0/8"), 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Summarize(tt.in))
+		})
+	}
+}
+
+func TestSummarizePreservesCoverage(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	base := netip.MustParseAddr("10.0.0.0").As4()
+	for round := 0; round < 20; round++ {
+		var in []netip.Prefix
+		for i := 0; i < 50; i++ {
+			addr := base
+			addr[2] = byte(rng.IntN(16))
+			addr[3] = byte(rng.IntN(256))
+			in = append(in, netip.PrefixFrom(netip.AddrFrom4(addr), 20+rng.IntN(13)))
+		}
+		out := Summarize(in)
+
+		total := 0
+		for i, agg := range out {
+			total += agg.Count
+			if i > 0 {
+				assert.False(t, out[i-1].Prefix.Overlaps(agg.Prefix), "%s overlaps %s", out[i-1].Prefix, agg.Prefix)
+				_, mergeable := mergeSiblings(out[i-1].Prefix, agg.Prefix)
+				assert.False(t, mergeable, "%s and %s should have merged", out[i-1].Prefix, agg.Prefix)
+			}
+		}
+		assert.Equal(t, len(in), total)
+
+		for n := 0; n < 1<<12; n++ {
+			addr := base
+			addr[2] = byte(n >> 8 & 0x0f)
+			addr[3] = byte(n)
+			ip := netip.AddrFrom4(addr)
+			assert.Equal(t, containsAddr(in, ip), containsAddr(prefixesOf(out), ip), ip.String())
+		}
+	}
+}
+
+func containsAddr(set []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range set {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func prefixesOf(aggs []Aggregate) []netip.Prefix {
+	out := make([]netip.Prefix, 0, len(aggs))
+	for _, a := range aggs {
+		out = append(out, a.Prefix)
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package cidr
+
+import "net/netip"
+
+// Subtract returns the prefixes covering exactly the addresses of p that are
+// not in any prefix of remove. p is split into halves as often as needed, so
+// the result is the smallest set of CIDR blocks for the difference, in
+// address order. It returns nil when remove covers p.
+func Subtract(p netip.Prefix, remove []netip.Prefix) []netip.Prefix {
+	if !p.IsValid() {
+		return nil
+	}
+	p = p.Masked()
+	return subtract(p, overlapping(p, remove), nil)
+}
+
+func subtract(p netip.Prefix, remove []netip.Prefix, out []netip.Prefix) []netip.Prefix {
+	if len(remove) == 0 {
+		return append(out, p)
+	}
+	for _, r := range remove {
+		if covers(r, p) {
+			return out
+		}
+	}
+	// Some removed prefix lies strictly inside p, so p has a longer prefix
+	// length than Bits() and can be split.
+	lo := netip.PrefixFrom(p.Addr(), p.Bits()+1)
+	hi := netip.PrefixFrom(lastAddr(lo).Next(), p.Bits()+1)
+	out = subtract(lo, overlapping(lo, remove), out)
+	return subtract(hi, overlapping(hi, remove), out)
+}
+
+func overlapping(p netip.Prefix, prefixes []netip.Prefix) []netip.Prefix {
+	var out []netip.Prefix
+	for _, q := range prefixes {
+		if q.IsValid() && q.Overlaps(p) {
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// lastAddr returns the highest address of p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	a := p.Masked().Addr().As16()
+	hostBits := p.Addr().BitLen() - p.Bits()
+	for i := 15; hostBits > 0; i-- {
+		n := min(hostBits, 8)
+		a[i] |= byte(1<<n - 1)
+		hostBits -= n
+	}
+	addr := netip.AddrFrom16(a)
+	if p.Addr().Is4() {
+		return addr.Unmap()
+	}
+	return addr
+}
@@ -0,0 +1,74 @@
+package cidr
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubtract(t *testing.T) {
+	tests := []struct {
+		name   string
+		p      string
+		remove []netip.Prefix
+		want   []netip.Prefix
+	}{
+		{name: "nothing removed", p: "10.0.0.0/24", want: prefixes("10.0.0.0/24")},
+		{name: "disjoint removal", p: "10.0.0.0/24", remove: prefixes("10.0.1.0/24"), want: prefixes("10.0.0.0/24")},
+		{name: "fully covered", p: "10.0.0.0/24", remove: prefixes("10.0.0.0/16"), want: nil},
+		{name: "identical", p: "10.0.0.0/24", remove: prefixes("10.0.0.0/24"), want: nil},
+		{
+			name:   "carve a hole",
+			p:      "10.0.0.0/24",
+			remove: prefixes("10.0.0.64/26"),
+			want:   prefixes("10.0.0.0/26", "10.0.0.128/25"),
+		},
+		{
+			name:   "single address",
+			p:      "192.0.2.0/30",
+			remove: prefixes("192.0.2.2/32"),
+			want:   prefixes("192.0.2.0/31", "192.0.2.3/32"),
+		},
+		{
+			name:   "several holes",
+			p:      "10.0.0.0/24",
+			remove: prefixes("10.0.0.0/26", "10.0.0.192/26"),
+			want:   prefixes("10.0.0.64/26", "10.0.0.128/26"),
+		},
+		{
+			name:   "ipv6",
+			p:      "2001:db8::/32",
+			remove: prefixes("2001:db8::/33", "10.0.0.0/8"),
+			want:   prefixes("2001:db8:8000::/33"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Subtract(netip.MustParsePrefix(tt.p), tt.remove))
+		})
+	}
+}
+
+func TestSubtractCoversExactDifference(t *testing.T) {
+	p := netip.MustParsePrefix("10.0.0.0/20")
+	remove := prefixes("10.0.1.7/32", "10.0.4.0/23", "10.0.15.128/25", "9.0.0.0/8", "10.0.8.0/30")
+	out := Subtract(p, remove)
+
+	for n := 0; n < 1<<12; n++ {
+		ip := netip.AddrFrom4([4]byte{10, 0, byte(n >> 8), byte(n)})
+		assert.Equal(t, !containsAddr(remove, ip), containsAddr(out, ip), ip.String())
+	}
+	for i := 1; i < len(out); i++ {
+		assert.False(t, out[i-1].Overlaps(out[i]))
+	}
+}
+
+func TestLastAddr(t *testing.T) {
+	assert.Equal(t, "10.0.0.255", lastAddr(netip.MustParsePrefix("10.0.0.0/24")).String())
+	assert.Equal(t, "10.0.0.0", lastAddr(netip.MustParsePrefix("10.0.0.0/32")).String())
+	assert.Equal(t, "255.255.255.255", lastAddr(netip.MustParsePrefix("0.0.0.0/0")).String())
+	assert.Equal(t, "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", lastAddr(netip.MustParsePrefix("2001:db8::/32")).String())
+	assert.Equal(t, "10.0.0.7", lastAddr(netip.MustParsePrefix("10.0.0.3/29")).String())
+}
@@ -0,0 +1,155 @@
+package cidr
+
+import (
+	"iter"
+	"math/bits"
+	"net/netip"
+)
+
+// Trie is a path-compressed binary radix tree mapping prefixes to values,
+// with separate roots for IPv4 and IPv6. Several values may be stored under
+// the same prefix (for example one per service publishing it). The zero
+// value is an empty trie ready to use; it is not safe for concurrent
+// writes.
+type Trie[V any] struct {
+	roots [2]*trieNode[V]
+	size  int
+}
+
+type trieNode[V any] struct {
+	prefix netip.Prefix
+	// set distinguishes prefixes that were inserted from the branching
+	// nodes created to join two subtrees.
+	set    bool
+	values []V
+	child  [2]*trieNode[V]
+}
+
+// Insert adds v under p. Host bits of p are masked off; invalid prefixes
+// are ignored.
+func (t *Trie[V]) Insert(p netip.Prefix, v V) {
+	if !p.IsValid() {
+		return
+	}
+	p = p.Masked()
+	link := &t.roots[familyIndex(p.Addr())]
+	for {
+		n := *link
+		if n == nil {
+			*link = &trieNode[V]{prefix: p, set: true, values: []V{v}}
+			t.size++
+			return
+		}
+
+		common := commonBits(n.prefix.Addr(), p.Addr(), min(n.prefix.Bits(), p.Bits()))
+		switch {
+		case common == n.prefix.Bits() && common == p.Bits():
+			if !n.set {
+				n.set = true
+				t.size++
+			}
+			n.values = append(n.values, v)
+			return
+		case common == n.prefix.Bits():
+			// p lies below n.
+			link = &n.child[bitAt(p.Addr(), common)]
+		case common == p.Bits():
+			// p covers n: p takes n's place with n as its child.
+			leaf := &trieNode[V]{prefix: p, set: true, values: []V{v}}
+			leaf.child[bitAt(n.prefix.Addr(), common)] = n
+			*link = leaf
+			t.size++
+			return
+		default:
+			// p and n diverge: join them under their common prefix.
+			branch := &trieNode[V]{prefix: netip.PrefixFrom(p.Addr(), common).Masked()}
+			branch.child[bitAt(n.prefix.Addr(), common)] = n
+			branch.child[bitAt(p.Addr(), common)] = &trieNode[V]{prefix: p, set: true, values: []V{v}}
+			*link = branch
+			t.size++
+			return
+		}
+	}
+}
+
+// Len returns the number of distinct prefixes in the trie.
+func (t *Trie[V]) Len() int { return t.size }
+
+// Contains reports whether any prefix in the trie contains addr.
+func (t *Trie[V]) Contains(addr netip.Addr) bool {
+	_, _, ok := t.Lookup(addr)
+	return ok
+}
+
+// Lookup returns the longest prefix containing addr and its values.
+func (t *Trie[V]) Lookup(addr netip.Addr) (netip.Prefix, []V, bool) {
+	var best *trieNode[V]
+	t.walk(addr, func(n *trieNode[V]) { best = n })
+	if best == nil {
+		return netip.Prefix{}, nil, false
+	}
+	return best.prefix, best.values, true
+}
+
+// Matches yields every prefix containing addr with its values, most
+// specific first.
+func (t *Trie[V]) Matches(addr netip.Addr) iter.Seq2[netip.Prefix, []V] {
+	return func(yield func(netip.Prefix, []V) bool) {
+		var path []*trieNode[V]
+		t.walk(addr, func(n *trieNode[V]) { path = append(path, n) })
+		for i := len(path) - 1; i >= 0; i-- {
+			if !yield(path[i].prefix, path[i].values) {
+				return
+			}
+		}
+	}
+}
+
+// walk calls fn for each inserted prefix containing addr, shortest first.
+func (t *Trie[V]) walk(addr netip.Addr, fn func(*trieNode[V])) {
+	if !addr.IsValid() {
+		return
+	}
+	addr = addr.Unmap()
+	for n := t.roots[familyIndex(addr)]; n != nil && n.prefix.Contains(addr); {
+		if n.set {
+			fn(n)
+		}
+		if n.prefix.Bits() == addr.BitLen() {
+			return
+		}
+		n = n.child[bitAt(addr, n.prefix.Bits())]
+	}
+}
+
+func familyIndex(a netip.Addr) int {
+	if a.Is4() {
+		return 0
+	}
+	return 1
+}
+
+// bitAt returns bit i of a, counting from the most significant bit.
+func bitAt(a netip.Addr, i int) int {
+	b := a.As16()
+	if a.Is4() {
+		i += 96
+	}
+	return int(b[i/8]>>(7-i%8)) & 1
+}
+
+// commonBits returns the length of the common leading bits of a and b,
+// capped at limit. a and b must be of the same family.
+func commonBits(a, b netip.Addr, limit int) int {
+	x, y := a.As16(), b.As16()
+	offset := 0
+	if a.Is4() {
+		offset = 96
+	}
+	for i := offset / 8; i < 16; i++ {
+		if d := x[i] ^ y[i]; d != 0 {
+			return min(i*8+bits.LeadingZeros8(d)-offset, limit)
+		}
+	}
+	return limit
+}
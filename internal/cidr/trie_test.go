@@ -0,0 +1,185 @@
+package cidr
+
+import (
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrieLookup(t *testing.T) {
+	var trie Trie[string]
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	trie.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	trie.Insert(netip.MustParsePrefix("10.1.2.0/24"), "c")
+	trie.Insert(netip.MustParsePrefix("10.1.2.0/24"), "c2")
+	trie.Insert(netip.MustParsePrefix("10.128.0.0/9"), "d")
+	trie.Insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+	trie.Insert(netip.MustParsePrefix("0.0.0.0/0"), "default")
+	trie.Insert(netip.Prefix{}, "ignored")
+
+	assert.Equal(t, 6, trie.Len())
+
+	tests := []struct {
+		addr   string
+		prefix string
+		values []string
+		ok     bool
+	}{
+		{addr: "10.1.2.3", prefix: "10.1.2.0/24", values: []string{"c", "c2"}, ok: true},
+		{addr: "10.1.3.3", prefix: "10.1.0.0/16", values: []string{"b"}, ok: true},
+		{addr: "10.200.0.1", prefix: "10.128.0.0/9", values: []string{"d"}, ok: true},
+		{addr: "10.2.0.1", prefix: "10.0.0.0/8", values: []string{"a"}, ok: true},
+		{addr: "192.0.2.1", prefix: "0.0.0.0/0", values: []string{"default"}, ok: true},
+		{addr: "::ffff:10.1.2.3", prefix: "10.1.2.0/24", values: []string{"c", "c2"}, ok: true},
+		{addr: "2001:db8:1::1", prefix: "2001:db8::/32", values: []string{"v6"}, ok: true},
+		{addr: "2001:db9::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			prefix, values, ok := trie.Lookup(netip.MustParseAddr(tt.addr))
+			require.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.ok, trie.Contains(netip.MustParseAddr(tt.addr)))
+			if ok {
+				assert.Equal(t, tt.prefix, prefix.String())
+				assert.Equal(t, tt.values, values)
+			}
+		})
+	}
+}
+
+func TestTrieMatchesMostSpecificFirst(t *testing.T) {
+	var trie Trie[int]
+	for i, p := range []string{"10.1.2.0/24", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.128/25", "11.0.0.0/8"} {
+		trie.Insert(netip.MustParsePrefix(p), i)
+	}
+
+	var got []string
+	for p := range trie.Matches(netip.MustParseAddr("10.1.2.3")) {
+		got = append(got, p.String())
+	}
+	assert.Equal(t, []string{"10.1.2.0/24", "10.1.0.0/16", "10.0.0.0/8"}, got)
+
+	got = got[:0]
+	for p := range trie.Matches(netip.MustParseAddr("10.1.2.200")) {
+		got = append(got, p.String())
+		break
+	}
+	assert.Equal(t, []string{"10.1.2.128/25"}, got)
+}
+
+func TestTrieInsertOrderDoesNotMatter(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	var in []netip.Prefix
+	for i := 0; i < 300; i++ {
+		addr := netip.AddrFrom4([4]byte{10, byte(rng.IntN(4)), byte(rng.IntN(256)), byte(rng.IntN(256))})
+		in = append(in, netip.PrefixFrom(addr, 12+rng.IntN(21)).Masked())
+	}
+
+	var forward, backward Trie[netip.Prefix]
+	for _, p := range in {
+		forward.Insert(p, p)
+	}
+	for _, p := range slices.Backward(in) {
+		backward.Insert(p, p)
+	}
+
+	for i := 0; i < 2000; i++ {
+		addr := netip.AddrFrom4([4]byte{10, byte(rng.IntN(4)), byte(rng.IntN(256)), byte(rng.IntN(256))})
+		want := linearMatches(in, addr)
+
+		var got []netip.Prefix
+		for p := range forward.Matches(addr) {
+			got = append(got, p)
+		}
+		assert.Equal(t, want, got, addr.String())
+
+		var gotBackward []netip.Prefix
+		for p := range backward.Matches(addr) {
+			gotBackward = append(gotBackward, p)
+		}
+		assert.Equal(t, want, gotBackward, addr.String())
+	}
+}
+
+// linearMatches is the reference implementation: the distinct prefixes
+// containing addr, longest first.
+func linearMatches(prefixes []netip.Prefix, addr netip.Addr) []netip.Prefix {
+	var out []netip.Prefix
+	for _, p := range prefixes {
+		if p.Contains(addr) && !slices.Contains(out, p) {
+			out = append(out, p)
+		}
+	}
+	slices.SortFunc(out, func(a, b netip.Prefix) int { return b.Bits() - a.Bits() })
+	return out
+}
+
+func benchmarkPrefixes(n int) []netip.Prefix {
+	rng := rand.New(rand.NewPCG(5, 6))
+	out := make([]netip.Prefix, 0, n)
+	for i := 0; i < n; i++ {
+		if i%4 == 0 {
+			var a [16]byte
+			a[0], a[1] = 0x26, 0x00
+			for j := 2; j < 8; j++ {
+				a[j] = byte(rng.IntN(256))
+			}
+			out = append(out, netip.PrefixFrom(netip.AddrFrom16(a), 32+rng.IntN(33)).Masked())
+			continue
+		}
+		addr := netip.AddrFrom4([4]byte{byte(rng.IntN(224)), byte(rng.IntN(256)), byte(rng.IntN(256)), 0})
+		out = append(out, netip.PrefixFrom(addr, 12+rng.IntN(13)).Masked())
+	}
+	return out
+}
+
+func benchmarkAddrs(n int) []netip.Addr {
+	rng := rand.New(rand.NewPCG(7, 8))
+	out := make([]netip.Addr, 0, n)
+	for i := 0; i < n; i++ {
+		out = append(out, netip.AddrFrom4([4]byte{byte(rng.IntN(224)), byte(rng.IntN(256)), byte(rng.IntN(256)), byte(rng.IntN(256))}))
+	}
+	return out
+}
+
+func BenchmarkTrieInsert(b *testing.B) {
+	prefixes := benchmarkPrefixes(100_000)
+	b.ReportAllocs()
+	for b.Loop() {
+		var trie Trie[int]
+		for i, p := range prefixes {
+			trie.Insert(p, i)
+		}
+	}
+}
+
+func BenchmarkTrieContains(b *testing.B) {
+	var trie Trie[int]
+	for i, p := range benchmarkPrefixes(100_000) {
+		trie.Insert(p, i)
+	}
+	addrs := benchmarkAddrs(1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; b.Loop(); i++ {
+		trie.Contains(addrs[i%len(addrs)])
+	}
+}
+
+func BenchmarkLinearContains(b *testing.B) {
+	prefixes := benchmarkPrefixes(100_000)
+	addrs := benchmarkAddrs(1024)
+	b.ResetTimer()
+	for i := 0; b.Loop(); i++ {
+		addr := addrs[i%len(addrs)]
+		for _, p := range prefixes {
+			if p.Contains(addr) {
+				break
+			}
+		}
+	}
+}
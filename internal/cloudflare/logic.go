@@ -8,22 +8,13 @@ import (
 	"github.com/kaumnen/cipr/internal/utils"
 )
 
-type Config struct {
-	Source    string
-	Verbosity string
-}
-
-func GetIPRanges(ctx context.Context, config Config) error {
-	rawData, err := utils.GetRawData(ctx, config.Source)
-	if err != nil {
-		return err
-	}
-	ipRanges, err := parseIPRanges(rawData)
+// fetchIPRanges reads source and returns its prefixes.
+func fetchIPRanges(ctx context.Context, source string) ([]string, error) {
+	rawData, err := utils.GetRawData(ctx, source)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	printIPRanges(ipRanges, config.Verbosity)
-	return nil
+	return parseIPRanges(rawData)
 }
 
 func parseIPRanges(rawData string) ([]string, error) {
@@ -43,18 +34,3 @@ func parseIPRanges(rawData string) ([]string, error) {
 	}
 	return ipRanges, nil
 }
-
-func printIPRanges(ipRanges []string, verbosity string) {
-	if len(ipRanges) == 0 {
-		fmt.Println("No IP ranges to display.")
-		return
-	}
-
-	for _, ip := range ipRanges {
-		if verbosity == "full" {
-			fmt.Printf("Cloudflare IP: %s\n", ip)
-		} else {
-			fmt.Println(ip)
-		}
-	}
-}
@@ -1,12 +1,14 @@
 package cloudflare
 
 import (
-	"bytes"
-	"io"
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -85,54 +87,31 @@ func TestParseIPRanges_InvalidCIDR(t *testing.T) {
 	assert.Contains(t, err.Error(), "line 2")
 }
 
-func TestPrintIPRanges(t *testing.T) {
-	testCases := []struct {
-		name           string
-		ipRanges       []string
-		verbosity      string
-		expectedOutput string
-	}{
-		{
-			name:           "Empty list",
-			ipRanges:       []string{},
-			verbosity:      "none",
-			expectedOutput: "No IP ranges to display.\n",
-		},
-		{
-			name:           "Verbosity none",
-			ipRanges:       []string{"1.1.1.0/24", "2.2.2.0/24"},
-			verbosity:      "none",
-			expectedOutput: "1.1.1.0/24\n2.2.2.0/24\n",
-		},
-		{
-			name:           "Verbosity mini behaves like none",
-			ipRanges:       []string{"1.1.1.0/24"},
-			verbosity:      "mini",
-			expectedOutput: "1.1.1.0/24\n",
-		},
-		{
-			name:           "Verbosity full",
-			ipRanges:       []string{"1.1.1.0/24", "2606:4700::/32"},
-			verbosity:      "full",
-			expectedOutput: "Cloudflare IP: 1.1.1.0/24\nCloudflare IP: 2606:4700::/32\n",
-		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			oldStdout := os.Stdout
-			r, w, _ := os.Pipe()
-			os.Stdout = w
-
-			printIPRanges(tc.ipRanges, tc.verbosity)
+func TestProviderLoad_FromFixture(t *testing.T) {
+	result, err := Provider{}.Load(context.Background(), provider.Query{
+		Source: filepath.Join("..", "testdata", "cloudflare_ipv6.txt"),
+		IPType: "both",
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Records, 7)
+	assert.Equal(t, "2400:cb00::/32", result.Records[0].Prefix.String())
+	assert.Equal(t, "cloudflare", result.Records[0].Provider)
+}
 
-			w.Close()
-			os.Stdout = oldStdout
+func TestProviderLoadFromHistory(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	at := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	for _, version := range []string{"ipv4", "ipv6"} {
+		_, err := utils.RecordSnapshot("cloudflare_"+version, []byte(loadFixture(t, "cloudflare_"+version+".txt")), at)
+		require.NoError(t, err)
+	}
 
-			var buf bytes.Buffer
-			io.Copy(&buf, r)
+	result, err := Provider{}.Load(context.Background(), provider.Query{Source: "history:cloudflare@2026-09-01", IPType: "ipv6"})
+	require.NoError(t, err)
+	require.Len(t, result.Records, 7)
+	assert.Equal(t, "2400:cb00::/32", result.Records[0].Prefix.String())
 
-			assert.Equal(t, tc.expectedOutput, buf.String())
-		})
-	}
+	result, err = Provider{}.Load(context.Background(), provider.Query{Source: "history:cloudflare@latest", IPType: "both"})
+	require.NoError(t, err)
+	assert.Greater(t, len(result.Records), 7)
 }
@@ -0,0 +1,95 @@
+package cloudflare
+
+import (
+	"context"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+)
+
+func init() {
+	provider.Register(Provider{})
+}
+
+// Provider exposes Cloudflare's per-family IP lists through the provider
+// registry.
+type Provider struct{}
+
+func (Provider) Name() string  { return "cloudflare" }
+func (Provider) Short() string { return "Get Cloudflare IP ranges" }
+func (Provider) Long() string {
+	return `Retrieve Cloudflare IPv4 and IPv6 ranges with optional verbosity levels.`
+}
+
+func (Provider) ConfigKeys() []string { return []string{"cloudflare_ipv4", "cloudflare_ipv6"} }
+
+func (Provider) DefaultEndpoints() map[string]string {
+	return map[string]string{
+		"cloudflare_ipv4": "https://www.cloudflare.com/ips-v4/",
+		"cloudflare_ipv6": "https://www.cloudflare.com/ips-v6/",
+	}
+}
+
+func (Provider) Columns() []provider.Column {
+	return []provider.Column{{Key: provider.PrefixKey, Label: "Cloudflare IP"}}
+}
+
+func (Provider) FilterDimensions() []provider.Dimension { return nil }
+func (Provider) ListDimensions() []provider.Dimension   { return nil }
+
+func (Provider) Parse(raw string) (provider.Result, error) {
+	ipRanges, err := parseIPRanges(raw)
+	if err != nil {
+		return provider.Result{}, err
+	}
+	return toProviderResult(ipRanges)
+}
+
+// Load fetches one list per requested family when the configured sources
+// are in use, or reads each family's snapshot for "history:cloudflare@...";
+// an explicit URL or path is read as a single list.
+func (Provider) Load(ctx context.Context, q provider.Query) (provider.Result, error) {
+	key, at, fromHistory := utils.ParseHistorySource(q.Source)
+	fromHistory = fromHistory && key == "cloudflare"
+	if !utils.UsesConfiguredSources(q.Source) && !fromHistory {
+		ipRanges, err := fetchIPRanges(ctx, q.Source)
+		if err != nil {
+			return provider.Result{}, err
+		}
+		return toProviderResult(ipRanges)
+	}
+
+	var ipVersions []string
+	if q.IPType != "ipv6" {
+		ipVersions = append(ipVersions, "ipv4")
+	}
+	if q.IPType != "ipv4" {
+		ipVersions = append(ipVersions, "ipv6")
+	}
+
+	var ipRanges []string
+	for _, version := range ipVersions {
+		source := "cloudflare_" + version
+		if fromHistory {
+			source = utils.HistorySource(source, at)
+		}
+		ranges, err := fetchIPRanges(ctx, source)
+		if err != nil {
+			return provider.Result{}, err
+		}
+		ipRanges = append(ipRanges, ranges...)
+	}
+	return toProviderResult(ipRanges)
+}
+
+func toProviderResult(ipRanges []string) (provider.Result, error) {
+	out := provider.Result{Records: make([]provider.Record, 0, len(ipRanges))}
+	for _, ip := range ipRanges {
+		rec, err := provider.NewRecord("cloudflare", ip)
+		if err != nil {
+			return provider.Result{}, err
+		}
+		out.Records = append(out.Records, rec)
+	}
+	return out, nil
+}
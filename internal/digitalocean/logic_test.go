@@ -1,34 +1,18 @@
 package digitalocean
 
 import (
-	"bytes"
 	"context"
-	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func captureStdout(t *testing.T, fn func()) string {
-	t.Helper()
-	oldStdout := os.Stdout
-	r, w, err := os.Pipe()
-	require.NoError(t, err)
-	os.Stdout = w
-
-	fn()
-
-	w.Close()
-	os.Stdout = oldStdout
-
-	var buf bytes.Buffer
-	_, _ = io.Copy(&buf, r)
-	return buf.String()
-}
-
 func TestParseRecords(t *testing.T) {
 	t.Run("empty input is rejected", func(t *testing.T) {
 		_, err := parseRecords("")
@@ -69,56 +53,42 @@ func TestParseRecords(t *testing.T) {
 	})
 }
 
-func TestGetIPRanges_FromFixture(t *testing.T) {
-	cfg := Config{
-		Source:    filepath.Join("..", "testdata", "do.csv"),
-		IPType:    "ipv4",
-		Filters:   Filters{Country: []string{"NL"}, City: []string{"Amsterdam"}},
-		Verbosity: "none",
+func TestProviderLoad_FromFixture(t *testing.T) {
+	q := provider.Query{
+		Source:  filepath.Join("..", "testdata", "do.csv"),
+		IPType:  "ipv4",
+		Filters: map[string][]string{"country": {"NL"}, "city": {"Amsterdam"}},
 	}
 
-	out := captureStdout(t, func() {
-		err := GetIPRanges(context.Background(), cfg)
-		require.NoError(t, err)
-	})
-
-	lines := bytes.Split(bytes.TrimRight([]byte(out), "\n"), []byte("\n"))
-	assert.NotEmpty(t, lines)
-	for _, line := range lines {
-		assert.Contains(t, string(line), "/")
+	result, err := Provider{}.Load(context.Background(), q)
+	require.NoError(t, err)
+	records := q.Select(result.Records)
+	assert.NotEmpty(t, records)
+	for _, r := range records {
+		assert.Equal(t, provider.IPv4, r.Family)
 	}
 }
 
-func TestGetIPRanges_SourceError(t *testing.T) {
-	cfg := Config{
-		Source:    "./does-not-exist/missing.csv",
-		IPType:    "ipv4",
-		Verbosity: "none",
-	}
-	err := GetIPRanges(context.Background(), cfg)
+func TestProviderLoad_SourceError(t *testing.T) {
+	_, err := Provider{}.Load(context.Background(), provider.Query{Source: "./does-not-exist/missing.csv", IPType: "ipv4"})
 	require.Error(t, err)
 }
 
-func TestGetIPRanges_List_FilterComposition(t *testing.T) {
-	cfg := Config{
-		Source:    filepath.Join("..", "testdata", "do.csv"),
-		IPType:    "both",
-		Filters:   Filters{Country: []string{"NL"}},
-		List:      "cities",
-		Verbosity: "none",
+func TestProviderList_FilterComposition(t *testing.T) {
+	p := Provider{}
+	q := provider.Query{
+		Source:  filepath.Join("..", "testdata", "do.csv"),
+		IPType:  "both",
+		Filters: map[string][]string{"country": {"NL"}},
 	}
-
-	out := captureStdout(t, func() {
-		require.NoError(t, GetIPRanges(context.Background(), cfg))
-	})
-
-	lines := bytes.Split(bytes.TrimRight([]byte(out), "\n"), []byte("\n"))
+	result, err := p.Load(context.Background(), q)
+	require.NoError(t, err)
+	lines := provider.ListValues(q.Select(result.Records), p.ListDimensions()[2])
 	require.NotEmpty(t, lines)
-	assert.Contains(t, lines, []byte("Amsterdam"))
+	assert.Contains(t, lines, "Amsterdam")
 
 	seen := make(map[string]struct{})
-	for _, l := range lines {
-		s := string(l)
+	for _, s := range lines {
 		assert.NotEmpty(t, s, "empty city surfaced")
 		_, dup := seen[s]
 		assert.False(t, dup, "duplicate city %q", s)
@@ -126,185 +96,111 @@ func TestGetIPRanges_List_FilterComposition(t *testing.T) {
 	}
 }
 
-func TestPrintListedValues(t *testing.T) {
-	ranges := []IPRange{
-		{Country: "NL", Region: "NL-NH", City: "Amsterdam", Zip: "1098 XH"},
-		{Country: "NL", Region: "NL-NH", City: "Amsterdam", Zip: "1098 XH"},
-		{Country: "US", Region: "US-NY", City: "New York", Zip: "10001"},
-		{Country: "US", Region: "", City: "", Zip: ""},
-	}
-
-	t.Run("zips drops empty and dedupes", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(ranges, "zips"))
-		})
-		assert.Equal(t, "10001\n1098 XH\n", out)
-	})
-
-	t.Run("unknown dim returns error", func(t *testing.T) {
-		err := printListedValues(ranges, "bogus")
-		require.Error(t, err)
-	})
-}
-
-func TestFiltrateIPRanges(t *testing.T) {
-	ipRanges := []IPRange{
-		{IPRange: "192.168.1.0/24", Country: "US", Region: "California", City: "San Francisco", Zip: "94107"},
-		{IPRange: "2607:f8b0:4005:805::200e", Country: "US", Region: "California", City: "Mountain View", Zip: "94043"},
-		{IPRange: "10.0.0.0/8", Country: "US", Region: "New York", City: "New York", Zip: "10001"},
-		{IPRange: "2001:4860:4860::8888", Country: "US", Region: "California", City: "Mountain View", Zip: "94043"},
-	}
+func TestSelectIPRanges(t *testing.T) {
+	raw := "192.168.1.0/24,US,California,San Francisco,94107\n" +
+		"2607:f8b0:4005:805::200e/128,US,California,Mountain View,94043\n" +
+		"10.0.0.0/8,US,New York,New York,10001\n" +
+		"2001:4860:4860::8888/128,US,California,Mountain View,94043\n"
 
 	tests := []struct {
 		name     string
-		config   Config
-		expected []IPRange
+		query    provider.Query
+		expected []string
 	}{
 		{
 			name: "Filter by IPv4",
-			config: Config{
+			query: provider.Query{
 				IPType: "ipv4",
 			},
-			expected: []IPRange{
-				{IPRange: "192.168.1.0/24", Country: "US", Region: "California", City: "San Francisco", Zip: "94107"},
-				{IPRange: "10.0.0.0/8", Country: "US", Region: "New York", City: "New York", Zip: "10001"},
+			expected: []string{
+				"192.168.1.0/24",
+				"10.0.0.0/8",
 			},
 		},
 		{
 			name: "Filter by IPv6",
-			config: Config{
+			query: provider.Query{
 				IPType: "ipv6",
 			},
-			expected: []IPRange{
-				{IPRange: "2607:f8b0:4005:805::200e", Country: "US", Region: "California", City: "Mountain View", Zip: "94043"},
-				{IPRange: "2001:4860:4860::8888", Country: "US", Region: "California", City: "Mountain View", Zip: "94043"},
+			expected: []string{
+				"2607:f8b0:4005:805::200e/128",
+				"2001:4860:4860::8888/128",
 			},
 		},
 		{
 			name: "Filter by both IPv4 and IPv6",
-			config: Config{
+			query: provider.Query{
 				IPType: "both",
 			},
-			expected: ipRanges,
+			expected: []string{"192.168.1.0/24", "2607:f8b0:4005:805::200e/128", "10.0.0.0/8", "2001:4860:4860::8888/128"},
 		},
 		{
 			name: "Filter by country",
-			config: Config{
-				IPType: "both",
-				Filters: Filters{
-					Country: []string{"US"},
-				},
+			query: provider.Query{
+				IPType:  "both",
+				Filters: map[string][]string{"country": {"US"}},
 			},
-			expected: ipRanges,
+			expected: []string{"192.168.1.0/24", "2607:f8b0:4005:805::200e/128", "10.0.0.0/8", "2001:4860:4860::8888/128"},
 		},
 		{
 			name: "Filter by region",
-			config: Config{
-				IPType: "both",
-				Filters: Filters{
-					Region: []string{"California"},
-				},
+			query: provider.Query{
+				IPType:  "both",
+				Filters: map[string][]string{"region": {"California"}},
 			},
-			expected: []IPRange{
-				{IPRange: "192.168.1.0/24", Country: "US", Region: "California", City: "San Francisco", Zip: "94107"},
-				{IPRange: "2607:f8b0:4005:805::200e", Country: "US", Region: "California", City: "Mountain View", Zip: "94043"},
-				{IPRange: "2001:4860:4860::8888", Country: "US", Region: "California", City: "Mountain View", Zip: "94043"},
+			expected: []string{
+				"192.168.1.0/24",
+				"2607:f8b0:4005:805::200e/128",
+				"2001:4860:4860::8888/128",
 			},
 		},
 		{
 			name: "Filter by city",
-			config: Config{
-				IPType: "both",
-				Filters: Filters{
-					City: []string{"Mountain View"},
-				},
+			query: provider.Query{
+				IPType:  "both",
+				Filters: map[string][]string{"city": {"Mountain View"}},
 			},
-			expected: []IPRange{
-				{IPRange: "2607:f8b0:4005:805::200e", Country: "US", Region: "California", City: "Mountain View", Zip: "94043"},
-				{IPRange: "2001:4860:4860::8888", Country: "US", Region: "California", City: "Mountain View", Zip: "94043"},
+			expected: []string{
+				"2607:f8b0:4005:805::200e/128",
+				"2001:4860:4860::8888/128",
 			},
 		},
 		{
 			name: "Filter by zip",
-			config: Config{
-				IPType: "both",
-				Filters: Filters{
-					Zip: []string{"94043"},
-				},
+			query: provider.Query{
+				IPType:  "both",
+				Filters: map[string][]string{"zip": {"94043"}},
 			},
-			expected: []IPRange{
-				{IPRange: "2607:f8b0:4005:805::200e", Country: "US", Region: "California", City: "Mountain View", Zip: "94043"},
-				{IPRange: "2001:4860:4860::8888", Country: "US", Region: "California", City: "Mountain View", Zip: "94043"},
+			expected: []string{
+				"2607:f8b0:4005:805::200e/128",
+				"2001:4860:4860::8888/128",
 			},
 		},
 	}
 
+	result, err := Provider{}.Parse(raw)
+	require.NoError(t, err)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := filtrateIPRanges(ipRanges, tt.config)
-			assert.Equal(t, tt.expected, result)
+			var got []string
+			for _, rec := range tt.query.Select(result.Records) {
+				got = append(got, rec.Prefix.String())
+			}
+			assert.Equal(t, tt.expected, got)
 		})
 	}
 }
-func TestPrintIPRanges(t *testing.T) {
-	ipRanges := []IPRange{
-		{IPRange: "192.168.1.0/24", Country: "US", Region: "California", City: "San Francisco", Zip: "94107"},
-		{IPRange: "2607:f8b0:4005:805::200e", Country: "US", Region: "California", City: "Mountain View", Zip: "94043"},
-	}
-
-	tests := []struct {
-		name      string
-		ipRanges  []IPRange
-		verbosity string
-		expected  string
-	}{
-		{
-			name:      "No IP ranges",
-			ipRanges:  []IPRange{},
-			verbosity: "none",
-			expected:  "No IP ranges to display.\n",
-		},
-		{
-			name:      "Verbosity none",
-			ipRanges:  ipRanges,
-			verbosity: "none",
-			expected:  "192.168.1.0/24\n2607:f8b0:4005:805::200e\n",
-		},
-		{
-			name:      "Verbosity mini",
-			ipRanges:  ipRanges,
-			verbosity: "mini",
-			expected:  "192.168.1.0/24,US,California,San Francisco,94107\n2607:f8b0:4005:805::200e,US,California,Mountain View,94043\n",
-		},
-		{
-			name:      "Verbosity full",
-			ipRanges:  ipRanges,
-			verbosity: "full",
-			expected:  "IP Range: 192.168.1.0/24, Country: US, Region: California, City: San Francisco, ZIP: 94107\nIP Range: 2607:f8b0:4005:805::200e, Country: US, Region: California, City: Mountain View, ZIP: 94043\n",
-		},
-		{
-			name:      "Default verbosity",
-			ipRanges:  ipRanges,
-			verbosity: "unknown",
-			expected:  "192.168.1.0/24\n2607:f8b0:4005:805::200e\n",
-		},
-	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			r, w, _ := os.Pipe()
-			oldStdout := os.Stdout
-			os.Stdout = w
-
-			printIPRanges(tt.ipRanges, tt.verbosity)
-
-			w.Close()
-			os.Stdout = oldStdout
-
-			var buf bytes.Buffer
-			buf.ReadFrom(r)
+func TestProviderLoadFromHistory(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "do.csv"))
+	require.NoError(t, err)
+	_, err = utils.RecordSnapshot("digitalocean", data, time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
 
-			assert.Equal(t, tt.expected, buf.String())
-		})
+	for _, source := range []string{"history:do@latest", "history:digitalocean@2026-09-01"} {
+		result, err := Provider{}.Load(context.Background(), provider.Query{Source: source, IPType: "both"})
+		require.NoError(t, err, source)
+		assert.Len(t, result.Records, 1144, source)
 	}
 }
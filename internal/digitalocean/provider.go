@@ -0,0 +1,81 @@
+package digitalocean
+
+import (
+	"context"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+)
+
+const defaultEndpoint = "https://digitalocean.com/geo/google.csv"
+
+func init() {
+	provider.Register(Provider{})
+}
+
+// Provider exposes the DigitalOcean geo CSV through the provider registry.
+type Provider struct{}
+
+func (Provider) Name() string  { return "do" }
+func (Provider) Short() string { return "Get Digital Ocean IP ranges" }
+func (Provider) Long() string {
+	return `Retrieve Digital Ocean IPv4 and IPv6 ranges with optional verbosity levels.`
+}
+
+func (Provider) ConfigKeys() []string { return []string{"digitalocean"} }
+
+func (Provider) DefaultEndpoints() map[string]string {
+	return map[string]string{"digitalocean": defaultEndpoint}
+}
+
+func (Provider) Columns() []provider.Column {
+	return []provider.Column{
+		{Key: provider.PrefixKey, Label: "IP Range"},
+		{Key: "country", Label: "Country"},
+		{Key: "region", Label: "Region"},
+		{Key: "city", Label: "City"},
+		{Key: "zip", Label: "ZIP"},
+	}
+}
+
+func (Provider) FilterDimensions() []provider.Dimension {
+	return []provider.Dimension{
+		{Key: "country", Plural: "countries", Usage: "Filter results by country"},
+		{Key: "region", Plural: "regions", Usage: "Filter results by region"},
+		{Key: "city", Plural: "cities", Usage: `Filter results by city (use quotes for names with spaces, e.g. "New York")`},
+		{Key: "zip", Plural: "zips", Usage: `Filter results by ZIP code`},
+	}
+}
+
+func (p Provider) ListDimensions() []provider.Dimension { return p.FilterDimensions() }
+
+func (Provider) Parse(raw string) (provider.Result, error) {
+	ranges, err := parseRecords(raw)
+	if err != nil {
+		return provider.Result{}, err
+	}
+	return toProviderResult(ranges)
+}
+
+func (p Provider) Load(ctx context.Context, q provider.Query) (provider.Result, error) {
+	raw, err := utils.GetRawData(ctx, provider.SourceFor(q.Source, "digitalocean"))
+	if err != nil {
+		return provider.Result{}, err
+	}
+	return p.Parse(raw)
+}
+
+func toProviderResult(ranges []IPRange) (provider.Result, error) {
+	out := provider.Result{Records: make([]provider.Record, 0, len(ranges))}
+	for _, r := range ranges {
+		rec, err := provider.NewRecord("do", r.IPRange)
+		if err != nil {
+			return provider.Result{}, err
+		}
+		rec.Country = r.Country
+		rec.Region = r.Region
+		rec.Attributes = map[string]string{"city": r.City, "zip": r.Zip}
+		out.Records = append(out.Records, rec)
+	}
+	return out, nil
+}
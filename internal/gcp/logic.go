@@ -1,7 +1,6 @@
 package gcp
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -28,43 +27,12 @@ type Prefix struct {
 	Service string
 }
 
-type Config struct {
-	Source    string
-	IPType    string
-	Filter    string
-	Filters   Filters
-	List      string
-	Verbosity string
-}
-
-type Filters struct {
-	Scope   []string
-	Service []string
-}
-
-func GetIPRanges(ctx context.Context, config Config) error {
-	rawData, err := utils.GetRawData(ctx, config.Source)
-	if err != nil {
-		return err
-	}
-
-	ipType := config.IPType
-	if config.List != "" {
-		ipType = "both"
-	}
-	filters := config.Filters
-	if config.Filter != "" {
-		filters = filtersFromComposite(config.Filter)
-	}
-	prefixes, err := filtrateIPRanges(rawData, ipType, filters)
-	if err != nil {
-		return err
-	}
-	if config.List != "" {
-		return printListedValues(prefixes, config.List)
-	}
-	printIPRanges(prefixes, config.Verbosity)
-	return nil
+// Result is the content of one cloud.json document together with the
+// document's own metadata.
+type Result struct {
+	SyncToken    string
+	CreationTime string
+	Prefixes     []Prefix
 }
 
 func separateFilters(filterFlagValues string) []string {
@@ -83,11 +51,13 @@ func separateFilters(filterFlagValues string) []string {
 	return filters[:2]
 }
 
-func filtersFromComposite(filter string) Filters {
+// filtersFromComposite maps the positional --filter syntax "scope,service"
+// to record filters.
+func filtersFromComposite(filter string) map[string][]string {
 	parts := separateFilters(filter)
-	return Filters{
-		Scope:   wildcardToEmpty(parts[0]),
-		Service: wildcardToEmpty(parts[1]),
+	return map[string][]string{
+		"scope":   wildcardToEmpty(parts[0]),
+		"service": wildcardToEmpty(parts[1]),
 	}
 }
 
@@ -98,22 +68,22 @@ func wildcardToEmpty(value string) []string {
 	return []string{value}
 }
 
-func filtrateIPRanges(rawData, ipType string, filters Filters) ([]Prefix, error) {
+func parseIPRanges(rawData string) (Result, error) {
 	var data IPsData
 	if err := json.Unmarshal([]byte(rawData), &data); err != nil {
-		return nil, fmt.Errorf("parse gcp cloud ip-ranges json: %w", err)
+		return Result{}, fmt.Errorf("parse gcp cloud ip-ranges json: %w", err)
 	}
 	if len(data.Prefixes) == 0 {
-		return nil, fmt.Errorf("validate gcp cloud ip-ranges json: no IP ranges found")
+		return Result{}, fmt.Errorf("validate gcp cloud ip-ranges json: no IP ranges found")
 	}
 
-	var result []Prefix
+	result := Result{SyncToken: data.SyncToken, CreationTime: data.CreationTime}
 	for i, source := range data.Prefixes {
 		if source.IPv4Prefix == "" && source.IPv6Prefix == "" {
-			return nil, fmt.Errorf("validate gcp prefix %d: missing ipv4Prefix or ipv6Prefix", i+1)
+			return Result{}, fmt.Errorf("validate gcp prefix %d: missing ipv4Prefix or ipv6Prefix", i+1)
 		}
 		if source.IPv4Prefix != "" && source.IPv6Prefix != "" {
-			return nil, fmt.Errorf("validate gcp prefix %d: both ipv4Prefix and ipv6Prefix are set", i+1)
+			return Result{}, fmt.Errorf("validate gcp prefix %d: both ipv4Prefix and ipv6Prefix are set", i+1)
 		}
 
 		address := source.IPv4Prefix
@@ -123,77 +93,14 @@ func filtrateIPRanges(rawData, ipType string, filters Filters) ([]Prefix, error)
 			family = "IPv6"
 		}
 		if !utils.IsCIDR(address) {
-			return nil, fmt.Errorf("validate gcp %s prefix %d: %q is not a valid CIDR", family, i+1, address)
+			return Result{}, fmt.Errorf("validate gcp %s prefix %d: %q is not a valid CIDR", family, i+1, address)
 		}
 		wrongFamily := (family == "IPv4" && !utils.IsIPv4(address)) ||
 			(family == "IPv6" && !utils.IsIPv6(address))
 		if wrongFamily {
-			return nil, fmt.Errorf("validate gcp %s prefix %d: %q has the wrong address family", family, i+1, address)
+			return Result{}, fmt.Errorf("validate gcp %s prefix %d: %q has the wrong address family", family, i+1, address)
 		}
-		if !matchesFilter(source, filters) || !ipVersionMatches(address, ipType) {
-			continue
-		}
-		result = append(result, Prefix{Address: address, Scope: source.Scope, Service: source.Service})
+		result.Prefixes = append(result.Prefixes, Prefix{Address: address, Scope: source.Scope, Service: source.Service})
 	}
 	return result, nil
 }
-
-func matchesFilter(prefix sourcePrefix, filters Filters) bool {
-	return (len(filters.Scope) == 0 || utils.ContainsIgnoreCase(filters.Scope, prefix.Scope)) &&
-		(len(filters.Service) == 0 || utils.ContainsIgnoreCase(filters.Service, prefix.Service))
-}
-
-func ipVersionMatches(address, ipType string) bool {
-	switch ipType {
-	case "ipv4":
-		return utils.IsIPv4(address)
-	case "ipv6":
-		return utils.IsIPv6(address)
-	default:
-		return true
-	}
-}
-
-func printListedValues(prefixes []Prefix, dimension string) error {
-	values := make([]string, 0, len(prefixes))
-	switch dimension {
-	case "scopes":
-		for _, prefix := range prefixes {
-			values = append(values, prefix.Scope)
-		}
-	case "services":
-		for _, prefix := range prefixes {
-			values = append(values, prefix.Service)
-		}
-	default:
-		return fmt.Errorf("unknown list dimension %q (valid: scopes, services)", dimension)
-	}
-
-	values = utils.DedupeSorted(values)
-	if len(values) == 0 {
-		fmt.Println("No values to display.")
-		return nil
-	}
-	for _, value := range values {
-		fmt.Println(value)
-	}
-	return nil
-}
-
-func printIPRanges(prefixes []Prefix, verbosity string) {
-	if len(prefixes) == 0 {
-		fmt.Println("No IP ranges to display.")
-		return
-	}
-
-	for _, prefix := range prefixes {
-		switch verbosity {
-		case "mini":
-			fmt.Printf("%s,%s,%s\n", prefix.Address, prefix.Scope, prefix.Service)
-		case "full":
-			fmt.Printf("IP Prefix: %s, Scope: %s, Service: %s\n", prefix.Address, prefix.Scope, prefix.Service)
-		default:
-			fmt.Println(prefix.Address)
-		}
-	}
-}
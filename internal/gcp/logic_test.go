@@ -1,13 +1,12 @@
 package gcp
 
 import (
-	"bytes"
 	"context"
-	"io"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/kaumnen/cipr/internal/provider"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -19,25 +18,6 @@ func loadFixture(t *testing.T) string {
 	return string(data)
 }
 
-func captureStdout(t *testing.T, fn func()) string {
-	t.Helper()
-	oldStdout := os.Stdout
-	r, w, err := os.Pipe()
-	require.NoError(t, err)
-	os.Stdout = w
-	t.Cleanup(func() { os.Stdout = oldStdout })
-
-	fn()
-
-	require.NoError(t, w.Close())
-	os.Stdout = oldStdout
-	var buf bytes.Buffer
-	_, err = io.Copy(&buf, r)
-	require.NoError(t, err)
-	require.NoError(t, r.Close())
-	return buf.String()
-}
-
 func TestSeparateFilters(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -59,7 +39,20 @@ func TestSeparateFilters(t *testing.T) {
 	}
 }
 
-func TestFiltrateIPRanges(t *testing.T) {
+// selectPrefixes parses rawData and returns the prefixes of the records q
+// selects.
+func selectPrefixes(t *testing.T, rawData string, q provider.Query) []Prefix {
+	t.Helper()
+	result, err := Provider{}.Parse(rawData)
+	require.NoError(t, err)
+	var prefixes []Prefix
+	for _, rec := range q.Select(result.Records) {
+		prefixes = append(prefixes, Prefix{Address: rec.Prefix.String(), Scope: rec.Get("scope"), Service: rec.Service})
+	}
+	return prefixes
+}
+
+func TestSelectIPRanges(t *testing.T) {
 	rawData := loadFixture(t)
 	tests := []struct {
 		name   string
@@ -124,14 +117,13 @@ func TestFiltrateIPRanges(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := filtrateIPRanges(rawData, tt.ipType, filtersFromComposite(tt.filter))
-			require.NoError(t, err)
+			got := selectPrefixes(t, rawData, provider.Query{IPType: tt.ipType, Filters: filtersFromComposite(tt.filter)})
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
 
-func TestFiltrateIPRangesValidation(t *testing.T) {
+func TestParseIPRangesValidation(t *testing.T) {
 	tests := []struct {
 		name string
 		raw  string
@@ -149,159 +141,102 @@ func TestFiltrateIPRangesValidation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := filtrateIPRanges(tt.raw, "both", Filters{})
+			_, err := parseIPRanges(tt.raw)
 			require.Error(t, err)
 			assert.ErrorContains(t, err, tt.want)
 		})
 	}
 }
 
-func TestFiltrateIPRangesValidatesExcludedEntries(t *testing.T) {
+func TestParseIPRangesValidatesEveryEntry(t *testing.T) {
 	rawData := `{"prefixes":[
 		{"ipv4Prefix":"192.0.2.0/24","service":"Google Cloud","scope":"global"},
 		{"ipv6Prefix":"invalid","service":"Google Cloud","scope":"elsewhere"}
 	]}`
-	_, err := filtrateIPRanges(rawData, "ipv4", filtersFromComposite("global,"))
+	_, err := Provider{}.Parse(rawData)
 	require.Error(t, err)
 	assert.ErrorContains(t, err, "validate gcp IPv6 prefix 2")
 }
 
-func TestFiltrateIPRangesFiltersService(t *testing.T) {
+func TestSelectIPRangesFiltersService(t *testing.T) {
 	rawData := `{"prefixes":[
 		{"ipv4Prefix":"192.0.2.0/24","service":"Google Cloud","scope":"global"},
 		{"ipv4Prefix":"198.51.100.0/24","service":"Future Service","scope":"global"}
 	]}`
-	got, err := filtrateIPRanges(rawData, "ipv4", filtersFromComposite(",google cloud"))
-	require.NoError(t, err)
+	got := selectPrefixes(t, rawData, provider.Query{IPType: "ipv4", Filters: filtersFromComposite(",google cloud")})
 	assert.Equal(t, []Prefix{
 		{Address: "192.0.2.0/24", Scope: "global", Service: "Google Cloud"},
 	}, got)
 }
 
-func TestFiltrateIPRangesMultipleValues(t *testing.T) {
+func TestSelectIPRangesMultipleValues(t *testing.T) {
 	rawData := `{"prefixes":[
 		{"ipv4Prefix":"192.0.2.0/24","service":"Google Cloud","scope":"global"},
 		{"ipv4Prefix":"198.51.100.0/24","service":"Future Service","scope":"asia-east1"},
 		{"ipv4Prefix":"203.0.113.0/24","service":"Other Service","scope":"africa-south1"}
 	]}`
 
-	got, err := filtrateIPRanges(rawData, "ipv4", Filters{
-		Scope:   []string{"GLOBAL", "asia-east1"},
-		Service: []string{"google cloud", "FUTURE SERVICE"},
-	})
-	require.NoError(t, err)
+	got := selectPrefixes(t, rawData, provider.Query{IPType: "ipv4", Filters: map[string][]string{
+		"scope":   {"GLOBAL", "asia-east1"},
+		"service": {"google cloud", "FUTURE SERVICE"},
+	}})
 	assert.Equal(t, []Prefix{
 		{Address: "192.0.2.0/24", Scope: "global", Service: "Google Cloud"},
 		{Address: "198.51.100.0/24", Scope: "asia-east1", Service: "Future Service"},
 	}, got)
 }
 
-func TestPrintIPRanges(t *testing.T) {
-	tests := []struct {
-		name      string
-		prefixes  []Prefix
-		verbosity string
-		want      string
-	}{
-		{name: "empty", verbosity: "none", want: "No IP ranges to display.\n"},
-		{
-			name: "none",
-			prefixes: []Prefix{
-				{Address: "34.80.0.0/15", Scope: "asia-east1", Service: "Google Cloud"},
-				{Address: "2600:1900:4030::/44", Scope: "asia-east1", Service: "Google Cloud"},
-			},
-			verbosity: "none",
-			want:      "34.80.0.0/15\n2600:1900:4030::/44\n",
-		},
-		{
-			name:      "mini",
-			prefixes:  []Prefix{{Address: "8.228.224.0/20", Scope: "global", Service: "Google Cloud"}},
-			verbosity: "mini",
-			want:      "8.228.224.0/20,global,Google Cloud\n",
-		},
-		{
-			name:      "full",
-			prefixes:  []Prefix{{Address: "2600:1901::/48", Scope: "global", Service: "Google Cloud"}},
-			verbosity: "full",
-			want:      "IP Prefix: 2600:1901::/48, Scope: global, Service: Google Cloud\n",
-		},
-		{
-			name:      "unknown verbosity falls back to CIDR only",
-			prefixes:  []Prefix{{Address: "34.1.208.0/20", Scope: "africa-south1", Service: "Google Cloud"}},
-			verbosity: "unknown",
-			want:      "34.1.208.0/20\n",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			out := captureStdout(t, func() { printIPRanges(tt.prefixes, tt.verbosity) })
-			assert.Equal(t, tt.want, out)
-		})
-	}
-}
-
-func TestPrintListedValues(t *testing.T) {
-	prefixes := []Prefix{
-		{Address: "34.80.0.0/15", Scope: "asia-east1", Service: "Google Cloud"},
-		{Address: "2600:1900:4030::/44", Scope: "asia-east1", Service: "Google Cloud"},
-		{Address: "8.228.224.0/20", Scope: "global", Service: "Google Cloud"},
-		{Address: "192.0.2.0/24", Scope: "", Service: ""},
-	}
-
-	t.Run("scopes are sorted deduplicated and non-empty", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(prefixes, "scopes"))
-		})
-		assert.Equal(t, "asia-east1\nglobal\n", out)
-	})
-	t.Run("services are sorted deduplicated and non-empty", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(prefixes, "services"))
-		})
-		assert.Equal(t, "Google Cloud\n", out)
-	})
-	t.Run("empty input", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(nil, "scopes"))
-		})
-		assert.Equal(t, "No values to display.\n", out)
-	})
-	t.Run("unknown dimension", func(t *testing.T) {
-		err := printListedValues(prefixes, "regions")
-		require.Error(t, err)
-		assert.ErrorContains(t, err, "valid: scopes, services")
-	})
+func TestParseIPRangesKeepsMetadata(t *testing.T) {
+	got, err := parseIPRanges(loadFixture(t))
+	require.NoError(t, err)
+	assert.NotEmpty(t, got.SyncToken)
+	assert.NotEmpty(t, got.CreationTime)
 }
 
-func TestGetIPRanges(t *testing.T) {
-	config := Config{
-		Source:    filepath.Join("..", "testdata", "gcp_cloud_sample.json"),
-		IPType:    "ipv4",
-		Filter:    "global,Google Cloud",
-		Verbosity: "mini",
+func TestProviderLoad(t *testing.T) {
+	q := provider.Query{
+		Source:  filepath.Join("..", "testdata", "gcp_cloud_sample.json"),
+		IPType:  "ipv4",
+		Filters: filtersFromComposite("global,Google Cloud"),
 	}
-	out := captureStdout(t, func() {
-		require.NoError(t, GetIPRanges(context.Background(), config))
-	})
-	assert.Equal(t, "8.228.224.0/20,global,Google Cloud\n", out)
+	result, err := Provider{}.Load(context.Background(), q)
+	require.NoError(t, err)
+	records := q.Select(result.Records)
+	require.Len(t, records, 1)
+	assert.Equal(t, "8.228.224.0/20", records[0].Prefix.String())
 }
 
-func TestGetIPRangesListComposesWithFilterAndIgnoresIPType(t *testing.T) {
-	config := Config{
-		Source: filepath.Join("..", "testdata", "gcp_cloud_sample.json"),
-		IPType: "ipv4",
-		Filter: ",Google Cloud",
-		List:   "scopes",
+func TestProviderListComposesWithFilter(t *testing.T) {
+	p := Provider{}
+	q := provider.Query{
+		Source:  filepath.Join("..", "testdata", "gcp_cloud_sample.json"),
+		IPType:  "both",
+		Filters: p.FiltersFromComposite(",Google Cloud"),
 	}
-	out := captureStdout(t, func() {
-		require.NoError(t, GetIPRanges(context.Background(), config))
-	})
-	assert.Equal(t, "africa-south1\nasia-east1\nglobal\n", out)
+	result, err := p.Load(context.Background(), q)
+	require.NoError(t, err)
+	values := provider.ListValues(q.Select(result.Records), p.ListDimensions()[0])
+	assert.Equal(t, []string{"africa-south1", "asia-east1", "global"}, values)
 }
 
-func TestGetIPRangesPropagatesSourceError(t *testing.T) {
-	err := GetIPRanges(context.Background(), Config{Source: filepath.Join(t.TempDir(), "missing.json")})
+func TestProviderLoadPropagatesSourceError(t *testing.T) {
+	_, err := Provider{}.Load(context.Background(), provider.Query{Source: filepath.Join(t.TempDir(), "missing.json")})
 	require.Error(t, err)
 	assert.ErrorContains(t, err, "read")
 }
+
+func TestProviderParseNormalizesScope(t *testing.T) {
+	result, err := Provider{}.Parse(loadFixture(t))
+	require.NoError(t, err)
+	require.NotEmpty(t, result.Records)
+
+	rec := result.Records[0]
+	assert.Equal(t, "34.1.208.0/20", rec.Prefix.String())
+	assert.Equal(t, "gcp", rec.Provider)
+	assert.Equal(t, provider.IPv4, rec.Family)
+	assert.Equal(t, "africa-south1", rec.Region)
+	assert.Equal(t, "Google Cloud", rec.Service)
+	assert.Equal(t, "africa-south1", rec.Get("scope"))
+	assert.Equal(t, provider.IPv6, result.Records[1].Family)
+	assert.Equal(t, "1783670691420", result.Metadata["sync_token"])
+}
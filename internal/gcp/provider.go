@@ -0,0 +1,89 @@
+package gcp
+
+import (
+	"context"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+)
+
+const defaultEndpoint = "https://www.gstatic.com/ipranges/cloud.json"
+
+func init() {
+	provider.Register(Provider{})
+}
+
+// Provider exposes the Google Cloud cloud.json feed through the provider
+// registry.
+type Provider struct{}
+
+func (Provider) Name() string  { return "gcp" }
+func (Provider) Short() string { return "Get Google Cloud IP ranges." }
+func (Provider) Long() string {
+	return `Get Google Cloud IPv4 and IPv6 ranges with optional scope and service filtering.`
+}
+
+func (Provider) ConfigKeys() []string { return []string{"gcp"} }
+
+func (Provider) DefaultEndpoints() map[string]string {
+	return map[string]string{"gcp": defaultEndpoint}
+}
+
+func (Provider) Columns() []provider.Column {
+	return []provider.Column{
+		{Key: provider.PrefixKey, Label: "IP Prefix"},
+		{Key: "scope", Label: "Scope"},
+		{Key: "service", Label: "Service"},
+	}
+}
+
+func (Provider) FilterDimensions() []provider.Dimension {
+	return []provider.Dimension{
+		{Key: "scope", Plural: "scopes", Usage: "Filter results by Google Cloud scope (comma-separated, for example, us-central1,global)"},
+		{Key: "service", Plural: "services", Usage: "Filter results by Google Cloud service (comma-separated)"},
+	}
+}
+
+func (p Provider) ListDimensions() []provider.Dimension { return p.FilterDimensions() }
+
+func (Provider) FiltersFromComposite(filter string) map[string][]string {
+	return filtersFromComposite(filter)
+}
+
+func (Provider) Parse(raw string) (provider.Result, error) {
+	result, err := parseIPRanges(raw)
+	if err != nil {
+		return provider.Result{}, err
+	}
+	return toProviderResult(result)
+}
+
+func (p Provider) Load(ctx context.Context, q provider.Query) (provider.Result, error) {
+	raw, err := utils.GetRawData(ctx, provider.SourceFor(q.Source, "gcp"))
+	if err != nil {
+		return provider.Result{}, err
+	}
+	return p.Parse(raw)
+}
+
+func toProviderResult(result Result) (provider.Result, error) {
+	out := provider.Result{
+		Records: make([]provider.Record, 0, len(result.Prefixes)),
+		Metadata: map[string]string{
+			"sync_token":    result.SyncToken,
+			"creation_time": result.CreationTime,
+		},
+	}
+	for _, p := range result.Prefixes {
+		rec, err := provider.NewRecord("gcp", p.Address)
+		if err != nil {
+			return provider.Result{}, err
+		}
+		// GCP scopes are regions ("us-central1") or "global".
+		rec.Region = p.Scope
+		rec.Service = p.Service
+		rec.Attributes = map[string]string{"scope": p.Scope}
+		out.Records = append(out.Records, rec)
+	}
+	return out, nil
+}
@@ -0,0 +1,74 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaumnen/cipr/internal/utils"
+	"github.com/spf13/viper"
+)
+
+// apiHost is the only host the token is sent to, and only over https; a
+// github_endpoint pointing elsewhere is fetched anonymously.
+const apiHost = "api.github.com"
+
+func init() {
+	utils.RegisterHostHooks(apiHost, utils.HostHooks{
+		Authorize:   authorize,
+		StatusError: rateLimitError,
+	})
+}
+
+// token returns the GitHub API token from GITHUB_TOKEN, GH_TOKEN, or the
+// github_token config key, in that order.
+func token() string {
+	for _, env := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	return viper.GetString("github_token")
+}
+
+func authorize(req *http.Request) {
+	if req.URL.Scheme != "https" || !strings.EqualFold(req.URL.Hostname(), apiHost) {
+		utils.Debugf("github: not sending token to %s over %s", req.URL.Host, req.URL.Scheme)
+		return
+	}
+	t := token()
+	if t == "" {
+		utils.Debugf("github: no token configured; requesting %s anonymously", req.URL.Host)
+		return
+	}
+	utils.Debugf("github: sending token to %s", req.URL.Host)
+	req.Header.Set("Authorization", "Bearer "+t)
+}
+
+// rateLimitError explains a 403 or 429 caused by an exhausted rate limit,
+// reporting when it resets. Other failures get the generic error.
+func rateLimitError(resp *http.Response) error {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return nil
+	}
+
+	msg := "GitHub API rate limit exceeded"
+	if limit := resp.Header.Get("X-RateLimit-Limit"); limit != "" {
+		msg += " (" + limit + " requests per hour)"
+	}
+	if secs, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		reset := time.Unix(secs, 0)
+		msg += fmt.Sprintf("; resets at %s (in %s)", reset.Local().Format(time.TimeOnly), max(time.Until(reset), 0).Round(time.Second))
+	}
+	if resp.Request == nil || resp.Request.Header.Get("Authorization") == "" {
+		msg += "; set GITHUB_TOKEN or github_token for a higher limit"
+	}
+	return errors.New(msg)
+}
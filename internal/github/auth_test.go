@@ -0,0 +1,76 @@
+package github
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToken(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+	assert.Empty(t, token())
+
+	viper.Set("github_token", "from-config")
+	assert.Equal(t, "from-config", token())
+	t.Setenv("GH_TOKEN", "from-gh")
+	assert.Equal(t, "from-gh", token())
+	t.Setenv("GITHUB_TOKEN", "from-github")
+	assert.Equal(t, "from-github", token())
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GITHUB_TOKEN", "")
+	req, err := http.NewRequest(http.MethodGet, defaultEndpoint, nil)
+	require.NoError(t, err)
+	authorize(req)
+	assert.Empty(t, req.Header.Get("Authorization"))
+
+	t.Setenv("GITHUB_TOKEN", "ghp_secret")
+	authorize(req)
+	assert.Equal(t, "Bearer ghp_secret", req.Header.Get("Authorization"))
+}
+
+func TestAuthorizeRequiresHTTPS(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "ghp_secret")
+	for _, url := range []string{"http://api.github.com/meta", "https://example.com/meta"} {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		authorize(req)
+		assert.Empty(t, req.Header.Get("Authorization"), url)
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	reset := time.Now().Add(23 * time.Minute).Truncate(time.Second)
+	response := func(status int, remaining string, authorized bool) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, defaultEndpoint, nil)
+		if authorized {
+			req.Header.Set("Authorization", "Bearer token")
+		}
+		h := http.Header{}
+		h.Set("X-RateLimit-Limit", "60")
+		h.Set("X-RateLimit-Remaining", remaining)
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		return &http.Response{StatusCode: status, Header: h, Request: req}
+	}
+
+	err := rateLimitError(response(http.StatusForbidden, "0", false))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "GitHub API rate limit exceeded (60 requests per hour); resets at "+reset.Local().Format(time.TimeOnly))
+	assert.Contains(t, err.Error(), "set GITHUB_TOKEN or github_token")
+
+	err = rateLimitError(response(http.StatusTooManyRequests, "0", true))
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "GITHUB_TOKEN", "no token hint when one was sent")
+
+	assert.NoError(t, rateLimitError(response(http.StatusForbidden, "12", false)), "a 403 with quota left is not a rate limit")
+	assert.NoError(t, rateLimitError(response(http.StatusInternalServerError, "0", false)))
+}
@@ -1,7 +1,6 @@
 package github
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"sort"
@@ -14,14 +13,6 @@ type IPRange struct {
 	Service string
 }
 
-type Config struct {
-	Source         string
-	IPType         string
-	FilterServices []string
-	List           string
-	Verbosity      string
-}
-
 // nonCIDRKeys are top-level keys in the /meta payload whose values are not
 // CIDR arrays. They are skipped during parsing.
 var nonCIDRKeys = map[string]struct{}{
@@ -31,25 +22,6 @@ var nonCIDRKeys = map[string]struct{}{
 	"domains":                            {},
 }
 
-func GetIPRanges(ctx context.Context, config Config) error {
-	rawData, err := utils.GetRawData(ctx, config.Source)
-	if err != nil {
-		return err
-	}
-
-	ranges, err := parseMeta(rawData)
-	if err != nil {
-		return err
-	}
-
-	filtered := filtrate(ranges, config.IPType, config.FilterServices)
-	if config.List != "" {
-		return printListedValues(filtered, config.List)
-	}
-	printIPRanges(filtered, config.Verbosity)
-	return nil
-}
-
 func parseMeta(rawData string) ([]IPRange, error) {
 	var raw map[string]json.RawMessage
 	if err := json.Unmarshal([]byte(rawData), &raw); err != nil {
@@ -83,67 +55,3 @@ func parseMeta(rawData string) ([]IPRange, error) {
 	}
 	return ranges, nil
 }
-
-func filtrate(ranges []IPRange, ipType string, filterServices []string) []IPRange {
-	var result []IPRange
-	for _, r := range ranges {
-		if ipType == "ipv4" && !utils.IsIPv4(r.CIDR) {
-			continue
-		}
-		if ipType == "ipv6" && !utils.IsIPv6(r.CIDR) {
-			continue
-		}
-		if len(filterServices) > 0 && !utils.ContainsIgnoreCase(filterServices, r.Service) {
-			continue
-		}
-		result = append(result, r)
-	}
-	return result
-}
-
-func printListedValues(ranges []IPRange, dim string) error {
-	if dim != "services" {
-		return fmt.Errorf("unknown list dimension %q (valid: services)", dim)
-	}
-
-	values := make([]string, 0, len(ranges))
-	for _, r := range ranges {
-		values = append(values, r.Service)
-	}
-	values = utils.DedupeSorted(values)
-	if len(values) == 0 {
-		fmt.Println("No values to display.")
-		return nil
-	}
-	for _, v := range values {
-		fmt.Println(v)
-	}
-	return nil
-}
-
-func printIPRanges(ranges []IPRange, verbosity string) {
-	if len(ranges) == 0 {
-		fmt.Println("No IP ranges to display.")
-		return
-	}
-
-	var printFunc func(IPRange)
-	switch verbosity {
-	case "mini":
-		printFunc = func(r IPRange) {
-			fmt.Printf("%s,%s\n", r.CIDR, r.Service)
-		}
-	case "full":
-		printFunc = func(r IPRange) {
-			fmt.Printf("IP Prefix: %s, Service: %s\n", r.CIDR, r.Service)
-		}
-	default:
-		printFunc = func(r IPRange) {
-			fmt.Println(r.CIDR)
-		}
-	}
-
-	for _, r := range ranges {
-		printFunc(r)
-	}
-}
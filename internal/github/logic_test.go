@@ -1,36 +1,16 @@
 package github
 
 import (
-	"bytes"
 	"context"
-	"io"
-	"os"
 	"path/filepath"
 	"sort"
-	"strings"
 	"testing"
 
+	"github.com/kaumnen/cipr/internal/provider"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func captureStdout(t *testing.T, fn func()) string {
-	t.Helper()
-	oldStdout := os.Stdout
-	r, w, err := os.Pipe()
-	require.NoError(t, err)
-	os.Stdout = w
-
-	fn()
-
-	w.Close()
-	os.Stdout = oldStdout
-
-	var buf bytes.Buffer
-	_, _ = io.Copy(&buf, r)
-	return buf.String()
-}
-
 func TestParseMeta(t *testing.T) {
 	raw := mockGetReq()
 
@@ -88,9 +68,8 @@ func TestParseMeta_Validation(t *testing.T) {
 	})
 }
 
-func TestFiltrate(t *testing.T) {
-	raw := mockGetReq()
-	ranges, err := parseMeta(raw)
+func TestSelect(t *testing.T) {
+	result, err := Provider{}.Parse(mockGetReq())
 	require.NoError(t, err)
 
 	testCases := []struct {
@@ -134,10 +113,11 @@ func TestFiltrate(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := filtrate(ranges, tc.ipType, tc.filterServices)
-			assert.Equal(t, tc.expectedLen, len(result))
+			q := provider.Query{IPType: tc.ipType, Filters: map[string][]string{"service": tc.filterServices}}
+			records := q.Select(result.Records)
+			assert.Equal(t, tc.expectedLen, len(records))
 			if tc.assertService != "" {
-				for _, r := range result {
+				for _, r := range records {
 					assert.Equal(t, tc.assertService, r.Service)
 				}
 			}
@@ -145,117 +125,14 @@ func TestFiltrate(t *testing.T) {
 	}
 }
 
-func TestFiltrateMultipleServices(t *testing.T) {
-	ranges := []IPRange{
-		{CIDR: "1.1.1.0/24", Service: "actions"},
-		{CIDR: "2.2.2.0/24", Service: "web"},
-		{CIDR: "3.3.3.0/24", Service: "api"},
-	}
-
-	assert.Equal(t, ranges[:2], filtrate(ranges, "", []string{"ACTIONS", "web"}))
-}
-
-func TestPrintIPRanges(t *testing.T) {
-	testCases := []struct {
-		name           string
-		ranges         []IPRange
-		verbosity      string
-		expectedOutput string
-	}{
-		{
-			name:           "empty list",
-			ranges:         []IPRange{},
-			verbosity:      "none",
-			expectedOutput: "No IP ranges to display.\n",
-		},
-		{
-			name: "none prints CIDR only",
-			ranges: []IPRange{
-				{CIDR: "192.30.252.0/22", Service: "hooks"},
-				{CIDR: "2606:50c0::/32", Service: "packages"},
-			},
-			verbosity:      "none",
-			expectedOutput: "192.30.252.0/22\n2606:50c0::/32\n",
-		},
-		{
-			name: "mini prints CIDR,service",
-			ranges: []IPRange{
-				{CIDR: "4.148.0.0/16", Service: "actions"},
-			},
-			verbosity:      "mini",
-			expectedOutput: "4.148.0.0/16,actions\n",
-		},
-		{
-			name: "full prints labeled output",
-			ranges: []IPRange{
-				{CIDR: "140.82.112.0/20", Service: "api"},
-				{CIDR: "2a0a:a440::/29", Service: "web"},
-			},
-			verbosity:      "full",
-			expectedOutput: "IP Prefix: 140.82.112.0/20, Service: api\nIP Prefix: 2a0a:a440::/29, Service: web\n",
-		},
-		{
-			name: "invalid verbosity defaults to none",
-			ranges: []IPRange{
-				{CIDR: "192.30.252.153/32", Service: "pages"},
-			},
-			verbosity:      "bogus",
-			expectedOutput: "192.30.252.153/32\n",
-		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			out := captureStdout(t, func() {
-				printIPRanges(tc.ranges, tc.verbosity)
-			})
-			assert.Equal(t, tc.expectedOutput, out)
-		})
-	}
-}
-
-func TestPrintListedValues(t *testing.T) {
-	ranges := []IPRange{
-		{CIDR: "1.1.1.0/24", Service: "web"},
-		{CIDR: "1.1.2.0/24", Service: "actions"},
-		{CIDR: "1.1.3.0/24", Service: "web"},
-		{CIDR: "1.1.4.0/24", Service: "api"},
-	}
-
-	t.Run("services sorted and deduped", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(ranges, "services"))
-		})
-		assert.Equal(t, "actions\napi\nweb\n", out)
-	})
-
-	t.Run("empty input prints placeholder", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(nil, "services"))
-		})
-		assert.Equal(t, "No values to display.\n", out)
-	})
-
-	t.Run("unknown dim returns error", func(t *testing.T) {
-		err := printListedValues(ranges, "bogus")
-		require.Error(t, err)
-		assert.Contains(t, err.Error(), "unknown list dimension")
-	})
-}
-
-func TestGetIPRanges_List(t *testing.T) {
-	cfg := Config{
-		Source:    filepath.Join("..", "testdata", "github_meta_sample.json"),
-		IPType:    "",
-		List:      "services",
-		Verbosity: "none",
-	}
-
-	out := captureStdout(t, func() {
-		require.NoError(t, GetIPRanges(context.Background(), cfg))
+func TestProviderListServices(t *testing.T) {
+	p := Provider{}
+	result, err := p.Load(context.Background(), provider.Query{
+		Source: filepath.Join("..", "testdata", "github_meta_sample.json"),
+		IPType: "both",
 	})
-
-	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	require.NoError(t, err)
+	lines := provider.ListValues(result.Records, p.ListDimensions()[0])
 	require.Len(t, lines, 9)
 	assert.True(t, sort.StringsAreSorted(lines), "services output not sorted: %v", lines)
 
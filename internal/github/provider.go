@@ -0,0 +1,73 @@
+package github
+
+import (
+	"context"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+)
+
+const defaultEndpoint = "https://api.github.com/meta"
+
+func init() {
+	provider.Register(Provider{})
+}
+
+// Provider exposes the GitHub /meta endpoint through the provider registry.
+type Provider struct{}
+
+func (Provider) Name() string  { return "github" }
+func (Provider) Short() string { return "Get GitHub IP ranges." }
+func (Provider) Long() string {
+	return `Get GitHub IPv4 and IPv6 ranges with optional service filtering.`
+}
+
+func (Provider) ConfigKeys() []string { return []string{"github"} }
+
+func (Provider) DefaultEndpoints() map[string]string {
+	return map[string]string{"github": defaultEndpoint}
+}
+
+func (Provider) Columns() []provider.Column {
+	return []provider.Column{
+		{Key: provider.PrefixKey, Label: "IP Prefix"},
+		{Key: "service", Label: "Service"},
+	}
+}
+
+func (Provider) FilterDimensions() []provider.Dimension {
+	return []provider.Dimension{
+		{Key: "service", Plural: "services", Usage: "Filter results by GitHub service (comma-separated; e.g. actions, web, api, git, hooks, pages, packages, importer, github_enterprise_importer)"},
+	}
+}
+
+func (p Provider) ListDimensions() []provider.Dimension { return p.FilterDimensions() }
+
+func (Provider) Parse(raw string) (provider.Result, error) {
+	ranges, err := parseMeta(raw)
+	if err != nil {
+		return provider.Result{}, err
+	}
+	return toProviderResult(ranges)
+}
+
+func (p Provider) Load(ctx context.Context, q provider.Query) (provider.Result, error) {
+	raw, err := utils.GetRawData(ctx, provider.SourceFor(q.Source, "github"))
+	if err != nil {
+		return provider.Result{}, err
+	}
+	return p.Parse(raw)
+}
+
+func toProviderResult(ranges []IPRange) (provider.Result, error) {
+	out := provider.Result{Records: make([]provider.Record, 0, len(ranges))}
+	for _, r := range ranges {
+		rec, err := provider.NewRecord("github", r.CIDR)
+		if err != nil {
+			return provider.Result{}, err
+		}
+		rec.Service = r.Service
+		out.Records = append(out.Records, rec)
+	}
+	return out, nil
+}
@@ -1,34 +1,16 @@
 package icloud
 
 import (
-	"bytes"
 	"context"
-	"io"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/kaumnen/cipr/internal/provider"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func captureStdout(t *testing.T, fn func()) string {
-	t.Helper()
-	oldStdout := os.Stdout
-	r, w, err := os.Pipe()
-	require.NoError(t, err)
-	os.Stdout = w
-
-	fn()
-
-	w.Close()
-	os.Stdout = oldStdout
-
-	var buf bytes.Buffer
-	_, _ = io.Copy(&buf, r)
-	return buf.String()
-}
-
 func TestParseRecords(t *testing.T) {
 	t.Run("empty input is rejected", func(t *testing.T) {
 		_, err := parseRecords("")
@@ -68,53 +50,38 @@ func TestParseRecords(t *testing.T) {
 	})
 }
 
-func TestGetIPRanges_FromFixture(t *testing.T) {
-	cfg := Config{
-		Source:    filepath.Join("..", "testdata", "icloud.csv"),
-		IPType:    "ipv4",
-		Filters:   Filters{Country: []string{"GB"}, City: []string{"London"}},
-		Verbosity: "none",
+func TestProviderLoad_FromFixture(t *testing.T) {
+	q := provider.Query{
+		Source:  filepath.Join("..", "testdata", "icloud.csv"),
+		IPType:  "ipv4",
+		Filters: map[string][]string{"country": {"GB"}, "city": {"London"}},
 	}
 
-	out := captureStdout(t, func() {
-		err := GetIPRanges(context.Background(), cfg)
-		require.NoError(t, err)
-	})
-
-	lines := bytes.Split(bytes.TrimRight([]byte(out), "\n"), []byte("\n"))
-	assert.NotEmpty(t, lines)
-	for _, line := range lines {
-		assert.Contains(t, string(line), "/")
+	result, err := Provider{}.Load(context.Background(), q)
+	require.NoError(t, err)
+	records := q.Select(result.Records)
+	assert.NotEmpty(t, records)
+	for _, r := range records {
+		assert.Equal(t, provider.IPv4, r.Family)
 	}
 }
 
-func TestGetIPRanges_SourceError(t *testing.T) {
-	cfg := Config{
-		Source:    "./does-not-exist/missing.csv",
-		IPType:    "ipv4",
-		Verbosity: "none",
-	}
-	err := GetIPRanges(context.Background(), cfg)
+func TestProviderLoad_SourceError(t *testing.T) {
+	_, err := Provider{}.Load(context.Background(), provider.Query{Source: "./does-not-exist/missing.csv", IPType: "ipv4"})
 	require.Error(t, err)
 }
 
-func TestGetIPRanges_List_Countries(t *testing.T) {
-	cfg := Config{
-		Source:    filepath.Join("..", "testdata", "icloud.csv"),
-		IPType:    "both",
-		List:      "countries",
-		Verbosity: "none",
-	}
-
-	out := captureStdout(t, func() {
-		require.NoError(t, GetIPRanges(context.Background(), cfg))
+func TestProviderList_Countries(t *testing.T) {
+	p := Provider{}
+	result, err := p.Load(context.Background(), provider.Query{
+		Source: filepath.Join("..", "testdata", "icloud.csv"),
+		IPType: "both",
 	})
-
-	lines := bytes.Split(bytes.TrimRight([]byte(out), "\n"), []byte("\n"))
+	require.NoError(t, err)
+	lines := provider.ListValues(result.Records, p.ListDimensions()[0])
 	require.NotEmpty(t, lines)
 	seen := make(map[string]struct{})
-	for _, l := range lines {
-		s := string(l)
+	for _, s := range lines {
 		assert.NotEmpty(t, s, "empty country surfaced")
 		_, dup := seen[s]
 		assert.False(t, dup, "duplicate country %q", s)
@@ -123,113 +90,29 @@ func TestGetIPRanges_List_Countries(t *testing.T) {
 	assert.Contains(t, seen, "GB")
 }
 
-func TestPrintListedValues(t *testing.T) {
-	ranges := []IPRange{
-		{Country: "GB", Region: "GB-EN", City: "London"},
-		{Country: "GB", Region: "GB-EN", City: "London"},
-		{Country: "JP", Region: "JP-13", City: "Tokyo"},
-		{Country: "DE", Region: "", City: ""},
-	}
-
-	t.Run("countries dedupes", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(ranges, "countries"))
-		})
-		assert.Equal(t, "DE\nGB\nJP\n", out)
-	})
-
-	t.Run("cities drops empty", func(t *testing.T) {
-		out := captureStdout(t, func() {
-			require.NoError(t, printListedValues(ranges, "cities"))
-		})
-		assert.Equal(t, "London\nTokyo\n", out)
-	})
-
-	t.Run("unknown dim returns error", func(t *testing.T) {
-		err := printListedValues(ranges, "bogus")
-		require.Error(t, err)
-	})
-}
-
-func TestPrintIPRanges(t *testing.T) {
-	ipRanges := []IPRange{
-		{IPRange: "172.224.224.0/27", Country: "GB", Region: "GB-EN", City: "London"},
-		{IPRange: "2a04:4e41:0030:0007::/64", Country: "JP", Region: "JP-13", City: "Tokyo"},
-	}
-
-	tests := []struct {
-		name      string
-		ipRanges  []IPRange
-		verbosity string
-		expected  string
-	}{
-		{
-			name:      "No IP ranges",
-			ipRanges:  []IPRange{},
-			verbosity: "none",
-			expected:  "No IP ranges to display.\n",
-		},
-		{
-			name:      "Verbosity none",
-			ipRanges:  ipRanges,
-			verbosity: "none",
-			expected:  "172.224.224.0/27\n2a04:4e41:0030:0007::/64\n",
-		},
-		{
-			name:      "Verbosity mini",
-			ipRanges:  ipRanges,
-			verbosity: "mini",
-			expected:  "172.224.224.0/27,GB,GB-EN,London\n2a04:4e41:0030:0007::/64,JP,JP-13,Tokyo\n",
-		},
-		{
-			name:      "Verbosity full",
-			ipRanges:  ipRanges,
-			verbosity: "full",
-			expected:  "IP Range: 172.224.224.0/27, Country: GB, Region: GB-EN, City: London\nIP Range: 2a04:4e41:0030:0007::/64, Country: JP, Region: JP-13, City: Tokyo\n",
-		},
-		{
-			name:      "Default verbosity",
-			ipRanges:  ipRanges,
-			verbosity: "unknown",
-			expected:  "172.224.224.0/27\n2a04:4e41:0030:0007::/64\n",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			out := captureStdout(t, func() {
-				printIPRanges(tt.ipRanges, tt.verbosity)
-			})
-			assert.Equal(t, tt.expected, out)
-		})
-	}
-}
-
-func TestFiltrateIPRanges(t *testing.T) {
-	ipRanges := []IPRange{
-		{"2a02:26f7:f6f9:800::/54", "US", "US-NY", "New York"},
-		{"2a02:26f7:f6f9:a06a::/64", "US", "US-NY", "New York"},
-		{"2a02:26f7:f6fc:800::/54", "US", "US-NY", "New York"},
-		{"2a02:26f7:f6fc:a06a::/64", "US", "US-NY", "New York"},
-		{"2606:54c0:a620::/45", "US", "US-NY", "New York"},
-		{"2a09:bac2:a620::/45", "US", "US-NY", "New York"},
-		{"2a09:bac3:a620::/45", "US", "US-NY", "New York"},
-		{"104.28.129.23/32", "DE", "DE-BE", "Berlin"},
-		{"104.28.129.24/32", "DE", "DE-BE", "Berlin"},
-		{"104.28.129.25/32", "DE", "DE-BE", "Berlin"},
-		{"104.28.129.26/32", "DE", "DE-BE", "Berlin"},
-		{"140.248.17.50/31", "DE", "DE-BE", "Berlin"},
-		{"140.248.34.44/31", "DE", "DE-BE", "Berlin"},
-		{"140.248.36.56/31", "DE", "DE-BE", "Berlin"},
-		{"146.75.166.14/31", "DE", "DE-BE", "Berlin"},
-		{"146.75.169.44/31", "DE", "DE-BE", "Berlin"},
-		{"172.224.240.128/27", "JP", "JP-13", "Tokyo"},
-		{"172.225.46.64/26", "JP", "JP-13", "Tokyo"},
-		{"172.225.46.208/28", "JP", "JP-13", "Tokyo"},
-		{"2a04:4e41:0030:0007::/64", "JP", "JP-13", "Tokyo"},
-		{"2a04:4e41:0035:0006::/64", "JP", "JP-13", "Tokyo"},
-		{"2a04:4e41:0064:000b::/64", "JP", "JP-13", "Tokyo"},
-	}
+func TestSelectIPRanges(t *testing.T) {
+	raw := "2a02:26f7:f6f9:800::/54,US,US-NY,New York\n" +
+		"2a02:26f7:f6f9:a06a::/64,US,US-NY,New York\n" +
+		"2a02:26f7:f6fc:800::/54,US,US-NY,New York\n" +
+		"2a02:26f7:f6fc:a06a::/64,US,US-NY,New York\n" +
+		"2606:54c0:a620::/45,US,US-NY,New York\n" +
+		"2a09:bac2:a620::/45,US,US-NY,New York\n" +
+		"2a09:bac3:a620::/45,US,US-NY,New York\n" +
+		"104.28.129.23/32,DE,DE-BE,Berlin\n" +
+		"104.28.129.24/32,DE,DE-BE,Berlin\n" +
+		"104.28.129.25/32,DE,DE-BE,Berlin\n" +
+		"104.28.129.26/32,DE,DE-BE,Berlin\n" +
+		"140.248.17.50/31,DE,DE-BE,Berlin\n" +
+		"140.248.34.44/31,DE,DE-BE,Berlin\n" +
+		"140.248.36.56/31,DE,DE-BE,Berlin\n" +
+		"146.75.166.14/31,DE,DE-BE,Berlin\n" +
+		"146.75.169.44/31,DE,DE-BE,Berlin\n" +
+		"172.224.240.128/27,JP,JP-13,Tokyo\n" +
+		"172.225.46.64/26,JP,JP-13,Tokyo\n" +
+		"172.225.46.208/28,JP,JP-13,Tokyo\n" +
+		"2a04:4e41:0030:0007::/64,JP,JP-13,Tokyo\n" +
+		"2a04:4e41:0035:0006::/64,JP,JP-13,Tokyo\n" +
+		"2a04:4e41:0064:000b::/64,JP,JP-13,Tokyo\n"
 
 	testCases := []struct {
 		name            string
@@ -237,7 +120,7 @@ func TestFiltrateIPRanges(t *testing.T) {
 		filterCountries []string
 		filterRegions   []string
 		filterCities    []string
-		expected        []IPRange
+		expected        []string
 	}{
 		{
 			name:            "IPv6 US-NY-New York",
@@ -245,14 +128,14 @@ func TestFiltrateIPRanges(t *testing.T) {
 			filterCountries: []string{"US"},
 			filterRegions:   []string{"US-NY"},
 			filterCities:    []string{"New York"},
-			expected: []IPRange{
-				{"2a02:26f7:f6f9:800::/54", "US", "US-NY", "New York"},
-				{"2a02:26f7:f6f9:a06a::/64", "US", "US-NY", "New York"},
-				{"2a02:26f7:f6fc:800::/54", "US", "US-NY", "New York"},
-				{"2a02:26f7:f6fc:a06a::/64", "US", "US-NY", "New York"},
-				{"2606:54c0:a620::/45", "US", "US-NY", "New York"},
-				{"2a09:bac2:a620::/45", "US", "US-NY", "New York"},
-				{"2a09:bac3:a620::/45", "US", "US-NY", "New York"},
+			expected: []string{
+				"2a02:26f7:f6f9:800::/54",
+				"2a02:26f7:f6f9:a06a::/64",
+				"2a02:26f7:f6fc:800::/54",
+				"2a02:26f7:f6fc:a06a::/64",
+				"2606:54c0:a620::/45",
+				"2a09:bac2:a620::/45",
+				"2a09:bac3:a620::/45",
 			},
 		},
 		{
@@ -261,16 +144,16 @@ func TestFiltrateIPRanges(t *testing.T) {
 			filterCountries: []string{"DE"},
 			filterRegions:   []string{"DE-BE"},
 			filterCities:    []string{"Berlin"},
-			expected: []IPRange{
-				{"104.28.129.23/32", "DE", "DE-BE", "Berlin"},
-				{"104.28.129.24/32", "DE", "DE-BE", "Berlin"},
-				{"104.28.129.25/32", "DE", "DE-BE", "Berlin"},
-				{"104.28.129.26/32", "DE", "DE-BE", "Berlin"},
-				{"140.248.17.50/31", "DE", "DE-BE", "Berlin"},
-				{"140.248.34.44/31", "DE", "DE-BE", "Berlin"},
-				{"140.248.36.56/31", "DE", "DE-BE", "Berlin"},
-				{"146.75.166.14/31", "DE", "DE-BE", "Berlin"},
-				{"146.75.169.44/31", "DE", "DE-BE", "Berlin"},
+			expected: []string{
+				"104.28.129.23/32",
+				"104.28.129.24/32",
+				"104.28.129.25/32",
+				"104.28.129.26/32",
+				"140.248.17.50/31",
+				"140.248.34.44/31",
+				"140.248.36.56/31",
+				"146.75.166.14/31",
+				"146.75.169.44/31",
 			},
 		},
 		{
@@ -279,10 +162,10 @@ func TestFiltrateIPRanges(t *testing.T) {
 			filterCountries: []string{},
 			filterRegions:   []string{},
 			filterCities:    []string{"Tokyo"},
-			expected: []IPRange{
-				{"172.224.240.128/27", "JP", "JP-13", "Tokyo"},
-				{"172.225.46.64/26", "JP", "JP-13", "Tokyo"},
-				{"172.225.46.208/28", "JP", "JP-13", "Tokyo"},
+			expected: []string{
+				"172.224.240.128/27",
+				"172.225.46.64/26",
+				"172.225.46.208/28",
 			},
 		},
 		{
@@ -291,16 +174,16 @@ func TestFiltrateIPRanges(t *testing.T) {
 			filterCountries: []string{"DE"},
 			filterRegions:   []string{"DE-BE"},
 			filterCities:    []string{},
-			expected: []IPRange{
-				{"104.28.129.23/32", "DE", "DE-BE", "Berlin"},
-				{"104.28.129.24/32", "DE", "DE-BE", "Berlin"},
-				{"104.28.129.25/32", "DE", "DE-BE", "Berlin"},
-				{"104.28.129.26/32", "DE", "DE-BE", "Berlin"},
-				{"140.248.17.50/31", "DE", "DE-BE", "Berlin"},
-				{"140.248.34.44/31", "DE", "DE-BE", "Berlin"},
-				{"140.248.36.56/31", "DE", "DE-BE", "Berlin"},
-				{"146.75.166.14/31", "DE", "DE-BE", "Berlin"},
-				{"146.75.169.44/31", "DE", "DE-BE", "Berlin"},
+			expected: []string{
+				"104.28.129.23/32",
+				"104.28.129.24/32",
+				"104.28.129.25/32",
+				"104.28.129.26/32",
+				"140.248.17.50/31",
+				"140.248.34.44/31",
+				"140.248.36.56/31",
+				"146.75.166.14/31",
+				"146.75.169.44/31",
 			},
 		},
 		{
@@ -309,14 +192,14 @@ func TestFiltrateIPRanges(t *testing.T) {
 			filterCountries: []string{"US"},
 			filterRegions:   []string{},
 			filterCities:    []string{},
-			expected: []IPRange{
-				{"2a02:26f7:f6f9:800::/54", "US", "US-NY", "New York"},
-				{"2a02:26f7:f6f9:a06a::/64", "US", "US-NY", "New York"},
-				{"2a02:26f7:f6fc:800::/54", "US", "US-NY", "New York"},
-				{"2a02:26f7:f6fc:a06a::/64", "US", "US-NY", "New York"},
-				{"2606:54c0:a620::/45", "US", "US-NY", "New York"},
-				{"2a09:bac2:a620::/45", "US", "US-NY", "New York"},
-				{"2a09:bac3:a620::/45", "US", "US-NY", "New York"},
+			expected: []string{
+				"2a02:26f7:f6f9:800::/54",
+				"2a02:26f7:f6f9:a06a::/64",
+				"2a02:26f7:f6fc:800::/54",
+				"2a02:26f7:f6fc:a06a::/64",
+				"2606:54c0:a620::/45",
+				"2a09:bac2:a620::/45",
+				"2a09:bac3:a620::/45",
 			},
 		},
 		{
@@ -325,17 +208,17 @@ func TestFiltrateIPRanges(t *testing.T) {
 			filterCountries: []string{},
 			filterRegions:   []string{},
 			filterCities:    []string{},
-			expected: []IPRange{
-				{"2a02:26f7:f6f9:800::/54", "US", "US-NY", "New York"},
-				{"2a02:26f7:f6f9:a06a::/64", "US", "US-NY", "New York"},
-				{"2a02:26f7:f6fc:800::/54", "US", "US-NY", "New York"},
-				{"2a02:26f7:f6fc:a06a::/64", "US", "US-NY", "New York"},
-				{"2606:54c0:a620::/45", "US", "US-NY", "New York"},
-				{"2a09:bac2:a620::/45", "US", "US-NY", "New York"},
-				{"2a09:bac3:a620::/45", "US", "US-NY", "New York"},
-				{"2a04:4e41:0030:0007::/64", "JP", "JP-13", "Tokyo"},
-				{"2a04:4e41:0035:0006::/64", "JP", "JP-13", "Tokyo"},
-				{"2a04:4e41:0064:000b::/64", "JP", "JP-13", "Tokyo"},
+			expected: []string{
+				"2a02:26f7:f6f9:800::/54",
+				"2a02:26f7:f6f9:a06a::/64",
+				"2a02:26f7:f6fc:800::/54",
+				"2a02:26f7:f6fc:a06a::/64",
+				"2606:54c0:a620::/45",
+				"2a09:bac2:a620::/45",
+				"2a09:bac3:a620::/45",
+				"2a04:4e41:30:7::/64",
+				"2a04:4e41:35:6::/64",
+				"2a04:4e41:64:b::/64",
 			},
 		},
 		{
@@ -344,10 +227,10 @@ func TestFiltrateIPRanges(t *testing.T) {
 			filterCountries: []string{},
 			filterRegions:   []string{},
 			filterCities:    []string{"Tokyo"},
-			expected: []IPRange{
-				{"172.224.240.128/27", "JP", "JP-13", "Tokyo"},
-				{"172.225.46.64/26", "JP", "JP-13", "Tokyo"},
-				{"172.225.46.208/28", "JP", "JP-13", "Tokyo"},
+			expected: []string{
+				"172.224.240.128/27",
+				"172.225.46.64/26",
+				"172.225.46.208/28",
 			},
 		},
 		{
@@ -356,39 +239,67 @@ func TestFiltrateIPRanges(t *testing.T) {
 			filterCountries: []string{"US", "DE", "JP"},
 			filterRegions:   []string{"US-NY", "DE-BE", "JP-13"},
 			filterCities:    []string{"New York", "Berlin", "Tokyo"},
-			expected: []IPRange{
-				{"104.28.129.23/32", "DE", "DE-BE", "Berlin"},
-				{"104.28.129.24/32", "DE", "DE-BE", "Berlin"},
-				{"104.28.129.25/32", "DE", "DE-BE", "Berlin"},
-				{"104.28.129.26/32", "DE", "DE-BE", "Berlin"},
-				{"140.248.17.50/31", "DE", "DE-BE", "Berlin"},
-				{"140.248.34.44/31", "DE", "DE-BE", "Berlin"},
-				{"140.248.36.56/31", "DE", "DE-BE", "Berlin"},
-				{"146.75.166.14/31", "DE", "DE-BE", "Berlin"},
-				{"146.75.169.44/31", "DE", "DE-BE", "Berlin"},
-				{"172.224.240.128/27", "JP", "JP-13", "Tokyo"},
-				{"172.225.46.64/26", "JP", "JP-13", "Tokyo"},
-				{"172.225.46.208/28", "JP", "JP-13", "Tokyo"},
+			expected: []string{
+				"104.28.129.23/32",
+				"104.28.129.24/32",
+				"104.28.129.25/32",
+				"104.28.129.26/32",
+				"140.248.17.50/31",
+				"140.248.34.44/31",
+				"140.248.36.56/31",
+				"146.75.166.14/31",
+				"146.75.169.44/31",
+				"172.224.240.128/27",
+				"172.225.46.64/26",
+				"172.225.46.208/28",
 			},
 		},
 	}
 
+	result, err := Provider{}.Parse(raw)
+	require.NoError(t, err)
 	for _, tc := range testCases {
-		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			config := Config{
+			q := provider.Query{
 				IPType: tc.ipType,
-				Filters: Filters{
-					Country: tc.filterCountries,
-					Region:  tc.filterRegions,
-					City:    tc.filterCities,
+				Filters: map[string][]string{
+					"country": tc.filterCountries,
+					"region":  tc.filterRegions,
+					"city":    tc.filterCities,
 				},
-				Verbosity: "none",
 			}
 
-			result := filtrateIPRanges(ipRanges, config)
-
-			assert.Equal(t, tc.expected, result, "Test case '%s' failed", tc.name)
+			var got []string
+			for _, rec := range q.Select(result.Records) {
+				got = append(got, rec.Prefix.String())
+			}
+			assert.Equal(t, tc.expected, got)
 		})
 	}
 }
+
+func TestProviderStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "egress.csv")
+	require.NoError(t, os.WriteFile(path, []byte("172.224.224.0/27,GB,GB-EN,London\n2a02:26f7::/64,US,US-NY,New York\n"), 0o644))
+	q := provider.Query{Source: path, IPType: "both"}
+
+	var got []string
+	metadata, err := Provider{}.Stream(context.Background(), q, func(rec provider.Record) error {
+		got = append(got, rec.Prefix.String()+" "+rec.Get("city"))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Nil(t, metadata)
+	assert.Equal(t, []string{"172.224.224.0/27 London", "2a02:26f7::/64 New York"}, got)
+
+	t.Run("invalid row fails after earlier rows were yielded", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("172.224.224.0/27,GB\nnot-a-cidr,GB\n"), 0o644))
+		calls := 0
+		_, err := Provider{}.Stream(context.Background(), q, func(provider.Record) error {
+			calls++
+			return nil
+		})
+		assert.ErrorContains(t, err, "row 2")
+		assert.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,91 @@
+package icloud
+
+import (
+	"context"
+
+	"github.com/kaumnen/cipr/internal/provider"
+	"github.com/kaumnen/cipr/internal/utils"
+)
+
+const defaultEndpoint = "https://mask-api.icloud.com/egress-ip-ranges.csv"
+
+func init() {
+	provider.Register(Provider{})
+}
+
+// Provider exposes the iCloud Private Relay egress CSV through the provider
+// registry.
+type Provider struct{}
+
+func (Provider) Name() string  { return "icloud" }
+func (Provider) Short() string { return "Get iCloud private relay IP ranges." }
+func (Provider) Long() string  { return `Get iCloud private relay IPv4 and IPv6 ranges.` }
+
+func (Provider) ConfigKeys() []string { return []string{"icloud"} }
+
+func (Provider) DefaultEndpoints() map[string]string {
+	return map[string]string{"icloud": defaultEndpoint}
+}
+
+func (Provider) Columns() []provider.Column {
+	return []provider.Column{
+		{Key: provider.PrefixKey, Label: "IP Range"},
+		{Key: "country", Label: "Country"},
+		{Key: "region", Label: "Region"},
+		{Key: "city", Label: "City"},
+	}
+}
+
+func (Provider) FilterDimensions() []provider.Dimension {
+	return []provider.Dimension{
+		{Key: "country", Plural: "countries", Usage: "Filter results by country"},
+		{Key: "region", Plural: "regions", Usage: "Filter results by region"},
+		{Key: "city", Plural: "cities", Usage: `Filter results by city (use quotes for names with spaces, e.g. "New York")`},
+	}
+}
+
+func (p Provider) ListDimensions() []provider.Dimension { return p.FilterDimensions() }
+
+func (Provider) Parse(raw string) (provider.Result, error) {
+	var out provider.Result
+	err := decode(raw, func(rec provider.Record) error {
+		out.Records = append(out.Records, rec)
+		return nil
+	})
+	if err != nil {
+		return provider.Result{}, err
+	}
+	return out, nil
+}
+
+func (p Provider) Load(ctx context.Context, q provider.Query) (provider.Result, error) {
+	raw, err := utils.GetRawData(ctx, provider.SourceFor(q.Source, "icloud"))
+	if err != nil {
+		return provider.Result{}, err
+	}
+	return p.Parse(raw)
+}
+
+// Stream yields the records of the egress CSV row by row; the feed has no
+// metadata.
+func (Provider) Stream(ctx context.Context, q provider.Query, yield func(provider.Record) error) (map[string]string, error) {
+	raw, err := utils.GetRawData(ctx, provider.SourceFor(q.Source, "icloud"))
+	if err != nil {
+		return nil, err
+	}
+	return nil, decode(raw, yield)
+}
+
+// decode turns each CSV row of raw into a record for yield.
+func decode(raw string, yield func(provider.Record) error) error {
+	return scanRecords(raw, func(r IPRange) error {
+		rec, err := provider.NewRecord("icloud", r.IPRange)
+		if err != nil {
+			return err
+		}
+		rec.Country = r.Country
+		rec.Region = r.Region
+		rec.Attributes = map[string]string{"city": r.City}
+		return yield(rec)
+	})
+}
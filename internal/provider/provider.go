@@ -0,0 +1,225 @@
+// Package provider is the registry of IP range sources and the library API
+// to them: provider.Lookup(name) returns a Provider whose Load fetches the
+// source and returns typed records with the source's metadata (AWS sync
+// token, Azure change number, ...). Provider packages export nothing else.
+package provider
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kaumnen/cipr/internal/utils"
+)
+
+// Provider describes one source of published IP ranges. Each provider lives
+// in its own package under internal/ and registers itself from init; the
+// cobra subcommands, configure, and the default config file are all
+// generated from the registry.
+type Provider interface {
+	// Name is the subcommand name, e.g. "aws" or "do".
+	Name() string
+	// Short and Long are the subcommand's help texts.
+	Short() string
+	Long() string
+	// ConfigKeys are the cipr.toml key prefixes the provider reads
+	// (<key>_endpoint, <key>_local_file, <key>_cache_ttl).
+	ConfigKeys() []string
+	// DefaultEndpoints maps each config key to its default URL.
+	DefaultEndpoints() map[string]string
+	// Columns describes the fields of each record in output order. The
+	// first column is always the prefix.
+	Columns() []Column
+	// FilterDimensions are the attributes exposed as --filter-<flag> flags.
+	FilterDimensions() []Dimension
+	// ListDimensions are the attributes accepted by --list.
+	ListDimensions() []Dimension
+	// Parse decodes one raw payload into unfiltered records.
+	Parse(raw string) (Result, error)
+	// Load fetches the records of q.Source. It leaves q.IPType and
+	// q.Filters to Query.Select, though it may skip fetching a feed that
+	// q.IPType rules out.
+	Load(ctx context.Context, q Query) (Result, error)
+}
+
+// CompositeFilterer is implemented by providers that accept the positional
+// --filter syntax (for example "region,service" for azure).
+type CompositeFilterer interface {
+	FiltersFromComposite(filter string) map[string][]string
+}
+
+// Streamer is implemented by providers whose feeds are large enough that
+// holding every record at once matters (azure, icloud). Stream reads
+// q.Source as Load does but passes each unfiltered record to yield as soon
+// as it is decoded, then returns the source metadata. An error from yield
+// stops decoding and is returned as is; a feed found invalid part way
+// through fails after some records were yielded.
+type Streamer interface {
+	Stream(ctx context.Context, q Query, yield func(Record) error) (map[string]string, error)
+}
+
+// Column is one output field of a record; Key is anything Record.Get
+// accepts.
+type Column struct {
+	Key   string
+	Label string
+}
+
+// Dimension is an attribute that records can be filtered or listed by.
+type Dimension struct {
+	// Key is the Record.Get key; the flag name is the key
+	// with underscores replaced by dashes.
+	Key string
+	// Plural is the --list value, e.g. "regions".
+	Plural string
+	// Usage is the help text of the --filter-<flag> flag.
+	Usage string
+}
+
+// Query selects the records a provider loads.
+type Query struct {
+	// Source is a --source value: "config" (or the legacy "hosted"), an
+	// HTTP(S) URL, or a local path.
+	Source string
+	// IPType is "ipv4", "ipv6", or "both".
+	IPType string
+	// Filters maps a Dimension.Key to the accepted values. Matching is
+	// case-insensitive; an empty slice matches everything.
+	Filters map[string][]string
+}
+
+// Select returns the records of the address family q.IPType that match
+// q.Filters. It and Selects are the one place filters are applied, whatever
+// the provider.
+func (q Query) Select(records []Record) []Record {
+	out := records[:0:0]
+	for _, r := range records {
+		if q.Selects(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Selects reports whether Select keeps r.
+func (q Query) Selects(r Record) bool {
+	if (q.IPType == "ipv4" && r.Family != IPv4) || (q.IPType == "ipv6" && r.Family != IPv6) {
+		return false
+	}
+	return r.Matches(q.Filters)
+}
+
+// Result is the records loaded from a provider plus source metadata such
+// as sync tokens or change numbers.
+type Result struct {
+	Records  []Record
+	Metadata map[string]string
+}
+
+// ListValues returns the sorted, deduplicated, non-empty values of dim
+// across records.
+func ListValues(records []Record, dim Dimension) []string {
+	values := make([]string, 0, len(records))
+	for _, r := range records {
+		values = append(values, r.Get(dim.Key))
+	}
+	return utils.DedupeSorted(values)
+}
+
+var registry = struct {
+	sync.Mutex
+	providers map[string]Provider
+}{providers: make(map[string]Provider)}
+
+// utils.GetRawData resolves config keys to default URLs through the
+// registry rather than a copy of it.
+func init() {
+	utils.SetDefaultEndpointLookup(DefaultEndpoint)
+}
+
+// Register adds p to the registry. It panics on a duplicate name, like flag
+// and sql do.
+func Register(p Provider) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, dup := registry.providers[p.Name()]; dup {
+		panic(fmt.Sprintf("provider: Register called twice for %q", p.Name()))
+	}
+	registry.providers[p.Name()] = p
+}
+
+// All returns the registered providers sorted by name.
+func All() []Provider {
+	registry.Lock()
+	defer registry.Unlock()
+	out := make([]Provider, 0, len(registry.providers))
+	for _, p := range registry.providers {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out
+}
+
+// Lookup returns the provider registered under name.
+func Lookup(name string) (Provider, bool) {
+	registry.Lock()
+	defer registry.Unlock()
+	p, ok := registry.providers[name]
+	return p, ok
+}
+
+// ResolveKeys resolves a provider name to its config keys. A config key of
+// a multi-feed provider (cloudflare_ipv4) selects just that feed.
+func ResolveKeys(name string) ([]string, error) {
+	name = strings.ToLower(name)
+	if p, ok := Lookup(name); ok {
+		return p.ConfigKeys(), nil
+	}
+	if slices.Contains(ConfigKeys(), name) {
+		return []string{name}, nil
+	}
+	var names []string
+	for _, p := range All() {
+		names = append(names, p.Name())
+	}
+	return nil, fmt.Errorf("unknown provider %q (valid: %s)", name, strings.Join(names, ", "))
+}
+
+// SourceFor resolves a --source value for the feed read from config key.
+// "config" selects key itself, and "history:<name>@<at>" selects the
+// snapshot of key when name resolves to it (see ResolveKeys), so
+// "history:do@latest" reads the snapshots stored under "digitalocean".
+// Anything else is returned verbatim.
+func SourceFor(source, key string) string {
+	name, at, ok := utils.ParseHistorySource(source)
+	if !ok {
+		return utils.SourceFor(source, key)
+	}
+	if keys, err := ResolveKeys(name); err == nil && slices.Contains(keys, key) {
+		return utils.HistorySource(key, at)
+	}
+	return source
+}
+
+// ConfigKeys returns every registered config key, sorted.
+func ConfigKeys() []string {
+	var keys []string
+	for _, p := range All() {
+		keys = append(keys, p.ConfigKeys()...)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DefaultEndpoint returns the default URL of a registered config key.
+func DefaultEndpoint(key string) (string, bool) {
+	for _, p := range All() {
+		if endpoint, ok := p.DefaultEndpoints()[key]; ok {
+			return endpoint, true
+		}
+	}
+	return "", false
+}
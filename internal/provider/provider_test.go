@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/kaumnen/cipr/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+	name string
+	keys []string
+}
+
+func (f fakeProvider) Name() string { return f.name }
+func (fakeProvider) Short() string  { return "" }
+func (fakeProvider) Long() string   { return "" }
+func (f fakeProvider) ConfigKeys() []string {
+	if len(f.keys) > 0 {
+		return f.keys
+	}
+	return []string{f.name}
+}
+func (f fakeProvider) DefaultEndpoints() map[string]string {
+	endpoints := make(map[string]string)
+	for _, key := range f.ConfigKeys() {
+		endpoints[key] = "https://" + key + ".example/ranges"
+	}
+	return endpoints
+}
+func (fakeProvider) Columns() []Column                           { return []Column{{Key: PrefixKey, Label: "IP Prefix"}} }
+func (fakeProvider) FilterDimensions() []Dimension               { return nil }
+func (fakeProvider) ListDimensions() []Dimension                 { return nil }
+func (fakeProvider) Parse(string) (Result, error)                { return Result{}, nil }
+func (fakeProvider) Load(context.Context, Query) (Result, error) { return Result{}, nil }
+
+func TestListValues(t *testing.T) {
+	records := []Record{
+		{Region: "us-east-1", Attributes: map[string]string{"scope": "us-east-1"}},
+		{Region: "eu-west-1", Attributes: map[string]string{"scope": "global"}},
+		{Region: "us-east-1"},
+		{},
+	}
+	assert.Equal(t, []string{"eu-west-1", "us-east-1"}, ListValues(records, Dimension{Key: "region", Plural: "regions"}))
+	assert.Equal(t, []string{"global", "us-east-1"}, ListValues(records, Dimension{Key: "scope", Plural: "scopes"}))
+}
+
+func TestQuerySelect(t *testing.T) {
+	records := []Record{
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24"), Family: IPv4, Region: "us-east-1", Service: "EC2"},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32"), Family: IPv6, Region: "us-east-1", Service: "S3"},
+		{Prefix: netip.MustParsePrefix("198.51.100.0/24"), Family: IPv4, Region: "eu-west-1", Service: "S3",
+			Attributes: map[string]string{"network_border_group": "eu-west-1"}},
+	}
+
+	assert.Equal(t, records, Query{IPType: "both"}.Select(records))
+	assert.Equal(t, []Record{records[0], records[2]}, Query{IPType: "ipv4"}.Select(records))
+	assert.Equal(t, []Record{records[1]}, Query{IPType: "ipv6"}.Select(records))
+	assert.Equal(t, []Record{records[1], records[2]}, Query{Filters: map[string][]string{"service": {"s3"}}}.Select(records))
+	assert.Equal(t, []Record{records[2]}, Query{IPType: "ipv4", Filters: map[string][]string{
+		"service":              {"EC2", "S3"},
+		"network_border_group": {"EU-WEST-1"},
+	}}.Select(records))
+	assert.Empty(t, Query{Filters: map[string][]string{"region": {"ap-south-1"}}}.Select(records))
+}
+
+func TestRegister(t *testing.T) {
+	Register(fakeProvider{name: "registry-test"})
+
+	p, ok := Lookup("registry-test")
+	require.True(t, ok)
+	assert.Equal(t, "registry-test", p.Name())
+	assert.Contains(t, ConfigKeys(), "registry-test")
+
+	endpoint, ok := DefaultEndpoint("registry-test")
+	require.True(t, ok)
+	assert.Equal(t, "https://registry-test.example/ranges", endpoint)
+	endpoint, ok = utils.DefaultEndpoint("registry-test")
+	require.True(t, ok)
+	assert.Equal(t, "https://registry-test.example/ranges", endpoint)
+
+	assert.Panics(t, func() { Register(fakeProvider{name: "registry-test"}) })
+}
+
+func TestResolveKeysAndSourceFor(t *testing.T) {
+	Register(fakeProvider{name: "fk", keys: []string{"fakekey"}})
+	Register(fakeProvider{name: "fakemulti", keys: []string{"fakemulti_a", "fakemulti_b"}})
+
+	keys, err := ResolveKeys("FK")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fakekey"}, keys)
+	keys, err = ResolveKeys("fakemulti_b")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fakemulti_b"}, keys)
+	_, err = ResolveKeys("oracle")
+	assert.ErrorContains(t, err, `unknown provider "oracle"`)
+
+	assert.Equal(t, "fakekey", SourceFor("config", "fakekey"))
+	assert.Equal(t, "/tmp/ranges.json", SourceFor("/tmp/ranges.json", "fakekey"))
+	assert.Equal(t, "history:fakekey@latest", SourceFor("history:fk", "fakekey"), "the provider name resolves to its config key")
+	assert.Equal(t, "history:fakekey@2026-09-01", SourceFor("history:fakekey@2026-09-01", "fakekey"))
+	assert.Equal(t, "history:fakemulti_a@latest", SourceFor("history:fakemulti@latest", "fakemulti_a"))
+	assert.Equal(t, "history:other@latest", SourceFor("history:other@latest", "fakekey"), "another provider's history is left alone")
+}
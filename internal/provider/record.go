@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/kaumnen/cipr/internal/utils"
+)
+
+// Family is the address family of a record.
+type Family string
+
+const (
+	IPv4 Family = "ipv4"
+	IPv6 Family = "ipv6"
+)
+
+// Keys of the fields every record carries. Record.Get resolves these before
+// looking at Attributes.
+const (
+	PrefixKey   = "prefix"
+	ProviderKey = "provider"
+	FamilyKey   = "family"
+	RegionKey   = "region"
+	ServiceKey  = "service"
+	CountryKey  = "country"
+)
+
+// Record is one published prefix in a provider-independent shape. Region,
+// Service and Country are the normalized view shared by all providers (GCP's
+// scope becomes Region, for example); Attributes keeps the remaining
+// provider-specific fields under their own names.
+type Record struct {
+	Prefix   netip.Prefix
+	Provider string
+	Family   Family
+
+	Region  string
+	Service string
+	Country string
+
+	Attributes map[string]string
+}
+
+// NewRecord parses prefix and returns a record for the named provider.
+// Bare addresses are accepted as single-address prefixes.
+func NewRecord(providerName, prefix string) (Record, error) {
+	p, err := parsePrefix(prefix)
+	if err != nil {
+		return Record{}, fmt.Errorf("parse %s prefix %q: %w", providerName, prefix, err)
+	}
+	return Record{Prefix: p, Provider: providerName, Family: FamilyOf(p)}, nil
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	return netip.ParsePrefix(s)
+}
+
+// FamilyOf reports the address family of p.
+func FamilyOf(p netip.Prefix) Family {
+	if p.Addr().Is4() {
+		return IPv4
+	}
+	return IPv6
+}
+
+// Get returns the value of the field with key, or "" when unset. The common
+// keys (PrefixKey, ProviderKey, ...) are served from the record's fields and
+// everything else from Attributes.
+func (r Record) Get(key string) string {
+	switch key {
+	case PrefixKey:
+		return r.Prefix.String()
+	case ProviderKey:
+		return r.Provider
+	case FamilyKey:
+		return string(r.Family)
+	case RegionKey:
+		return r.Region
+	case ServiceKey:
+		return r.Service
+	case CountryKey:
+		return r.Country
+	}
+	return r.Attributes[key]
+}
+
+// Matches reports whether r passes filters: for every key with values, r's
+// value must be one of them (case-insensitive). Query.Select applies it to
+// every provider's records.
+func (r Record) Matches(filters map[string][]string) bool {
+	for key, values := range filters {
+		if len(values) > 0 && !utils.ContainsIgnoreCase(values, r.Get(key)) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesAny reports whether r's value for any key in filters is one of that
+// key's values. It is the test used by the --exclude-* flags.
+func (r Record) MatchesAny(filters map[string][]string) bool {
+	for key, values := range filters {
+		if len(values) > 0 && utils.ContainsIgnoreCase(values, r.Get(key)) {
+			return true
+		}
+	}
+	return false
+}
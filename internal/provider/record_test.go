@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRecord(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefix     string
+		wantPrefix string
+		wantFamily Family
+		wantErr    bool
+	}{
+		{name: "ipv4", prefix: "3.5.140.0/22", wantPrefix: "3.5.140.0/22", wantFamily: IPv4},
+		{name: "ipv6", prefix: "2600:1f18::/36", wantPrefix: "2600:1f18::/36", wantFamily: IPv6},
+		{name: "surrounding space", prefix: " 1.1.1.0/24\n", wantPrefix: "1.1.1.0/24", wantFamily: IPv4},
+		{name: "bare ipv4 address", prefix: "192.0.2.1", wantPrefix: "192.0.2.1/32", wantFamily: IPv4},
+		{name: "bare ipv6 address", prefix: "2001:db8::1", wantPrefix: "2001:db8::1/128", wantFamily: IPv6},
+		{name: "invalid", prefix: "not-a-prefix", wantErr: true},
+		{name: "bad length", prefix: "10.0.0.0/33", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := NewRecord("test", tt.prefix)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "parse test prefix")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPrefix, rec.Prefix.String())
+			assert.Equal(t, tt.wantFamily, rec.Family)
+			assert.Equal(t, "test", rec.Provider)
+		})
+	}
+}
+
+func TestRecordGet(t *testing.T) {
+	r := Record{
+		Prefix:     netip.MustParsePrefix("10.0.0.0/8"),
+		Provider:   "gcp",
+		Family:     IPv4,
+		Region:     "us-central1",
+		Service:    "Google Cloud",
+		Attributes: map[string]string{"scope": "us-central1"},
+	}
+	assert.Equal(t, "10.0.0.0/8", r.Get(PrefixKey))
+	assert.Equal(t, "gcp", r.Get(ProviderKey))
+	assert.Equal(t, "ipv4", r.Get(FamilyKey))
+	assert.Equal(t, "us-central1", r.Get(RegionKey))
+	assert.Equal(t, "Google Cloud", r.Get(ServiceKey))
+	assert.Empty(t, r.Get(CountryKey))
+	assert.Equal(t, "us-central1", r.Get("scope"))
+	assert.Empty(t, r.Get("zip"))
+}
+
+func TestRecordMatches(t *testing.T) {
+	r := Record{Region: "us-east-1", Service: "EC2", Attributes: map[string]string{"network_border_group": "us-east-1"}}
+
+	assert.True(t, r.Matches(nil))
+	assert.True(t, r.Matches(map[string][]string{"region": {"US-EAST-1", "eu-west-1"}, "service": nil}))
+	assert.False(t, r.Matches(map[string][]string{"region": {"us-east-1"}, "service": {"AMAZON"}}))
+
+	assert.False(t, r.MatchesAny(nil))
+	assert.True(t, r.MatchesAny(map[string][]string{"region": {"eu-west-1"}, "service": {"ec2"}}))
+	assert.False(t, r.MatchesAny(map[string][]string{"network_border_group": {"us-east-1-wl1"}}))
+}
@@ -6,49 +6,217 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
 // GetCached wraps fetch with the standard cache-aside policy: read on hit,
-// run fetch on miss, write the result. If key is "" or --no-cache is set,
-// fetch runs unconditionally and the cache is left untouched. Cache write
-// failures are logged but never fatal. Suitable for providers (e.g. azure)
-// whose fetch path doesn't reduce to a single utils.GetRawData call.
+// run fetch on miss, write the result. An expired entry whose metadata holds
+// ETag or Last-Modified validators is revalidated: the endpoint request made
+// by fetch becomes conditional, and a 304 answer refreshes the cached body
+// instead of downloading it again. When the fetch fails, an expired entry
+// still within the stale_if_error window is served instead (see serveStale).
+// Under WithCacheRefresh the body is always fetched in full and a failure
+// is returned as is. In offline mode the cache entry is served whatever its
+// age, and a missing entry is an error. If key is "" or --no-cache is set,
+// fetch runs unconditionally and the cache is left untouched. Entries are
+// aged by the fetch time in their metadata and served only when the body
+// matches the recorded checksum; a mismatch counts as a miss. Fetched bodies
+// of keyed sources are also recorded in the history store when it is
+// enabled. Requests made by fetch use key's retry and timeout settings (see
+// DoRequest). Cache write failures are logged but never fatal.
 func GetCached(ctx context.Context, key string, fetch func(context.Context) (string, error)) (string, error) {
 	if key == "" {
 		Debugf("cache: bypassed for unkeyed source")
 		return fetch(ctx)
 	}
+	ctx = withRequestKey(ctx, key)
+	if Offline() {
+		return readOffline(key)
+	}
 	if viper.GetBool("no_cache") {
 		Debugf("cache: bypassed for %s by configuration", key)
-		return fetch(ctx)
+		body, err := fetch(ctx)
+		if err != nil {
+			return "", err
+		}
+		recordHistory(key, body)
+		return body, nil
 	}
 	ttl := resolveCacheTTL(key)
-	if ttl > 0 {
+	refresh := forcedRefresh(ctx)
+	if refresh {
+		Debugf("cache: forced refresh of %s", key)
+	} else if ttl > 0 {
 		if data, ok, _ := readCache(key, ttl); ok {
-			Debugf("cache: hit for %s (age %s, ttl %s)", key, cacheAge(key), ttl)
-			fmt.Fprintf(os.Stderr, "Using cached IP ranges for %s (age %s)\n", key, cacheAge(key))
+			age := cacheAge(key)
+			Debugf("cache: hit for %s (age %s, ttl %s)", key, age, ttl)
+			fmt.Fprintf(os.Stderr, "Using cached IP ranges for %s (age %s)\n", key, age)
 			return string(data), nil
 		}
 		Debugf("cache: miss or stale entry for %s (ttl %s)", key, ttl)
 	} else {
 		Debugf("cache: disabled for %s", key)
 	}
-	body, err := fetch(ctx)
+
+	cf := &conditionalFetch{}
+	if meta, ok := readCacheMeta(key); ok && meta.hasValidators() && !refresh {
+		cf.prev = meta
+		Debugf("cache: revalidating %s (etag %q, last-modified %q)", key, meta.ETag, meta.LastModified)
+	}
+	body, err := fetch(withConditionalFetch(ctx, cf))
+	if errors.Is(err, errNotModified) && cf.notModified {
+		if data, ok := refreshCache(key, cf.prev); ok {
+			fmt.Fprintf(os.Stderr, "Cached IP ranges for %s are unchanged upstream; refreshed cache\n", key)
+			return string(data), nil
+		}
+		Debugf("cache: %s not modified but cached body unusable; fetching in full", key)
+		body, err = fetch(withConditionalFetch(ctx, &conditionalFetch{}))
+	}
 	if err != nil {
+		if data, ok := serveStale(ctx, key, ttl, err); ok {
+			return string(data), nil
+		}
 		return "", err
 	}
+	recordHistory(key, body)
 	if werr := writeCache(key, []byte(body)); werr != nil {
 		fmt.Fprintln(os.Stderr, "Warning: cache write failed:", werr)
 		Debugf("cache: write failed for %s: %v", key, werr)
-	} else {
-		Debugf("cache: wrote %d bytes for %s", len(body), key)
+		return body, nil
+	}
+	Debugf("cache: wrote %d bytes for %s", len(body), key)
+	meta := cf.got
+	meta.FetchedAt = time.Now().UTC()
+	meta.describe([]byte(body))
+	if werr := writeCacheMeta(key, meta); werr != nil {
+		Debugf("cache: metadata write failed for %s: %v", key, werr)
 	}
 	return body, nil
 }
 
+// readOffline serves key's cache entry regardless of TTL, for offline mode.
+func readOffline(key string) (string, error) {
+	data, err := ReadCacheEntry(key)
+	if err != nil {
+		return "", fmt.Errorf("offline mode: %w; fetch it once while online, or point %s at a file with: cipr configure %s --local-file <path>", err, key, key)
+	}
+	Debugf("cache: offline, serving %s entry (age %s)", key, cacheAge(key))
+	fmt.Fprintf(os.Stderr, "Using cached IP ranges for %s (offline, age %s)\n", key, cacheAge(key))
+	return string(data), nil
+}
+
+// refreshCache marks key's cached body as freshly validated after a 304
+// and returns it.
+func refreshCache(key string, meta cacheMeta) ([]byte, bool) {
+	path, err := cachePath(key)
+	if err != nil {
+		return nil, false
+	}
+	data, _, err := loadCacheEntry(key)
+	if err != nil {
+		Debugf("cache: cannot reuse %s entry: %v", key, err)
+		return nil, false
+	}
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		Debugf("cache: touch failed for %s: %v", key, err)
+	}
+	meta.FetchedAt = now.UTC()
+	if err := writeCacheMeta(key, meta); err != nil {
+		Debugf("cache: metadata write failed for %s: %v", key, err)
+	}
+	Debugf("cache: %s not modified; reusing %d cached bytes", key, len(data))
+	return data, true
+}
+
+// resolveStaleIfError returns how long past its TTL a cache entry may be
+// served when fetching fails: <key>_stale_if_error, else stale_if_error.
+// Unset or "0s" disables the fallback.
+func resolveStaleIfError(key string) time.Duration {
+	raw, name := keyedSetting(key, "stale_if_error")
+	if raw == "" {
+		return 0
+	}
+	window, err := time.ParseDuration(raw)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: invalid %s=%q, not serving stale cache on errors\n", name, raw)
+		return 0
+	}
+	return window
+}
+
+// StaleReport collects the cache keys a load served from expired entries
+// because fetching them failed.
+type StaleReport struct {
+	mu   sync.Mutex
+	ages map[string]time.Duration
+}
+
+type staleReportKey struct{}
+
+// WithStaleReport returns a context whose cache reads record stale
+// fallbacks in the returned report.
+func WithStaleReport(ctx context.Context) (context.Context, *StaleReport) {
+	r := &StaleReport{ages: make(map[string]time.Duration)}
+	return context.WithValue(ctx, staleReportKey{}, r), r
+}
+
+func staleReportFrom(ctx context.Context) *StaleReport {
+	r, _ := ctx.Value(staleReportKey{}).(*StaleReport)
+	return r
+}
+
+func (r *StaleReport) add(key string, age time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ages[key] = age
+}
+
+// Oldest returns the age of the oldest stale entry served, if any.
+func (r *StaleReport) Oldest() (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var oldest time.Duration
+	for _, age := range r.ages {
+		oldest = max(oldest, age)
+	}
+	return oldest, len(r.ages) > 0
+}
+
+// serveStale returns key's expired cache entry after fetchErr when it is
+// at most stale_if_error past its TTL. Canceled fetches are not retried
+// from the cache, and neither are forced refreshes.
+func serveStale(ctx context.Context, key string, ttl time.Duration, fetchErr error) ([]byte, bool) {
+	window := resolveStaleIfError(key)
+	if window <= 0 || ctx.Err() != nil || forcedRefresh(ctx) {
+		return nil, false
+	}
+	data, fetchedAt, err := loadCacheEntry(key)
+	if err != nil {
+		if unusableCacheEntry(err) {
+			Debugf("cache: not serving stale %s entry: %v", key, err)
+		}
+		return nil, false
+	}
+	age := time.Since(fetchedAt).Round(time.Second)
+	if age > max(ttl, 0)+window {
+		Debugf("cache: %s entry (age %s) is past stale_if_error %s", key, age, window)
+		return nil, false
+	}
+
+	staleReportFrom(ctx).add(key, age)
+	fmt.Fprintf(os.Stderr, "Warning: fetching %s failed (%v); using stale cached IP ranges (age %s)\n", key, fetchErr, age)
+	Debugf("cache: served stale %s entry (age %s, ttl %s, stale_if_error %s)", key, age, ttl, window)
+	return data, true
+}
+
 func resolveCacheTTL(key string) time.Duration {
 	raw := viper.GetString(key + "_cache_ttl")
 	if raw == "" {
@@ -85,25 +253,50 @@ func cachePath(key string) (string, error) {
 // miss / stale / unreadable / corrupt — callers should fetch in that case.
 // Errors are returned only for genuinely unexpected conditions.
 func readCache(key string, ttl time.Duration) ([]byte, bool, error) {
-	path, err := cachePath(key)
-	if err != nil {
+	if _, err := cachePath(key); err != nil {
 		return nil, false, err
 	}
-	info, err := os.Stat(path)
+	data, fetchedAt, err := loadCacheEntry(key)
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil, false, nil
+		if unusableCacheEntry(err) {
+			Debugf("cache: discarding %s entry: %v", key, err)
 		}
 		return nil, false, nil
 	}
-	if time.Since(info.ModTime()) >= ttl {
+	if time.Since(fetchedAt) >= ttl {
 		return nil, false, nil
 	}
+	return data, true, nil
+}
+
+// loadCacheEntry reads key's cached body and verifies it against its
+// metadata, returning when it was fetched. A body failing verification
+// yields an error wrapping errCacheCorrupt, and one without a sidecar
+// recording its checksum and fetch time an error wrapping
+// errCacheUnverified: the modification time alone is never trusted.
+func loadCacheEntry(key string) ([]byte, time.Time, error) {
+	path, err := cachePath(key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, false, nil
+		return nil, time.Time{}, err
 	}
-	return data, true, nil
+	meta, ok := readCacheMeta(key)
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("%w: missing %s.meta", errCacheUnverified, key)
+	}
+	if err := meta.verify(data); err != nil {
+		return nil, time.Time{}, err
+	}
+	return data, meta.FetchedAt, nil
+}
+
+// unusableCacheEntry reports whether err from loadCacheEntry means the entry
+// exists but must not be served.
+func unusableCacheEntry(err error) bool {
+	return errors.Is(err, errCacheCorrupt) || errors.Is(err, errCacheUnverified)
 }
 
 // writeCache writes data atomically (tmp + rename). Caller logs and
@@ -113,6 +306,10 @@ func writeCache(key string, data []byte) error {
 	if err != nil {
 		return err
 	}
+	return writeFileAtomic(path, data)
+}
+
+func writeFileAtomic(path string, data []byte) error {
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0o700); err != nil {
 		return fmt.Errorf("create cache dir: %w", err)
@@ -138,16 +335,255 @@ func writeCache(key string, data []byte) error {
 	return nil
 }
 
-// cacheAge returns how old the cache entry for key is, or 0 if absent.
-// Used only for log messages; never returns an error.
+// cacheAge returns how long ago the cache entry for key was fetched, or 0
+// if absent. Used only for log messages; never returns an error.
 func cacheAge(key string) time.Duration {
+	at, ok := cacheFetchedAt(key)
+	if !ok {
+		return 0
+	}
+	return time.Since(at).Round(time.Second)
+}
+
+// cacheFetchedAt returns when key's entry was fetched according to its
+// metadata. It does not verify the body.
+func cacheFetchedAt(key string) (time.Time, bool) {
+	meta, ok := readCacheMeta(key)
+	if !ok || meta.FetchedAt.IsZero() {
+		return time.Time{}, false
+	}
+	return meta.FetchedAt, true
+}
+
+// CacheEntry describes a stored cache entry.
+type CacheEntry struct {
+	Key   string
+	Path  string
+	Size  int64
+	Age   time.Duration
+	TTL   time.Duration
+	Fresh bool
+	// URL is the endpoint the body was fetched from, when recorded.
+	URL string
+	// SyncToken is the feed's own version marker, when it has one.
+	SyncToken string
+	// Corrupt is set when the body does not match its recorded checksum;
+	// such an entry is never served.
+	Corrupt bool
+	// Unverified is set when the entry has no metadata recording its
+	// checksum and fetch time; it is never served either.
+	Unverified bool
+}
+
+// CacheDir returns the directory holding cache entries.
+func CacheDir() (string, error) {
+	return cacheDir()
+}
+
+// ListCache returns the stored cache entries, sorted by key.
+func ListCache() ([]CacheEntry, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read cache dir: %w", err)
+	}
+	var out []CacheEntry
+	for _, f := range files {
+		key, ok := strings.CutSuffix(f.Name(), ".cache")
+		if !ok || f.IsDir() {
+			continue
+		}
+		if entry, ok := StatCache(key); ok {
+			out = append(out, entry)
+		}
+	}
+	return out, nil
+}
+
+// StatCache describes key's cache entry; ok is false when there is none.
+func StatCache(key string) (CacheEntry, bool) {
 	path, err := cachePath(key)
 	if err != nil {
-		return 0
+		return CacheEntry{}, false
 	}
 	info, err := os.Stat(path)
 	if err != nil {
-		return 0
+		return CacheEntry{}, false
+	}
+	meta, _ := readCacheMeta(key)
+	fetchedAt := meta.FetchedAt
+	if fetchedAt.IsZero() {
+		fetchedAt = info.ModTime()
+	}
+	entry := CacheEntry{
+		Key:       key,
+		Path:      path,
+		Size:      info.Size(),
+		Age:       time.Since(fetchedAt).Round(time.Second),
+		TTL:       resolveCacheTTL(key),
+		URL:       meta.URL,
+		SyncToken: meta.SyncToken,
+	}
+	_, _, err = loadCacheEntry(key)
+	entry.Corrupt = errors.Is(err, errCacheCorrupt)
+	entry.Unverified = errors.Is(err, errCacheUnverified)
+	entry.Fresh = err == nil && entry.TTL > 0 && time.Since(fetchedAt) < entry.TTL
+	return entry, true
+}
+
+// ClearCache deletes key's cache entry and its metadata. It reports whether
+// there was an entry.
+func ClearCache(key string) (bool, error) {
+	path, err := cachePath(key)
+	if err != nil {
+		return false, err
+	}
+	metaPath, err := cacheMetaPath(key)
+	if err != nil {
+		return false, err
+	}
+	removed := false
+	for _, p := range []string{path, metaPath} {
+		switch err := os.Remove(p); {
+		case err == nil:
+			removed = true
+		case !errors.Is(err, os.ErrNotExist):
+			return removed, fmt.Errorf("clear cache for %s: %w", key, err)
+		}
+	}
+	return removed, nil
+}
+
+// ClearAllCache deletes every cache entry with its metadata, plus temporary
+// files left by interrupted writes, and returns how many files were removed.
+// Derived files such as the match index and anything else in the directory
+// are left alone.
+func ClearAllCache() (int, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return 0, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("read cache dir: %w", err)
+	}
+	n := 0
+	for _, f := range files {
+		if f.IsDir() || !isCacheFile(f.Name()) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, f.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return n, fmt.Errorf("clear cache: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// isCacheFile reports whether name is a cache body, its metadata sidecar, or
+// a temporary file of an atomic write.
+func isCacheFile(name string) bool {
+	for _, ext := range []string{".cache", ".meta", ".tmp"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+type cacheRefreshKey struct{}
+
+// WithCacheRefresh returns a context under which GetCached skips fresh
+// cache entries and validators, fetching and storing every body in full.
+func WithCacheRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheRefreshKey{}, true)
+}
+
+func forcedRefresh(ctx context.Context) bool {
+	refresh, _ := ctx.Value(cacheRefreshKey{}).(bool)
+	return refresh
+}
+
+// ReadCacheEntry returns the cached body for key regardless of its age, for
+// commands that inspect the cache rather than serve from it.
+func ReadCacheEntry(key string) ([]byte, error) {
+	data, _, err := loadCacheEntry(key)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("no cache entry for %s", key)
+	case errors.Is(err, errCacheCorrupt):
+		return nil, fmt.Errorf("cache entry for %s is corrupt (%w); clear it with: cipr cache clear %s", key, err, key)
+	case errors.Is(err, errCacheUnverified):
+		return nil, fmt.Errorf("cache entry for %s cannot be verified (%w); refetch it with: cipr cache refresh %s", key, err, key)
+	case err != nil:
+		return nil, fmt.Errorf("read cache for %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// SourceFingerprint identifies the data GetRawData would return for the
+// configured source key without reading it: the local file override or a
+// fresh cache entry, by size and modification time. ok is false when the
+// next read would go to the network, so nothing derived from the source
+// can be trusted to be current.
+func SourceFingerprint(key string) (fingerprint string, ok bool) {
+	if localFile := viper.GetString(key + "_local_file"); localFile != "" {
+		info, err := os.Stat(localFile)
+		if err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("file:%s:%d:%d", localFile, info.Size(), info.ModTime().UnixNano()), true
+	}
+	if viper.GetBool("no_cache") {
+		return "", false
+	}
+	ttl := resolveCacheTTL(key)
+	if ttl <= 0 {
+		return "", false
+	}
+	path, err := cachePath(key)
+	if err != nil {
+		return "", false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	meta, ok := readCacheMeta(key)
+	if !ok || meta.SHA256 == "" || meta.FetchedAt.IsZero() || time.Since(meta.FetchedAt) >= ttl {
+		return "", false
+	}
+	return fmt.Sprintf("cache:%d:%d:%s", info.Size(), info.ModTime().UnixNano(), meta.SHA256), true
+}
+
+// ReadCacheFile returns the contents of a derived file (such as an index)
+// stored in the cache directory under name.
+func ReadCacheFile(name string) ([]byte, bool) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// WriteCacheFile atomically stores a derived file in the cache directory.
+func WriteCacheFile(name string, data []byte) error {
+	dir, err := cacheDir()
+	if err != nil {
+		return err
 	}
-	return time.Since(info.ModTime()).Round(time.Second)
+	return writeFileAtomic(filepath.Join(dir, name), data)
 }
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errCacheCorrupt marks a cached body that does not match the length or
+// checksum recorded in its metadata.
+var errCacheCorrupt = errors.New("cached body does not match its metadata")
+
+// errCacheUnverified marks a cached body without the metadata needed to
+// date and verify it: no sidecar, or one without a checksum or fetch time.
+// Such an entry is treated as a miss rather than trusted by its mtime.
+var errCacheUnverified = errors.New("cached body has no checksum metadata")
+
+// cacheMeta is stored next to a cache entry as <key>.meta. It records where
+// and when the body was fetched, the validators the server sent with it (so
+// an expired entry can be revalidated with a conditional request), and the
+// body's length and SHA-256 so a truncated or replaced body is detected.
+// Freshness is judged by FetchedAt, not the file's modification time.
+type cacheMeta struct {
+	URL          string    `json:"url,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	SHA256       string    `json:"sha256,omitempty"`
+	Length       int64     `json:"length"`
+	SyncToken    string    `json:"sync_token,omitempty"`
+}
+
+func (m cacheMeta) hasValidators() bool {
+	return m.URL != "" && (m.ETag != "" || m.LastModified != "")
+}
+
+// describe fills in the length, checksum and sync token of body.
+func (m *cacheMeta) describe(body []byte) {
+	sum := sha256.Sum256(body)
+	m.SHA256 = hex.EncodeToString(sum[:])
+	m.Length = int64(len(body))
+	m.SyncToken = syncTokenOf(body)
+}
+
+// verify checks body against the recorded length and checksum. Metadata
+// lacking the checksum or the fetch time verifies nothing.
+func (m cacheMeta) verify(body []byte) error {
+	if m.SHA256 == "" || m.FetchedAt.IsZero() {
+		return errCacheUnverified
+	}
+	if int64(len(body)) != m.Length {
+		return fmt.Errorf("%w: %d bytes, expected %d", errCacheCorrupt, len(body), m.Length)
+	}
+	if sum := sha256.Sum256(body); hex.EncodeToString(sum[:]) != m.SHA256 {
+		return fmt.Errorf("%w: sha256 mismatch", errCacheCorrupt)
+	}
+	return nil
+}
+
+// syncTokenOf returns the top-level "syncToken" of a JSON feed (AWS and
+// Google publish one), or "" for other bodies.
+func syncTokenOf(body []byte) string {
+	if !strings.Contains(string(body[:min(len(body), 4096)]), `"syncToken"`) {
+		return ""
+	}
+	var feed struct {
+		SyncToken string `json:"syncToken"`
+	}
+	if err := json.Unmarshal(body, &feed); err != nil {
+		return ""
+	}
+	return feed.SyncToken
+}
+
+func cacheMetaPath(key string) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, key+".meta"), nil
+}
+
+// readCacheMeta returns the metadata of key's cache entry. A missing or
+// unreadable sidecar yields ok=false; the entry is then never served.
+func readCacheMeta(key string) (cacheMeta, bool) {
+	path, err := cacheMetaPath(key)
+	if err != nil {
+		return cacheMeta{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cacheMeta{}, false
+	}
+	var meta cacheMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		Debugf("cache: ignoring unreadable metadata for %s: %v", key, err)
+		return cacheMeta{}, false
+	}
+	return meta, true
+}
+
+func writeCacheMeta(key string, meta cacheMeta) error {
+	path, err := cacheMetaPath(key)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode cache metadata: %w", err)
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
@@ -1,11 +1,14 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,11 +39,21 @@ func TestReadCache_Miss(t *testing.T) {
 	assert.Nil(t, data)
 }
 
+// storeCacheEntry writes body as key's cache entry with metadata dating it
+// age ago, as GetCached would have.
+func storeCacheEntry(t *testing.T, key string, body []byte, age time.Duration) {
+	t.Helper()
+	meta := cacheMeta{FetchedAt: time.Now().Add(-age).UTC()}
+	meta.describe(body)
+	assert.NoError(t, writeCache(key, body))
+	assert.NoError(t, writeCacheMeta(key, meta))
+}
+
 func TestWriteAndReadCache_RoundTrip(t *testing.T) {
 	t.Setenv("XDG_CACHE_HOME", t.TempDir())
 
 	want := []byte("payload")
-	assert.NoError(t, writeCache("rt", want))
+	storeCacheEntry(t, "rt", want, 0)
 
 	got, hit, err := readCache("rt", time.Hour)
 	assert.NoError(t, err)
@@ -48,25 +61,63 @@ func TestWriteAndReadCache_RoundTrip(t *testing.T) {
 	assert.Equal(t, want, got)
 }
 
-func TestReadCache_StaleByMtime(t *testing.T) {
+func TestReadCache_Stale(t *testing.T) {
 	t.Setenv("XDG_CACHE_HOME", t.TempDir())
 
-	assert.NoError(t, writeCache("stale", []byte("payload")))
-
-	path, err := cachePath("stale")
-	assert.NoError(t, err)
-	old := time.Now().Add(-2 * time.Hour)
-	assert.NoError(t, os.Chtimes(path, old, old))
+	storeCacheEntry(t, "stale", []byte("payload"), 2*time.Hour)
 
 	_, hit, err := readCache("stale", time.Hour)
 	assert.NoError(t, err)
 	assert.False(t, hit, "entry older than TTL should miss")
 }
 
+func TestReadCache_RequiresMetadata(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(viper.Reset)
+	viper.Set("stale_if_error", "72h")
+
+	// A body without a sidecar, touched to look new, as after deleting the
+	// .meta file or copying the cache directory without it.
+	assert.NoError(t, writeCache("bare", []byte("payload")))
+	path, err := cachePath("bare")
+	assert.NoError(t, err)
+	now := time.Now()
+	assert.NoError(t, os.Chtimes(path, now, now))
+
+	_, hit, err := readCache("bare", time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, hit, "an entry without metadata is a miss whatever its mtime")
+	_, ok := serveStale(context.Background(), "bare", time.Hour, errors.New("down"))
+	assert.False(t, ok)
+	_, err = ReadCacheEntry("bare")
+	assert.ErrorContains(t, err, "cache entry for bare cannot be verified")
+	entry, ok := StatCache("bare")
+	assert.True(t, ok)
+	assert.True(t, entry.Unverified)
+	assert.False(t, entry.Fresh)
+
+	assert.NoError(t, writeCacheMeta("bare", cacheMeta{URL: "https://example.com", FetchedAt: now.UTC()}))
+	_, hit, err = readCache("bare", time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, hit, "metadata without a checksum verifies nothing")
+
+	fetches := 0
+	got, err := GetCached(context.Background(), "bare", func(context.Context) (string, error) {
+		fetches++
+		return "fresh", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "fresh", got)
+	assert.Equal(t, 1, fetches, "the touched entry is refetched")
+	data, err := ReadCacheEntry("bare")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("fresh"), data)
+}
+
 func TestReadCache_TTLZero(t *testing.T) {
 	t.Setenv("XDG_CACHE_HOME", t.TempDir())
 
-	assert.NoError(t, writeCache("zero", []byte("payload")))
+	storeCacheEntry(t, "zero", []byte("payload"), 0)
 
 	_, hit, err := readCache("zero", 0)
 	assert.NoError(t, err)
@@ -83,3 +134,240 @@ func TestWriteCache_CreatesDir(t *testing.T) {
 	assert.NoError(t, err)
 	assert.False(t, info.IsDir())
 }
+
+func TestSourceFingerprint(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+
+	_, ok := SourceFingerprint("fp")
+	assert.False(t, ok, "missing cache entry has no fingerprint")
+
+	assert.NoError(t, writeCache("fp", []byte("payload")))
+	_, ok = SourceFingerprint("fp")
+	assert.False(t, ok, "an entry without metadata has no fingerprint")
+
+	storeCacheEntry(t, "fp", []byte("payload"), 0)
+	first, ok := SourceFingerprint("fp")
+	assert.True(t, ok)
+
+	backdateCacheEntry(t, "fp", time.Minute)
+	second, ok := SourceFingerprint("fp")
+	assert.True(t, ok)
+	assert.NotEqual(t, first, second, "rewritten cache changes the fingerprint")
+
+	viper.Set("fp_cache_ttl", "30s")
+	_, ok = SourceFingerprint("fp")
+	assert.False(t, ok, "stale cache entry has no fingerprint")
+
+	local := filepath.Join(t.TempDir(), "ranges.json")
+	assert.NoError(t, os.WriteFile(local, []byte("{}"), 0o600))
+	viper.Set("fp_local_file", local)
+	fp, ok := SourceFingerprint("fp")
+	assert.True(t, ok)
+	assert.Contains(t, fp, local)
+}
+
+func TestWriteAndReadCacheFile(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	_, ok := ReadCacheFile("index.idx")
+	assert.False(t, ok)
+	assert.NoError(t, WriteCacheFile("index.idx", []byte("data")))
+	data, ok := ReadCacheFile("index.idx")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("data"), data)
+}
+
+func TestReadCacheEntry_IgnoresAge(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	_, err := ReadCacheEntry("absent")
+	assert.EqualError(t, err, "no cache entry for absent")
+
+	storeCacheEntry(t, "old", []byte("payload"), 48*time.Hour)
+
+	data, err := ReadCacheEntry("old")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("payload"), data)
+}
+
+func TestListCacheAndStat(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(viper.Reset)
+	viper.Set("gcp_cache_ttl", "1h")
+
+	entries, err := ListCache()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	gcpMeta := cacheMeta{URL: "https://example.com/gcp.json", FetchedAt: time.Now().UTC()}
+	gcpMeta.describe([]byte("gcp body"))
+	assert.NoError(t, writeCache("gcp", []byte("gcp body")))
+	assert.NoError(t, writeCacheMeta("gcp", gcpMeta))
+	storeCacheEntry(t, "aws", []byte("aws"), 48*time.Hour)
+	assert.NoError(t, WriteCacheFile("match-0123.idx", []byte("index")))
+
+	entries, err = ListCache()
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "aws", entries[0].Key)
+		assert.False(t, entries[0].Fresh)
+		assert.Equal(t, 48*time.Hour, entries[0].Age)
+		assert.Equal(t, "gcp", entries[1].Key)
+		assert.True(t, entries[1].Fresh)
+		assert.Equal(t, time.Hour, entries[1].TTL)
+		assert.Equal(t, int64(8), entries[1].Size)
+		assert.Equal(t, "https://example.com/gcp.json", entries[1].URL)
+	}
+}
+
+func TestClearCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	removed, err := ClearCache("aws")
+	assert.NoError(t, err)
+	assert.False(t, removed)
+
+	assert.NoError(t, writeCache("aws", []byte("aws")))
+	assert.NoError(t, writeCacheMeta("aws", cacheMeta{URL: "https://example.com"}))
+	assert.NoError(t, writeCache("gcp", []byte("gcp")))
+	removed, err = ClearCache("aws")
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	_, ok := readCacheMeta("aws")
+	assert.False(t, ok, "metadata goes with the entry")
+
+	assert.NoError(t, writeCacheMeta("gcp", cacheMeta{URL: "https://example.com"}))
+	assert.NoError(t, WriteCacheFile("gcp.cache.123.tmp", []byte("partial")))
+	assert.NoError(t, WriteCacheFile("match-abc.idx", []byte("index")))
+	assert.NoError(t, WriteCacheFile("notes.txt", []byte("mine")))
+	n, err := ClearAllCache()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	entries, err := ListCache()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+	dir, err := CacheDir()
+	assert.NoError(t, err)
+	for _, kept := range []string{"match-abc.idx", "notes.txt"} {
+		assert.FileExists(t, filepath.Join(dir, kept), "clear all leaves non-cache files")
+	}
+}
+
+func TestGetCached_ForcedRefresh(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(viper.Reset)
+	viper.Set("stale_if_error", "72h")
+
+	storeCacheEntry(t, "aws", []byte("cached"), 0)
+	fetches := 0
+	fetch := func(context.Context) (string, error) {
+		fetches++
+		return "fresh", nil
+	}
+
+	got, err := GetCached(context.Background(), "aws", fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, "cached", got)
+
+	got, err = GetCached(WithCacheRefresh(context.Background()), "aws", fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, "fresh", got)
+	assert.Equal(t, 1, fetches)
+
+	_, err = GetCached(WithCacheRefresh(context.Background()), "aws", func(context.Context) (string, error) {
+		return "", errors.New("upstream down")
+	})
+	assert.EqualError(t, err, "upstream down", "a forced refresh never falls back to the cache")
+}
+
+// backdateCacheEntry makes key's entry look fetched age ago, in both its
+// metadata and its modification time.
+func backdateCacheEntry(t *testing.T, key string, age time.Duration) {
+	t.Helper()
+	at := time.Now().Add(-age)
+	meta, ok := readCacheMeta(key)
+	if ok {
+		meta.FetchedAt = at.UTC()
+		assert.NoError(t, writeCacheMeta(key, meta))
+	}
+	path, err := cachePath(key)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chtimes(path, at, at))
+}
+
+func TestReadCache_AgesByFetchTime(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	body := []byte("payload")
+	meta := cacheMeta{FetchedAt: time.Now().Add(-2 * time.Hour).UTC()}
+	meta.describe(body)
+	assert.NoError(t, writeCache("aged", body))
+	assert.NoError(t, writeCacheMeta("aged", meta))
+
+	_, hit, err := readCache("aged", time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, hit, "a recent mtime does not make an old fetch fresh")
+	assert.Equal(t, 2*time.Hour, cacheAge("aged"))
+
+	backdateCacheEntry(t, "aged", 0)
+	_, hit, err = readCache("aged", time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, hit)
+}
+
+func TestReadCache_VerifiesChecksum(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(viper.Reset)
+	viper.Set("stale_if_error", "72h")
+
+	body := []byte(`{"syncToken":"1700000000","prefixes":[]}`)
+	meta := cacheMeta{URL: "https://example.com", FetchedAt: time.Now().UTC()}
+	meta.describe(body)
+	assert.Equal(t, "1700000000", meta.SyncToken)
+	assert.Equal(t, int64(len(body)), meta.Length)
+	assert.NoError(t, writeCache("sum", body))
+	assert.NoError(t, writeCacheMeta("sum", meta))
+
+	got, hit, err := readCache("sum", time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, body, got)
+
+	for name, corrupt := range map[string][]byte{
+		"truncated": body[:10],
+		"replaced":  []byte(`{"syncToken":"1700000000","prefixes":{}}`),
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, writeCache("sum", corrupt))
+
+			_, hit, err := readCache("sum", time.Hour)
+			assert.NoError(t, err)
+			assert.False(t, hit, "a body not matching its checksum is a miss")
+			_, ok := serveStale(context.Background(), "sum", time.Hour, errors.New("down"))
+			assert.False(t, ok, "a corrupt body is not served stale either")
+			_, err = ReadCacheEntry("sum")
+			assert.ErrorContains(t, err, "cache entry for sum is corrupt")
+
+			entry, ok := StatCache("sum")
+			assert.True(t, ok)
+			assert.True(t, entry.Corrupt)
+			assert.False(t, entry.Fresh)
+		})
+	}
+}
+
+func TestGetCached_WritesMetadata(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(viper.Reset)
+
+	body := `{"syncToken":"42","prefixes":[]}`
+	_, err := GetCached(context.Background(), "meta", func(context.Context) (string, error) { return body, nil })
+	assert.NoError(t, err)
+
+	meta, ok := readCacheMeta("meta")
+	assert.True(t, ok)
+	assert.NoError(t, meta.verify([]byte(body)))
+	assert.Equal(t, "42", meta.SyncToken)
+	assert.WithinDuration(t, time.Now(), meta.FetchedAt, time.Minute)
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// errNotModified is returned by loadFromEndpoint when the server answers a
+// conditional request with 304 Not Modified.
+var errNotModified = errors.New("not modified")
+
+// conditionalFetch carries validators between GetCached and the endpoint
+// request made by its fetch function. prev holds the validators of the
+// cached body; loadFromEndpoint sends them when it requests the same URL
+// and records the validators of a new body in got.
+type conditionalFetch struct {
+	prev        cacheMeta
+	got         cacheMeta
+	notModified bool
+}
+
+type conditionalFetchKey struct{}
+
+func withConditionalFetch(ctx context.Context, cf *conditionalFetch) context.Context {
+	return context.WithValue(ctx, conditionalFetchKey{}, cf)
+}
+
+func conditionalFetchFrom(ctx context.Context) *conditionalFetch {
+	cf, _ := ctx.Value(conditionalFetchKey{}).(*conditionalFetch)
+	return cf
+}
+
+// applyValidators adds If-None-Match / If-Modified-Since to req when the
+// cached body was fetched from the same URL. It reports whether any were
+// sent.
+func (cf *conditionalFetch) applyValidators(req *http.Request, url string) bool {
+	if cf == nil || !cf.prev.hasValidators() || cf.prev.URL != url {
+		return false
+	}
+	if cf.prev.ETag != "" {
+		req.Header.Set("If-None-Match", cf.prev.ETag)
+	}
+	if cf.prev.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cf.prev.LastModified)
+	}
+	return true
+}
+
+// recordValidators keeps the validators of a freshly downloaded body.
+func (cf *conditionalFetch) recordValidators(url string, header http.Header) {
+	if cf == nil {
+		return
+	}
+	cf.got = cacheMeta{URL: url, ETag: header.Get("ETag"), LastModified: header.Get("Last-Modified")}
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Redacted replaces secrets in logs and configuration output, as
+// SanitizeURL does for URL userinfo.
+const Redacted = "xxxxx"
+
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// CustomHeaders returns the <key>_headers table of source key as request
+// headers, expanding ${VAR} references from the environment:
+//
+//	[aws_headers]
+//	Authorization = "Bearer ${ARTIFACTORY_TOKEN}"
+//
+// A reference to an unset variable is an error rather than an empty value.
+// DoRequest sends them only to the host of the key's endpoint and drops them
+// when a redirect leaves it.
+func CustomHeaders(key string) (http.Header, error) {
+	table := viper.GetStringMapString(key + "_headers")
+	if len(table) == 0 {
+		return nil, nil
+	}
+	headers := make(http.Header, len(table))
+	for name, value := range table {
+		var missing string
+		expanded := envReference.ReplaceAllStringFunc(value, func(ref string) string {
+			v, ok := os.LookupEnv(envReference.FindStringSubmatch(ref)[1])
+			if !ok && missing == "" {
+				missing = ref
+			}
+			return v
+		})
+		if missing != "" {
+			return nil, fmt.Errorf("%s_headers.%s references unset environment variable %s", key, http.CanonicalHeaderKey(name), missing)
+		}
+		headers.Set(name, expanded)
+	}
+	return headers, nil
+}
+
+// endpointHost returns the host (with any port) of the endpoint configured
+// for source key, the only host its <key>_headers are sent to. It is "" when
+// the key has no endpoint.
+func endpointHost(key string) string {
+	endpoint := viper.GetString(key + "_endpoint")
+	if endpoint == "" {
+		endpoint, _ = DefaultEndpoint(key)
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// stripCustomHeaders removes the <key>_headers of source key from a
+// redirected request whose host differs from the one before it.
+func stripCustomHeaders(key string, req *http.Request, via []*http.Request) {
+	if len(via) == 0 || strings.EqualFold(req.URL.Host, via[len(via)-1].URL.Host) {
+		return
+	}
+	names := CustomHeaderNames(key)
+	if len(names) == 0 {
+		return
+	}
+	for _, name := range names {
+		req.Header.Del(name)
+	}
+	Debugf("http: dropping %s_headers on redirect to %s", key, SanitizeURL(req.URL.String()))
+}
+
+// CustomHeaderNames returns the canonical names of the headers configured
+// for source key, sorted, without resolving their values.
+func CustomHeaderNames(key string) []string {
+	table := viper.GetStringMapString(key + "_headers")
+	names := make([]string, 0, len(table))
+	for name := range table {
+		names = append(names, http.CanonicalHeaderKey(name))
+	}
+	slices.Sort(names)
+	return names
+}
+
+// RedactHeaders formats header names with their values redacted, for debug
+// logs.
+func RedactHeaders(names []string) string {
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+Redacted)
+	}
+	return strings.Join(parts, ", ")
+}
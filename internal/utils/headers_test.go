@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setHeadersConfig loads a TOML config the way cipr.toml is read, so table
+// keys are lowercased by viper as in real use.
+func setHeadersConfig(t *testing.T, config string) {
+	t.Helper()
+	t.Cleanup(func() { viper.Reset() })
+	viper.SetConfigType("toml")
+	require.NoError(t, viper.ReadConfig(strings.NewReader(config)))
+}
+
+func TestCustomHeaders(t *testing.T) {
+	t.Setenv("ARTIFACTORY_TOKEN", "s3cret")
+	setHeadersConfig(t, `
+[aws_headers]
+Authorization = "Bearer ${ARTIFACTORY_TOKEN}"
+X-Api-Key = "plain$value"
+`)
+
+	headers, err := CustomHeaders("aws")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer s3cret", headers.Get("Authorization"))
+	assert.Equal(t, "plain$value", headers.Get("X-Api-Key"), "only ${VAR} references are expanded")
+	assert.Equal(t, []string{"Authorization", "X-Api-Key"}, CustomHeaderNames("aws"))
+
+	headers, err = CustomHeaders("gcp")
+	assert.NoError(t, err)
+	assert.Empty(t, headers)
+	assert.Empty(t, CustomHeaderNames("gcp"))
+}
+
+func TestCustomHeaders_UnsetVariable(t *testing.T) {
+	setHeadersConfig(t, `
+[aws_headers]
+Authorization = "Bearer ${CIPR_TEST_UNSET_TOKEN}"
+`)
+	_, err := CustomHeaders("aws")
+	assert.EqualError(t, err, "aws_headers.Authorization references unset environment variable ${CIPR_TEST_UNSET_TOKEN}")
+}
+
+func TestGetRawData_SendsCustomHeaders(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("ARTIFACTORY_TOKEN", "s3cret")
+
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		_, _ = w.Write([]byte("payload"))
+	}))
+	defer srv.Close()
+	setHeadersConfig(t, `
+cachetest_endpoint = "`+srv.URL+`"
+
+[cachetest_headers]
+Authorization = "Bearer ${ARTIFACTORY_TOKEN}"
+User-Agent = "mirror-client"
+`)
+
+	var output bytes.Buffer
+	t.Cleanup(setDebugWriter(&output))
+	SetDebug(true)
+	t.Cleanup(func() { SetDebug(false) })
+
+	_, err := GetRawData(context.Background(), "cachetest")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer s3cret", got.Get("Authorization"))
+	assert.Equal(t, "mirror-client", got.Get("User-Agent"), "configured headers override the defaults")
+	assert.Contains(t, output.String(), "Authorization=xxxxx, User-Agent=xxxxx")
+	assert.NotContains(t, output.String(), "s3cret")
+
+	_, err = GetRawData(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.Empty(t, got.Get("Authorization"), "headers are only sent for their own source key")
+}
+
+func TestCustomHeadersStayOnEndpointHost(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	var other http.Header
+	otherSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		other = r.Header.Clone()
+		_, _ = w.Write([]byte("payload"))
+	}))
+	defer otherSrv.Close()
+	var endpoint http.Header
+	endpointSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint = r.Header.Clone()
+		http.Redirect(w, r, otherSrv.URL+"/ranges.json", http.StatusFound)
+	}))
+	defer endpointSrv.Close()
+	setHeadersConfig(t, `
+cachetest_endpoint = "`+endpointSrv.URL+`"
+no_cache = true
+
+[cachetest_headers]
+X-Api-Key = "s3cret"
+`)
+
+	_, err := GetRawData(context.Background(), "cachetest")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", endpoint.Get("X-Api-Key"))
+	assert.Empty(t, other.Get("X-Api-Key"), "headers are dropped on a cross-host redirect")
+
+	other = nil
+	_, err = GetCached(context.Background(), "cachetest", func(ctx context.Context) (string, error) {
+		return loadFromEndpoint(ctx, otherSrv.URL+"/download.json")
+	})
+	require.NoError(t, err)
+	assert.Empty(t, other.Get("X-Api-Key"), "headers are not sent to other hosts fetched under the key")
+}
@@ -8,35 +8,51 @@ import (
 	"github.com/spf13/viper"
 )
 
-// DefaultEndpoints maps a provider config-key prefix to its default URL.
-// createDefaultConfig writes <key>_endpoint and <key>_local_file for each entry.
-var DefaultEndpoints = map[string]string{
-	"aws":             "https://ip-ranges.amazonaws.com/ip-ranges.json",
-	"azure":           "https://www.microsoft.com/en-us/download/details.aspx?id=56519",
-	"cloudflare_ipv4": "https://www.cloudflare.com/ips-v4/",
-	"cloudflare_ipv6": "https://www.cloudflare.com/ips-v6/",
-	"icloud":          "https://mask-api.icloud.com/egress-ip-ranges.csv",
-	"digitalocean":    "https://digitalocean.com/geo/google.csv",
-	"gcp":             "https://www.gstatic.com/ipranges/cloud.json",
-	"github":          "https://api.github.com/meta",
+// defaultEndpointLookup answers DefaultEndpoint. The provider package
+// installs its registry lookup from init, so the defaults have one home and
+// utils does not import the registry.
+var defaultEndpointLookup func(key string) (string, bool)
+
+// SetDefaultEndpointLookup makes DefaultEndpoint answer from lookup. It is
+// meant to be called from an init function, before any fetch.
+func SetDefaultEndpointLookup(lookup func(key string) (string, bool)) {
+	defaultEndpointLookup = lookup
+}
+
+// DefaultEndpoint returns the registered default URL for a config key.
+func DefaultEndpoint(key string) (string, bool) {
+	if defaultEndpointLookup == nil {
+		return "", false
+	}
+	return defaultEndpointLookup(key)
 }
 
 // ResolveSource returns the source token to pass to GetRawData. The "config"
 // default and the legacy "hosted" alias both resolve to the provider's config
 // key; URLs and paths are returned verbatim.
 func ResolveSource(hostedKey string) string {
-	src := viper.GetString("source")
-	if src == "config" || src == "hosted" {
+	return SourceFor(viper.GetString("source"), hostedKey)
+}
+
+// SourceFor is ResolveSource for an explicit --source value.
+func SourceFor(source, hostedKey string) string {
+	if UsesConfiguredSources(source) {
 		return hostedKey
 	}
-	return src
+	return source
+}
+
+// UsesConfiguredSources reports whether a --source value selects the
+// per-provider config keys rather than a single URL or path.
+func UsesConfiguredSources(source string) bool {
+	return source == "config" || source == "hosted"
 }
 
 // IsConfiguredSource reports whether source names a provider whose endpoint or
 // local-file settings should be read from viper. Everything else that is not an
 // HTTP(S) URL is treated as a local path, including a bare filename.
 func IsConfiguredSource(source string) bool {
-	if _, ok := DefaultEndpoints[source]; ok {
+	if _, ok := DefaultEndpoint(source); ok {
 		return true
 	}
 	return viper.IsSet(source+"_endpoint") || viper.IsSet(source+"_local_file")
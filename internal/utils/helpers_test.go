@@ -27,6 +27,33 @@ func TestResolveSource(t *testing.T) {
 	assert.Equal(t, "ranges.csv", ResolveSource("aws"))
 }
 
+func TestUsesConfiguredSources(t *testing.T) {
+	assert.True(t, UsesConfiguredSources("config"))
+	assert.True(t, UsesConfiguredSources("hosted"))
+	assert.False(t, UsesConfiguredSources("https://example.test/ranges"))
+	assert.False(t, UsesConfiguredSources("ranges.txt"))
+}
+
+func TestDefaultEndpointUsesLookup(t *testing.T) {
+	t.Cleanup(func() { SetDefaultEndpointLookup(nil) })
+
+	_, ok := DefaultEndpoint("aws")
+	assert.False(t, ok, "no lookup installed")
+	assert.False(t, IsConfiguredSource("aws"))
+
+	SetDefaultEndpointLookup(func(key string) (string, bool) {
+		if key == "aws" {
+			return "https://ip-ranges.example/ranges.json", true
+		}
+		return "", false
+	})
+	endpoint, ok := DefaultEndpoint("aws")
+	assert.True(t, ok)
+	assert.Equal(t, "https://ip-ranges.example/ranges.json", endpoint)
+	assert.True(t, IsConfiguredSource("aws"))
+	assert.False(t, IsConfiguredSource("ranges.txt"))
+}
+
 func TestContainsIgnoreCase(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,276 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// HistorySourcePrefix starts a --source value that reads a stored snapshot,
+// e.g. "history:aws@2026-09-01".
+const HistorySourcePrefix = "history:"
+
+const (
+	defaultHistoryKeep = 100
+	historyTimeLayout  = "20060102T150405Z"
+	historyHashLen     = 16
+	historySuffix      = ".snap"
+)
+
+// Snapshot is one stored body of a source key. Snapshots are named by fetch
+// time and content hash; a body identical to the newest snapshot is not
+// stored again, so each snapshot holds until the next one's Time.
+type Snapshot struct {
+	Key  string
+	Time time.Time
+	Hash string
+	Size int64
+	Path string
+}
+
+// HistoryEnabled reports whether fetched bodies are recorded (history =
+// true in the config).
+func HistoryEnabled() bool {
+	return viper.GetBool("history")
+}
+
+// HistoryRetention returns how many snapshots to keep per key (history_keep,
+// default 100; 0 keeps all) and the maximum snapshot age (history_max_age,
+// a Go duration; unset keeps all).
+func HistoryRetention() (keep int, maxAge time.Duration) {
+	keep = defaultHistoryKeep
+	if viper.IsSet("history_keep") {
+		keep = viper.GetInt("history_keep")
+	}
+	if raw := viper.GetString("history_max_age"); raw != "" {
+		age, err := time.ParseDuration(raw)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: invalid history_max_age=%q, keeping snapshots of any age\n", raw)
+			return keep, 0
+		}
+		maxAge = age
+	}
+	return keep, maxAge
+}
+
+func historyDir(key string) (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("resolve history dir: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "cipr", "history", key), nil
+}
+
+// RecordSnapshot stores data as the newest snapshot of key unless it matches
+// the current newest one, then applies the retention settings. It returns
+// whether a snapshot was written.
+func RecordSnapshot(key string, data []byte, at time.Time) (bool, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])[:historyHashLen]
+
+	snapshots, err := ListSnapshots(key)
+	if err != nil {
+		return false, err
+	}
+	if n := len(snapshots); n > 0 && snapshots[n-1].Hash == hash {
+		return false, nil
+	}
+
+	dir, err := historyDir(key)
+	if err != nil {
+		return false, err
+	}
+	name := at.UTC().Format(historyTimeLayout) + "-" + hash + historySuffix
+	if err := writeFileAtomic(filepath.Join(dir, name), data); err != nil {
+		return false, fmt.Errorf("write %s snapshot: %w", key, err)
+	}
+
+	keep, maxAge := HistoryRetention()
+	if _, err := PruneSnapshots(key, keep, maxAge, at); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// ListSnapshots returns the stored snapshots of key, oldest first. A key
+// without history has none.
+func ListSnapshots(key string) ([]Snapshot, error) {
+	dir, err := historyDir(key)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read %s history: %w", key, err)
+	}
+
+	var out []Snapshot
+	for _, e := range entries {
+		stamp, hash, ok := strings.Cut(strings.TrimSuffix(e.Name(), historySuffix), "-")
+		if e.IsDir() || !strings.HasSuffix(e.Name(), historySuffix) || !ok {
+			continue
+		}
+		at, err := time.Parse(historyTimeLayout, stamp)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, Snapshot{Key: key, Time: at, Hash: hash, Size: info.Size(), Path: filepath.Join(dir, e.Name())})
+	}
+	slices.SortFunc(out, func(a, b Snapshot) int { return a.Time.Compare(b.Time) })
+	return out, nil
+}
+
+// FindSnapshot resolves at against the history of key. at is "latest", a
+// date (2006-01-02) or RFC 3339 time selecting the snapshot in effect then,
+// or a prefix of a snapshot hash.
+func FindSnapshot(key, at string) (Snapshot, error) {
+	snapshots, err := ListSnapshots(key)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if len(snapshots) == 0 {
+		if !HistoryEnabled() {
+			return Snapshot{}, fmt.Errorf("no snapshots of %s (history is disabled; enable it with history = true)", key)
+		}
+		return Snapshot{}, fmt.Errorf("no snapshots of %s yet; one is stored the next time %s is fetched", key, key)
+	}
+
+	var cutoff time.Time
+	switch {
+	case at == "" || at == "latest":
+		return snapshots[len(snapshots)-1], nil
+	case isDate(at):
+		day, _ := time.Parse(time.DateOnly, at)
+		cutoff = day.Add(24*time.Hour - time.Nanosecond)
+	default:
+		if t, err := time.Parse(time.RFC3339, at); err == nil {
+			cutoff = t
+			break
+		}
+		for _, s := range slices.Backward(snapshots) {
+			if strings.HasPrefix(s.Hash, strings.ToLower(at)) {
+				return s, nil
+			}
+		}
+		return Snapshot{}, fmt.Errorf("no %s snapshot matches %q (use latest, a date like 2006-01-02, an RFC 3339 time, or a hash prefix)", key, at)
+	}
+
+	for _, s := range slices.Backward(snapshots) {
+		if !s.Time.After(cutoff) {
+			return s, nil
+		}
+	}
+	return Snapshot{}, fmt.Errorf("no %s snapshot at or before %s (oldest is %s)", key, at, snapshots[0].Time.Format(time.RFC3339))
+}
+
+func isDate(s string) bool {
+	_, err := time.Parse(time.DateOnly, s)
+	return err == nil
+}
+
+// ReadSnapshot returns the stored body of s.
+func ReadSnapshot(s Snapshot) ([]byte, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s snapshot: %w", s.Key, err)
+	}
+	return data, nil
+}
+
+// PruneSnapshots deletes the snapshots of key beyond the newest keep and
+// those older than maxAge at now. Zero disables either limit. The newest
+// snapshot is always kept: it describes the feed as currently published.
+func PruneSnapshots(key string, keep int, maxAge time.Duration, now time.Time) ([]Snapshot, error) {
+	snapshots, err := ListSnapshots(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []Snapshot
+	for i, s := range snapshots[:max(len(snapshots)-1, 0)] {
+		tooMany := keep > 0 && len(snapshots)-i > keep
+		tooOld := maxAge > 0 && now.Sub(s.Time) > maxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(s.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, fmt.Errorf("remove %s snapshot: %w", key, err)
+		}
+		removed = append(removed, s)
+	}
+	return removed, nil
+}
+
+// ParseHistorySource splits a "history:<key>@<at>" source. at defaults to
+// "latest" when omitted.
+func ParseHistorySource(source string) (key, at string, ok bool) {
+	rest, ok := strings.CutPrefix(source, HistorySourcePrefix)
+	if !ok {
+		return "", "", false
+	}
+	key, at, _ = strings.Cut(rest, "@")
+	if at == "" {
+		at = "latest"
+	}
+	return key, at, true
+}
+
+// HistorySource builds the source selecting key's snapshot at at.
+func HistorySource(key, at string) string {
+	return HistorySourcePrefix + key + "@" + at
+}
+
+func loadFromHistory(source string) (string, error) {
+	key, at, _ := ParseHistorySource(source)
+	if key == "" {
+		return "", fmt.Errorf("invalid history source %q (want history:<key>@<date>)", source)
+	}
+	s, err := FindSnapshot(key, at)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(os.Stderr, "Using %s snapshot from %s\n", key, s.Time.Format(time.RFC3339))
+	data, err := ReadSnapshot(s)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// recordHistory stores a freshly fetched body when history is enabled.
+// Failures are logged but never fatal.
+func recordHistory(key string, body string) {
+	if !HistoryEnabled() {
+		return
+	}
+	written, err := RecordSnapshot(key, []byte(body), time.Now())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: history write failed:", err)
+		Debugf("history: write failed for %s: %v", key, err)
+		return
+	}
+	if written {
+		Debugf("history: stored snapshot of %s", key)
+	} else {
+		Debugf("history: %s unchanged since the last snapshot", key)
+	}
+}
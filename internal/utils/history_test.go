@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordSnapshot_SkipsUnchangedBody(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Cleanup(viper.Reset)
+	start := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	written, err := RecordSnapshot("aws", []byte("v1"), start)
+	require.NoError(t, err)
+	assert.True(t, written)
+	written, err = RecordSnapshot("aws", []byte("v1"), start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, written, "identical body should not be stored again")
+	written, err = RecordSnapshot("aws", []byte("v2"), start.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, written)
+
+	snapshots, err := ListSnapshots("aws")
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, start, snapshots[0].Time)
+	assert.Len(t, snapshots[0].Hash, historyHashLen)
+	assert.Equal(t, int64(2), snapshots[1].Size)
+}
+
+func TestFindSnapshot(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Cleanup(viper.Reset)
+	_, err := FindSnapshot("aws", "latest")
+	assert.ErrorContains(t, err, "no snapshots of aws (history is disabled")
+	viper.Set("history", true)
+	_, err = FindSnapshot("aws", "latest")
+	assert.EqualError(t, err, "no snapshots of aws yet; one is stored the next time aws is fetched")
+
+	day := time.Date(2026, 9, 1, 8, 0, 0, 0, time.UTC)
+	for i, body := range []string{"v1", "v2", "v3"} {
+		_, err := RecordSnapshot("aws", []byte(body), day.Add(time.Duration(i)*48*time.Hour))
+		require.NoError(t, err)
+	}
+	read := func(at string) string {
+		t.Helper()
+		s, err := FindSnapshot("aws", at)
+		require.NoError(t, err)
+		data, err := ReadSnapshot(s)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	assert.Equal(t, "v3", read("latest"))
+	assert.Equal(t, "v1", read("2026-09-01"))
+	assert.Equal(t, "v1", read("2026-09-02"), "a date selects the snapshot in effect that day")
+	assert.Equal(t, "v2", read("2026-09-03"))
+	assert.Equal(t, "v2", read("2026-09-05T07:59:59Z"))
+
+	all, err := ListSnapshots("aws")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", read(all[1].Hash[:6]))
+
+	_, err = FindSnapshot("aws", "2026-08-31")
+	assert.ErrorContains(t, err, "no aws snapshot at or before 2026-08-31")
+	_, err = FindSnapshot("aws", "yesterday")
+	assert.ErrorContains(t, err, `no aws snapshot matches "yesterday"`)
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Cleanup(viper.Reset)
+	viper.Set("history_keep", 0)
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	for i, body := range []string{"v1", "v2", "v3", "v4"} {
+		_, err := RecordSnapshot("aws", []byte(body), start.Add(time.Duration(i)*24*time.Hour))
+		require.NoError(t, err)
+	}
+
+	removed, err := PruneSnapshots("aws", 3, 0, start)
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	assert.Equal(t, start, removed[0].Time)
+
+	now := start.Add(30 * 24 * time.Hour)
+	removed, err = PruneSnapshots("aws", 0, 7*24*time.Hour, now)
+	require.NoError(t, err)
+	assert.Len(t, removed, 2)
+	left, err := ListSnapshots("aws")
+	require.NoError(t, err)
+	require.Len(t, left, 1, "the newest snapshot survives any age limit")
+	assert.Equal(t, start.Add(3*24*time.Hour), left[0].Time)
+}
+
+func TestRecordSnapshot_AppliesRetention(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Cleanup(viper.Reset)
+	viper.Set("history_keep", 2)
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	for i, body := range []string{"v1", "v2", "v3"} {
+		_, err := RecordSnapshot("aws", []byte(body), start.Add(time.Duration(i)*time.Hour))
+		require.NoError(t, err)
+	}
+	left, err := ListSnapshots("aws")
+	require.NoError(t, err)
+	assert.Len(t, left, 2)
+}
+
+func TestParseHistorySource(t *testing.T) {
+	key, at, ok := ParseHistorySource("history:aws@2026-09-01")
+	assert.True(t, ok)
+	assert.Equal(t, "aws", key)
+	assert.Equal(t, "2026-09-01", at)
+
+	key, at, ok = ParseHistorySource("history:cloudflare_ipv4")
+	assert.True(t, ok)
+	assert.Equal(t, "cloudflare_ipv4", key)
+	assert.Equal(t, "latest", at)
+
+	_, _, ok = ParseHistorySource("aws")
+	assert.False(t, ok)
+	assert.Equal(t, "history:aws@latest", HistorySource("aws", "latest"))
+}
+
+func TestGetCached_RecordsHistory(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Cleanup(viper.Reset)
+	viper.Set("history", true)
+	viper.Set("no_cache", true)
+
+	fetch := func(context.Context) (string, error) { return "body", nil }
+	_, err := GetCached(context.Background(), "aws", fetch)
+	require.NoError(t, err)
+
+	got, err := GetRawData(context.Background(), "history:aws@latest")
+	require.NoError(t, err)
+	assert.Equal(t, "body", got)
+}
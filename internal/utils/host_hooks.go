@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// HostHooks customize the requests sent to one API host and the errors
+// reported for its failed responses.
+type HostHooks struct {
+	// Authorize adds credentials to a request for the host.
+	Authorize func(req *http.Request)
+	// StatusError explains a non-2xx response, or returns nil to fall back
+	// to the generic "unexpected status" error. A non-nil error for a 429
+	// or 5xx response also stops retries: the failure is not transient.
+	StatusError func(resp *http.Response) error
+}
+
+var hostHooks = struct {
+	sync.RWMutex
+	hooks map[string]HostHooks
+}{hooks: make(map[string]HostHooks)}
+
+// RegisterHostHooks installs hooks for requests to host (a hostname
+// without port, matched case-insensitively). Providers call it from init.
+func RegisterHostHooks(host string, hooks HostHooks) {
+	hostHooks.Lock()
+	defer hostHooks.Unlock()
+	hostHooks.hooks[strings.ToLower(host)] = hooks
+}
+
+func hooksFor(req *http.Request) (HostHooks, bool) {
+	hostHooks.RLock()
+	defer hostHooks.RUnlock()
+	hooks, ok := hostHooks.hooks[strings.ToLower(req.URL.Hostname())]
+	return hooks, ok
+}
+
+// hostStatusError returns the host-specific error for resp, if any.
+func hostStatusError(resp *http.Response) error {
+	hooks, ok := hooksFor(resp.Request)
+	if !ok || hooks.StatusError == nil {
+		return nil
+	}
+	return hooks.StatusError(resp)
+}
+
+// StatusError describes the failed response resp to a request for rawURL,
+// preferring the explanation of the host's hooks.
+func StatusError(resp *http.Response, rawURL string) error {
+	if err := hostStatusError(resp); err != nil {
+		return err
+	}
+	return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, rawURL)
+}
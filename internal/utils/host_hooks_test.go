@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostHooks(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retry_delay", "1ms")
+
+	var hits atomic.Int32
+	var auth atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		auth.Store(r.Header.Get("Authorization"))
+		if r.URL.Path == "/limited" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	RegisterHostHooks("127.0.0.1", HostHooks{
+		Authorize: func(req *http.Request) { req.Header.Set("Authorization", "Bearer secret") },
+		StatusError: func(resp *http.Response) error {
+			if resp.StatusCode == http.StatusTooManyRequests {
+				return errors.New("rate limited until later")
+			}
+			return nil
+		},
+	})
+	t.Cleanup(func() {
+		hostHooks.Lock()
+		delete(hostHooks.hooks, "127.0.0.1")
+		hostHooks.Unlock()
+	})
+
+	_, err := loadFromEndpoint(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer secret", auth.Load())
+
+	other := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	_, err = loadFromEndpoint(context.Background(), other)
+	require.NoError(t, err)
+	assert.Empty(t, auth.Load(), "credentials are only sent to the registered host")
+
+	hits.Store(0)
+	_, err = loadFromEndpoint(context.Background(), srv.URL+"/limited")
+	assert.EqualError(t, err, "rate limited until later")
+	assert.Equal(t, int32(1), hits.Load(), "a failure explained by the host is not retried")
+}
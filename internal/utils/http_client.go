@@ -1,55 +1,70 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"time"
-
-	"github.com/spf13/viper"
 )
 
-const httpTimeout = 30 * time.Second
+const defaultHTTPTimeout = 30 * time.Second
+
+// DirectProxy is the proxy setting that disables proxying, including the
+// standard proxy environment variables.
+const DirectProxy = "direct"
 
-// ValidateProxyURL verifies that rawURL is an absolute HTTP(S) proxy URL.
-// An empty value is valid and means that the standard proxy environment
-// variables should be used.
+// proxySchemes are the proxy URL schemes net/http can dial. With either
+// SOCKS scheme, hostnames are resolved by the proxy, not locally.
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// ValidateProxyURL verifies that rawURL is an absolute HTTP(S) or SOCKS5
+// proxy URL, or DirectProxy. An empty value is valid and means that the
+// standard proxy environment variables should be used.
 func ValidateProxyURL(rawURL string) error {
-	if rawURL == "" {
+	if rawURL == "" || rawURL == DirectProxy {
 		return nil
 	}
 	parsed, err := url.Parse(rawURL)
 	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
-		return fmt.Errorf("invalid proxy URL %q", rawURL)
+		return fmt.Errorf("invalid proxy URL %q", SanitizeURL(rawURL))
 	}
-	if parsed.Scheme != "http" && parsed.Scheme != "https" {
-		return fmt.Errorf("unsupported proxy URL scheme %q (only http and https are supported)", parsed.Scheme)
+	if !slices.Contains(proxySchemes, parsed.Scheme) {
+		return fmt.Errorf("unsupported proxy URL scheme %q (supported: http, https, socks5, socks5h, or %q)", parsed.Scheme, DirectProxy)
 	}
 	return nil
 }
 
-// NewHTTPClient returns a client configured from the effective proxy setting.
-// A configured proxy overrides the standard environment proxy. With no
-// configured value, ProxyFromEnvironment supplies HTTP_PROXY, HTTPS_PROXY,
-// and NO_PROXY behavior.
+// NewHTTPClient returns a client configured from the effective global proxy,
+// TLS and http_timeout (default 30s) settings.
+// A configured proxy overrides the standard environment proxy, and "direct"
+// disables both. With no configured value, ProxyFromEnvironment supplies
+// HTTP_PROXY, HTTPS_PROXY, and NO_PROXY behavior.
 func NewHTTPClient() (*http.Client, error) {
+	return newHTTPClient("")
+}
+
+// newHTTPClient returns a client for source key, whose <key>_proxy, TLS and
+// timeout settings override the global ones (see resolveTLSConfig).
+func newHTTPClient(key string) (*http.Client, error) {
 	transport, ok := http.DefaultTransport.(*http.Transport)
 	if !ok {
 		return nil, fmt.Errorf("unsupported default HTTP transport %T", http.DefaultTransport)
 	}
 	cloned := transport.Clone()
 
-	configured := viper.GetString("proxy")
+	configured, setting := keyedSetting(key, "proxy")
 	if err := ValidateProxyURL(configured); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", setting, err)
 	}
-	if configured != "" {
-		proxyURL, _ := url.Parse(configured)
+	switch configured {
+	case DirectProxy:
 		cloned.Proxy = func(req *http.Request) (*url.URL, error) {
-			Debugf("proxy: configured %s for %s", SanitizeURL(configured), SanitizeURL(req.URL.String()))
-			return proxyURL, nil
+			Debugf("proxy: direct connection for %s (%s = %q)", SanitizeURL(req.URL.String()), setting, DirectProxy)
+			return nil, nil
 		}
-	} else {
+	case "":
 		cloned.Proxy = func(req *http.Request) (*url.URL, error) {
 			proxyURL, err := http.ProxyFromEnvironment(req)
 			switch {
@@ -62,7 +77,50 @@ func NewHTTPClient() (*http.Client, error) {
 			}
 			return proxyURL, err
 		}
+	default:
+		proxyURL, _ := url.Parse(configured)
+		cloned.Proxy = func(req *http.Request) (*url.URL, error) {
+			Debugf("proxy: configured %s for %s (%s)", SanitizeURL(configured), SanitizeURL(req.URL.String()), setting)
+			return proxyURL, nil
+		}
 	}
 
-	return &http.Client{Transport: cloned, Timeout: httpTimeout}, nil
+	tlsConfig, err := resolveTLSConfig(key)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		cloned.TLSClientConfig = tlsConfig
+	}
+
+	timeout, err := resolveHTTPTimeout(key)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: cloned, Timeout: timeout}
+	if key != "" {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			stripCustomHeaders(key, req, via)
+			return nil
+		}
+	}
+	return client, nil
+}
+
+// resolveHTTPTimeout reads <key>_http_timeout, falling back to the global
+// http_timeout. Zero and negative values are rejected: net/http reads them
+// as no timeout at all.
+func resolveHTTPTimeout(key string) (time.Duration, error) {
+	raw, setting := keyedSetting(key, "http_timeout")
+	if raw == "" {
+		return defaultHTTPTimeout, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q (want a positive duration such as \"30s\")", setting, raw)
+	}
+	return d, nil
 }
@@ -21,8 +21,8 @@ const defaultCacheTTL = 24 * time.Hour
 const maxResponseBytes = 64 << 20
 
 // GetRawData fetches IP-range data for the given source. The source may be
-// a config-key prefix (e.g. "aws", looked up in viper), an HTTP(S) URL, or
-// a filesystem path.
+// a config-key prefix (e.g. "aws", looked up in viper), an HTTP(S) URL, a
+// history snapshot ("history:aws@2026-09-01"), or a filesystem path.
 func GetRawData(ctx context.Context, source string) (string, error) {
 	var endpointURL, localFile, cacheKey string
 	sourceKind := ""
@@ -36,11 +36,14 @@ func GetRawData(ctx context.Context, source string) (string, error) {
 		}
 		endpointURL = source
 		sourceKind = "url"
+	case strings.HasPrefix(source, HistorySourcePrefix):
+		Debugf("source: %q resolved as history snapshot", source)
+		return loadFromHistory(source)
 	case IsConfiguredSource(source):
 		endpointURL = viper.GetString(source + "_endpoint")
 		localFile = viper.GetString(source + "_local_file")
 		if endpointURL == "" && localFile == "" {
-			endpointURL = DefaultEndpoints[source]
+			endpointURL, _ = DefaultEndpoint(source)
 		}
 		if localFile == "" {
 			cacheKey = source
@@ -78,6 +81,21 @@ func GetRawData(ctx context.Context, source string) (string, error) {
 	})
 }
 
+// Offline reports whether network access is disabled (--offline or
+// offline = true): keyed sources are then served only from the cache.
+func Offline() bool {
+	return viper.GetBool("offline")
+}
+
+// CheckOnline returns an error when offline mode forbids fetching rawURL.
+// Every outgoing request goes through it.
+func CheckOnline(rawURL string) error {
+	if !Offline() {
+		return nil
+	}
+	return fmt.Errorf("offline mode: refusing to fetch %s", SanitizeURL(rawURL))
+}
+
 // ValidateHTTPURL verifies that rawURL is an absolute HTTP(S) URL suitable for
 // endpoint fetching.
 func ValidateHTTPURL(rawURL string) error {
@@ -100,27 +118,29 @@ func loadFromFile(filePath string) (string, error) {
 }
 
 func loadFromEndpoint(ctx context.Context, url string) (string, error) {
+	if err := CheckOnline(url); err != nil {
+		return "", err
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return "", fmt.Errorf("build request for %s: %w", url, err)
 	}
 	req.Header.Set("User-Agent", UserAgent)
+	cf := conditionalFetchFrom(ctx)
+	conditional := cf.applyValidators(req, url)
 
-	client, err := NewHTTPClient()
+	response, err := DoRequest(ctx, req)
 	if err != nil {
-		return "", err
-	}
-	started := time.Now()
-	response, err := client.Do(req)
-	if err != nil {
-		Debugf("http: GET %s failed after %s", SanitizeURL(url), time.Since(started).Round(time.Millisecond))
 		return "", fmt.Errorf("fetch %s: %w", url, err)
 	}
 	defer response.Body.Close()
-	Debugf("http: GET %s returned %d after %s", SanitizeURL(url), response.StatusCode, time.Since(started).Round(time.Millisecond))
 
+	if conditional && response.StatusCode == http.StatusNotModified {
+		cf.notModified = true
+		return "", errNotModified
+	}
 	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
-		return "", fmt.Errorf("unexpected status %d from %s", response.StatusCode, url)
+		return "", StatusError(response, url)
 	}
 
 	body, err := readAllLimited(response.Body, maxResponseBytes)
@@ -128,6 +148,7 @@ func loadFromEndpoint(ctx context.Context, url string) (string, error) {
 		return "", fmt.Errorf("read body from %s: %w", url, err)
 	}
 	Debugf("http: read %d bytes from %s", len(body), SanitizeURL(url))
+	cf.recordValidators(url, response.Header)
 	return string(body), nil
 }
 
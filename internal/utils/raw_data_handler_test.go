@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -66,6 +67,8 @@ func TestLoadFromEndpoint_OK(t *testing.T) {
 }
 
 func TestLoadFromEndpoint_Non2xx(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retries", 0)
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 	}))
@@ -76,6 +79,8 @@ func TestLoadFromEndpoint_Non2xx(t *testing.T) {
 }
 
 func TestLoadFromEndpoint_Unreachable(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retries", 0)
 	_, err := loadFromEndpoint(context.Background(), "http://127.0.0.1:1")
 	assert.Error(t, err)
 }
@@ -291,3 +296,168 @@ func TestGetRawData_BadTTLFallsBack(t *testing.T) {
 	}
 	assert.Equal(t, 1, hits, "unparseable TTL should fall back to default and still cache")
 }
+
+func TestGetRawData_RevalidatesWithETag(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+
+	var full, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("payload"))
+	}))
+	defer srv.Close()
+
+	viper.Set("cachetest_endpoint", srv.URL)
+	viper.Set("cachetest_cache_ttl", "1h")
+
+	_, err := GetRawData(context.Background(), "cachetest")
+	require.NoError(t, err)
+	meta, ok := readCacheMeta("cachetest")
+	require.True(t, ok)
+	assert.Equal(t, `"v1"`, meta.ETag)
+	assert.Equal(t, srv.URL, meta.URL)
+
+	backdateCacheEntry(t, "cachetest", 2*time.Hour)
+
+	got, err := GetRawData(context.Background(), "cachetest")
+	require.NoError(t, err)
+	assert.Equal(t, "payload", got)
+	assert.Equal(t, 1, full)
+	assert.Equal(t, 1, notModified)
+
+	_, hit, err := readCache("cachetest", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, hit, "a 304 should make the cached body fresh again")
+}
+
+func TestGetRawData_RevalidatesWithLastModified(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+
+	const stamp = "Tue, 01 Sep 2026 10:00:00 GMT"
+	body := "v1"
+	var conditional []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-Modified-Since"))
+		w.Header().Set("Last-Modified", stamp)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	viper.Set("cachetest_endpoint", srv.URL)
+	viper.Set("cachetest_cache_ttl", "0s")
+
+	_, err := GetRawData(context.Background(), "cachetest")
+	require.NoError(t, err)
+	body = "v2"
+	got, err := GetRawData(context.Background(), "cachetest")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", got, "a 200 answer to a conditional request replaces the body")
+	assert.Equal(t, []string{"", stamp}, conditional)
+}
+
+func TestGetRawData_SkipsValidatorsForOtherURL(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+
+	var sawValidator bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sawValidator = sawValidator || r.Header.Get("If-None-Match") != ""
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("payload"))
+	}))
+	defer srv.Close()
+
+	viper.Set("cachetest_cache_ttl", "0s")
+	viper.Set("cachetest_endpoint", srv.URL+"/a")
+	_, err := GetRawData(context.Background(), "cachetest")
+	require.NoError(t, err)
+	viper.Set("cachetest_endpoint", srv.URL+"/b")
+	_, err = GetRawData(context.Background(), "cachetest")
+	require.NoError(t, err)
+	assert.False(t, sawValidator, "validators belong to the URL they were issued for")
+}
+
+func TestGetRawData_StaleIfError(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		global   string
+		perKey   string
+		age      time.Duration
+		wantBody bool
+	}{
+		{name: "disabled by default", age: 2 * time.Hour},
+		{name: "global window", global: "72h", age: 48 * time.Hour, wantBody: true},
+		{name: "past the window", global: "2h", age: 4 * time.Hour},
+		{name: "per-key window overrides global", global: "1h", perKey: "72h", age: 48 * time.Hour, wantBody: true},
+		{name: "per-key zero disables", global: "72h", perKey: "0s", age: 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+			viper.Reset()
+			viper.Set("cachetest_endpoint", srv.URL)
+			viper.Set("cachetest_cache_ttl", "1h")
+			viper.Set("stale_if_error", tt.global)
+			viper.Set("cachetest_stale_if_error", tt.perKey)
+			viper.Set("retries", 0)
+
+			storeCacheEntry(t, "cachetest", []byte("stale payload"), tt.age)
+
+			ctx, stale := WithStaleReport(context.Background())
+			got, err := GetRawData(ctx, "cachetest")
+			if !tt.wantBody {
+				assert.ErrorContains(t, err, "unexpected status 429")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "stale payload", got)
+			age, ok := stale.Oldest()
+			assert.True(t, ok)
+			assert.InDelta(t, tt.age.Seconds(), age.Seconds(), 5)
+		})
+	}
+}
+
+func TestGetRawData_Offline(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = w.Write([]byte("fresh"))
+	}))
+	defer srv.Close()
+	viper.Set("cachetest_endpoint", srv.URL)
+	viper.Set("offline", true)
+
+	_, err := GetRawData(context.Background(), "cachetest")
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "no cache entry for cachetest")
+	assert.ErrorContains(t, err, "cipr configure cachetest --local-file <path>")
+
+	storeCacheEntry(t, "cachetest", []byte("cached"), 30*24*time.Hour)
+
+	got, err := GetRawData(context.Background(), "cachetest")
+	require.NoError(t, err)
+	assert.Equal(t, "cached", got, "offline mode ignores the TTL")
+
+	_, err = GetRawData(context.Background(), srv.URL)
+	assert.ErrorContains(t, err, "offline mode: refusing to fetch")
+	assert.Zero(t, hits)
+}
@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultRetries       = 2
+	defaultRetryDelay    = 500 * time.Millisecond
+	defaultRetryMaxDelay = 10 * time.Second
+	defaultRetryJitter   = 0.2
+)
+
+// retryPolicy controls how a request is retried. Attempts are spaced by
+// Delay doubling up to MaxDelay, each scaled by a random factor within
+// ±Jitter; a Retry-After header replaces the computed delay.
+type retryPolicy struct {
+	Retries  int
+	Delay    time.Duration
+	MaxDelay time.Duration
+	Jitter   float64
+}
+
+type requestKeyKey struct{}
+
+// withRequestKey returns a context whose requests use the retry and
+// timeout settings of source key.
+func withRequestKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, requestKeyKey{}, key)
+}
+
+func requestKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(requestKeyKey{}).(string)
+	return key
+}
+
+// keyedSetting returns the raw value of <key>_<name>, falling back to the
+// global <name>, and the name of the setting it came from.
+func keyedSetting(key, name string) (raw, setting string) {
+	if key != "" {
+		setting = key + "_" + name
+		if raw = viper.GetString(setting); raw != "" {
+			return raw, setting
+		}
+	}
+	return viper.GetString(name), name
+}
+
+func keyedDuration(key, name string, def time.Duration) time.Duration {
+	raw, setting := keyedSetting(key, name)
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		fmt.Fprintf(os.Stderr, "Warning: invalid %s=%q, using %s\n", setting, raw, def)
+		return def
+	}
+	return d
+}
+
+// resolveRetryPolicy reads the retry settings of source key: <key>_retries,
+// <key>_retry_delay, <key>_retry_max_delay and <key>_retry_jitter, each
+// falling back to the global setting without the prefix.
+func resolveRetryPolicy(key string) retryPolicy {
+	p := retryPolicy{
+		Retries:  defaultRetries,
+		Delay:    keyedDuration(key, "retry_delay", defaultRetryDelay),
+		MaxDelay: keyedDuration(key, "retry_max_delay", defaultRetryMaxDelay),
+		Jitter:   defaultRetryJitter,
+	}
+	if raw, setting := keyedSetting(key, "retries"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			fmt.Fprintf(os.Stderr, "Warning: invalid %s=%q, using %d\n", setting, raw, defaultRetries)
+		} else {
+			p.Retries = n
+		}
+	}
+	if raw, setting := keyedSetting(key, "retry_jitter"); raw != "" {
+		j, err := strconv.ParseFloat(raw, 64)
+		if err != nil || j < 0 || j > 1 {
+			fmt.Fprintf(os.Stderr, "Warning: invalid %s=%q (want 0 to 1), using %g\n", setting, raw, defaultRetryJitter)
+		} else {
+			p.Jitter = j
+		}
+	}
+	return p
+}
+
+// backoff returns the delay before retry number attempt (1-based).
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.Delay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	if p.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 + p.Jitter*(2*rand.Float64()-1)))
+	}
+	return d
+}
+
+// isTransient reports whether a transport error may go away on retry:
+// timeouts, refused or reset connections, and connections closed before the
+// response was complete. TLS verification, proxy and URL errors fail at once.
+func isTransient(err error) bool {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return true
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+	return false
+}
+
+// retryableStatus reports whether a response status is worth retrying:
+// 429 Too Many Requests and any 5xx.
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP
+// date.
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	raw := h.Get("Retry-After")
+	if raw == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(raw); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(raw); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// DoRequest sends req, which must not have a body, with the retry and
+// timeout settings of the source being fetched. Transient transport errors
+// (see isTransient), 429 and 5xx responses are retried with exponential
+// backoff, honoring Retry-After;
+// the last response is returned as is for the caller to check its status.
+// Every attempt is logged with Debugf. Hooks registered for the request's
+// host authorize it (see RegisterHostHooks), then the source's configured
+// headers are added when req goes to the host of its endpoint (see
+// CustomHeaders).
+func DoRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
+	client, err := newHTTPClient(requestKeyFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	policy := resolveRetryPolicy(requestKeyFrom(ctx))
+	target := SanitizeURL(req.URL.String())
+	attempts := policy.Retries + 1
+	if hooks, ok := hooksFor(req); ok && hooks.Authorize != nil {
+		req = req.Clone(ctx)
+		hooks.Authorize(req)
+	}
+	if key := requestKeyFrom(ctx); key != "" && strings.EqualFold(req.URL.Host, endpointHost(key)) {
+		headers, err := CustomHeaders(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(headers) > 0 {
+			req = req.Clone(ctx)
+			for name, values := range headers {
+				req.Header[name] = values
+			}
+			Debugf("http: sending %s_headers to %s: %s", key, target, RedactHeaders(CustomHeaderNames(key)))
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		started := time.Now()
+		resp, err := client.Do(req.Clone(ctx))
+		elapsed := time.Since(started).Round(time.Millisecond)
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			Debugf("http: %s %s attempt %d/%d failed after %s: %v", req.Method, target, attempt, attempts, elapsed, err)
+			if attempt == attempts || ctx.Err() != nil || !isTransient(err) {
+				return nil, err
+			}
+			delay = policy.backoff(attempt)
+		case retryableStatus(resp.StatusCode) && attempt < attempts && hostStatusError(resp) == nil:
+			Debugf("http: %s %s attempt %d/%d returned %d after %s", req.Method, target, attempt, attempts, resp.StatusCode, elapsed)
+			delay = policy.backoff(attempt)
+			if wait, ok := retryAfter(resp.Header, time.Now()); ok {
+				if wait > policy.MaxDelay {
+					Debugf("http: Retry-After %s from %s exceeds retry_max_delay %s; giving up", wait, target, policy.MaxDelay)
+					return resp, nil
+				}
+				delay = wait
+			}
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		default:
+			Debugf("http: %s %s attempt %d/%d returned %d after %s", req.Method, target, attempt, attempts, resp.StatusCode, elapsed)
+			return resp, nil
+		}
+
+		Debugf("http: retrying %s in %s", target, delay.Round(time.Millisecond))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, context.Cause(ctx)
+		case <-timer.C:
+		}
+	}
+}
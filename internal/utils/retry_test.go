@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveRetryPolicy(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+
+	p := resolveRetryPolicy("aws")
+	assert.Equal(t, retryPolicy{Retries: 2, Delay: 500 * time.Millisecond, MaxDelay: 10 * time.Second, Jitter: 0.2}, p)
+
+	viper.Set("retries", "5")
+	viper.Set("aws_retries", "0")
+	viper.Set("aws_retry_delay", "2s")
+	viper.Set("gcp_retry_jitter", "1.5")
+	assert.Equal(t, retryPolicy{Retries: 0, Delay: 2 * time.Second, MaxDelay: 10 * time.Second, Jitter: 0.2}, resolveRetryPolicy("aws"))
+	gcp := resolveRetryPolicy("gcp")
+	assert.Equal(t, 5, gcp.Retries)
+	assert.Equal(t, 0.2, gcp.Jitter, "an out-of-range jitter falls back to the default")
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := retryPolicy{Delay: time.Second, MaxDelay: 5 * time.Second}
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 2*time.Second, p.backoff(2))
+	assert.Equal(t, 4*time.Second, p.backoff(3))
+	assert.Equal(t, 5*time.Second, p.backoff(4))
+	assert.Equal(t, 5*time.Second, p.backoff(40))
+
+	p.Jitter = 0.5
+	for range 100 {
+		d := p.backoff(2)
+		assert.GreaterOrEqual(t, d, time.Second)
+		assert.LessOrEqual(t, d, 3*time.Second)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 9, 1, 10, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{header: "", ok: false},
+		{header: "3", want: 3 * time.Second, ok: true},
+		{header: "Tue, 01 Sep 2026 10:00:30 GMT", want: 30 * time.Second, ok: true},
+		{header: "Tue, 01 Sep 2026 09:00:00 GMT", want: 0, ok: true},
+		{header: "soon", ok: false},
+	} {
+		h := http.Header{}
+		if tt.header != "" {
+			h.Set("Retry-After", tt.header)
+		}
+		got, ok := retryAfter(h, now)
+		assert.Equal(t, tt.ok, ok, tt.header)
+		assert.Equal(t, tt.want, got, tt.header)
+	}
+}
+
+func TestLoadFromEndpoint_Retries(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retry_delay", "1ms")
+
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  string
+		wantHits int32
+	}{
+		{name: "recovers from 5xx", statuses: []int{503, 502, 200}, wantHits: 3},
+		{name: "recovers from 429", statuses: []int{429, 200}, wantHits: 2},
+		{name: "gives up after retries", statuses: []int{500, 500, 500, 200}, wantErr: "unexpected status 500", wantHits: 3},
+		{name: "does not retry 404", statuses: []int{404, 200}, wantErr: "unexpected status 404", wantHits: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := hits.Add(1)
+				w.WriteHeader(tt.statuses[n-1])
+				_, _ = w.Write([]byte("payload"))
+			}))
+			defer srv.Close()
+
+			body, err := loadFromEndpoint(context.Background(), srv.URL)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "payload", body)
+			}
+			assert.Equal(t, tt.wantHits, hits.Load())
+		})
+	}
+}
+
+func TestLoadFromEndpoint_RetryAfter(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retry_delay", "1ms")
+	viper.Set("retry_max_delay", "2s")
+
+	var hits atomic.Int32
+	var first time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.GreaterOrEqual(t, time.Since(first), time.Second, "Retry-After must be honored")
+		_, _ = w.Write([]byte("payload"))
+	}))
+	defer srv.Close()
+
+	body, err := loadFromEndpoint(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "payload", body)
+	assert.Equal(t, int32(2), hits.Load())
+
+	viper.Set("retry_max_delay", "500ms")
+	hits.Store(0)
+	_, err = loadFromEndpoint(context.Background(), srv.URL)
+	assert.ErrorContains(t, err, "unexpected status 503", "a Retry-After beyond retry_max_delay is not waited for")
+	assert.Equal(t, int32(1), hits.Load())
+}
+
+func TestLoadFromEndpoint_RetryUsesSourceKey(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retry_delay", "1ms")
+	viper.Set("cachetest_retries", "0")
+
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	viper.Set("cachetest_endpoint", srv.URL)
+
+	_, err := GetRawData(context.Background(), "cachetest")
+	assert.ErrorContains(t, err, "unexpected status 502")
+	assert.Equal(t, int32(1), hits.Load(), "cachetest_retries = 0 overrides the default")
+}
+
+func TestDoRequestLogsAttempts(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retry_delay", "1ms")
+	var output bytes.Buffer
+	t.Cleanup(setDebugWriter(&output))
+	SetDebug(true)
+	t.Cleanup(func() { SetDebug(false) })
+
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	_, err := loadFromEndpoint(context.Background(), srv.URL+"?token=secret")
+	require.NoError(t, err)
+	log := output.String()
+	assert.Contains(t, log, "attempt 1/3 returned 500")
+	assert.Contains(t, log, "attempt 2/3 returned 200")
+	assert.Equal(t, 1, strings.Count(log, "http: retrying"))
+	assert.NotContains(t, log, "secret")
+}
+
+func TestDoRequestStopsOnCancel(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retry_delay", "1h")
+	viper.Set("retry_max_delay", "1h")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err := loadFromEndpoint(ctx, srv.URL)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), 10*time.Second)
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, isTransient(&url.Error{Op: "Get", URL: "https://example.com", Err: syscall.ECONNREFUSED}))
+	assert.True(t, isTransient(fmt.Errorf("read: %w", syscall.ECONNRESET)))
+	assert.True(t, isTransient(io.ErrUnexpectedEOF))
+	assert.True(t, isTransient(&net.OpError{Op: "dial", Err: timeoutError{}}))
+	assert.False(t, isTransient(&url.Error{Op: "Get", URL: "https://example.com", Err: x509.UnknownAuthorityError{}}))
+	assert.False(t, isTransient(errors.New("proxyconnect tcp: 407 Proxy Authentication Required")))
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestDoRequestDoesNotRetryTLSErrors(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retries", 2)
+	viper.Set("retry_delay", "1ms")
+
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	_, err := loadFromEndpoint(context.Background(), srv.URL)
+	assert.ErrorContains(t, err, "certificate")
+	assert.Equal(t, int32(1), conns.Load(), "a TLS verification error fails after one attempt")
+}
+
+func TestDoRequestRetriesDroppedConnections(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retry_delay", "1ms")
+
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+			return
+		}
+		_, _ = w.Write([]byte("payload"))
+	}))
+	defer srv.Close()
+
+	body, err := loadFromEndpoint(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "payload", body)
+	assert.Equal(t, int32(2), hits.Load())
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// insecureWarned records the source keys already warned about disabled
+// certificate verification, so each is reported once per run.
+var insecureWarned sync.Map
+
+// resolveTLSConfig builds the TLS settings of source key from tls_ca_file
+// (a PEM bundle trusted in addition to the system roots), tls_client_cert
+// and tls_client_key (a PEM pair for mutual TLS), tls_min_version ("1.2",
+// "1.3", ...) and tls_insecure_skip_verify. Each may be overridden per key
+// as <key>_tls_ca_file and so on. It returns nil when none is set, leaving
+// Go's defaults in place. The settings also apply to the TLS connection to
+// an https:// proxy.
+func resolveTLSConfig(key string) (*tls.Config, error) {
+	caFile, caSetting := keyedSetting(key, "tls_ca_file")
+	certFile, certSetting := keyedSetting(key, "tls_client_cert")
+	keyFile, keySetting := keyedSetting(key, "tls_client_key")
+	minVersion, versionSetting := keyedSetting(key, "tls_min_version")
+	insecureRaw, insecureSetting := keyedSetting(key, "tls_insecure_skip_verify")
+	if caFile == "" && certFile == "" && keyFile == "" && minVersion == "" && insecureRaw == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", caSetting, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			Debugf("tls: system roots unavailable (%v); trusting only %s", err, caFile)
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s %s contains no PEM certificates", caSetting, caFile)
+		}
+		config.RootCAs = pool
+		Debugf("tls: trusting certificates from %s", caFile)
+	}
+
+	switch {
+	case certFile != "" && keyFile != "":
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load %s and %s: %w", certSetting, keySetting, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+		Debugf("tls: presenting client certificate %s", certFile)
+	case certFile != "":
+		return nil, fmt.Errorf("%s is set without %s", certSetting, keySetting)
+	case keyFile != "":
+		return nil, fmt.Errorf("%s is set without %s", keySetting, certSetting)
+	}
+
+	if minVersion != "" {
+		v, ok := tlsVersions[minVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid %s %q (allowed: 1.0, 1.1, 1.2, 1.3)", versionSetting, minVersion)
+		}
+		config.MinVersion = v
+	}
+
+	if insecureRaw != "" {
+		insecure, err := strconv.ParseBool(insecureRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q (want true or false)", insecureSetting, insecureRaw)
+		}
+		if insecure {
+			config.InsecureSkipVerify = true
+			if _, warned := insecureWarned.LoadOrStore(insecureSetting, true); !warned {
+				fmt.Fprintf(os.Stderr, "Warning: %s is set: TLS certificates are NOT verified and connections can be intercepted. Use tls_ca_file to trust a private CA instead.\n", insecureSetting)
+			}
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertPEM stores cert as a PEM file and returns its path.
+func writeCertPEM(t *testing.T, cert *x509.Certificate) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600))
+	return path
+}
+
+// newClientCert creates a self-signed client certificate and writes it and
+// its key as PEM files.
+func newClientCert(t *testing.T) (cert *x509.Certificate, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cipr-test-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client-key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return cert, certFile, keyFile
+}
+
+func newTLSTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("payload"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestResolveTLSConfig(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+
+	config, err := resolveTLSConfig("aws")
+	require.NoError(t, err)
+	assert.Nil(t, config, "no settings keep Go's defaults")
+
+	viper.Set("tls_min_version", "1.3")
+	viper.Set("gcp_tls_min_version", "1.2")
+	config, err = resolveTLSConfig("aws")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+	config, err = resolveTLSConfig("gcp")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion, "per-key settings override global ones")
+}
+
+func TestResolveTLSConfig_Errors(t *testing.T) {
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+	_, certFile, _ := newClientCert(t)
+
+	for _, tt := range []struct {
+		settings map[string]string
+		want     string
+	}{
+		{settings: map[string]string{"tls_ca_file": "/nonexistent/ca.pem"}, want: "read tls_ca_file:"},
+		{settings: map[string]string{"aws_tls_ca_file": notPEM}, want: "aws_tls_ca_file " + notPEM + " contains no PEM certificates"},
+		{settings: map[string]string{"tls_client_cert": certFile}, want: "tls_client_cert is set without tls_client_key"},
+		{settings: map[string]string{"tls_client_cert": certFile, "tls_client_key": certFile}, want: "load tls_client_cert and tls_client_key:"},
+		{settings: map[string]string{"tls_min_version": "1.4"}, want: `invalid tls_min_version "1.4"`},
+		{settings: map[string]string{"aws_tls_insecure_skip_verify": "maybe"}, want: `invalid aws_tls_insecure_skip_verify "maybe"`},
+	} {
+		viper.Reset()
+		for k, v := range tt.settings {
+			viper.Set(k, v)
+		}
+		_, err := resolveTLSConfig("aws")
+		assert.ErrorContains(t, err, tt.want)
+	}
+	viper.Reset()
+}
+
+func TestTLSCAFile(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retries", 0)
+	srv := newTLSTestServer(t)
+
+	_, err := loadFromEndpoint(context.Background(), srv.URL)
+	assert.ErrorContains(t, err, "certificate", "the test CA is not trusted by default")
+
+	viper.Set("cachetest_endpoint", srv.URL)
+	viper.Set("cachetest_tls_ca_file", writeCertPEM(t, srv.Certificate()))
+	body, err := GetRawData(context.Background(), "cachetest")
+	require.NoError(t, err)
+	assert.Equal(t, "payload", body)
+
+	_, err = GetRawData(context.Background(), srv.URL)
+	assert.Error(t, err, "a per-key CA does not apply to other sources")
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retries", 0)
+	clientCert, certFile, keyFile := newClientCert(t)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+	viper.Set("tls_ca_file", writeCertPEM(t, srv.Certificate()))
+
+	_, err := loadFromEndpoint(context.Background(), srv.URL)
+	assert.Error(t, err, "the server requires a client certificate")
+
+	viper.Set("tls_client_cert", certFile)
+	viper.Set("tls_client_key", keyFile)
+	body, err := loadFromEndpoint(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "cipr-test-client", body)
+}
+
+func TestTLSMinVersion(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retries", 0)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	defer srv.Close()
+	viper.Set("tls_ca_file", writeCertPEM(t, srv.Certificate()))
+
+	_, err := loadFromEndpoint(context.Background(), srv.URL)
+	require.NoError(t, err)
+
+	viper.Set("tls_min_version", "1.3")
+	_, err = loadFromEndpoint(context.Background(), srv.URL)
+	assert.ErrorContains(t, err, "protocol version")
+}
+
+func TestTLSInsecureSkipVerify(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retries", 0)
+	srv := newTLSTestServer(t)
+
+	viper.Set("tls_insecure_skip_verify", true)
+	body, err := loadFromEndpoint(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "payload", body)
+	_, warned := insecureWarned.Load("tls_insecure_skip_verify")
+	assert.True(t, warned)
+
+	viper.Set("aws_tls_insecure_skip_verify", false)
+	config, err := resolveTLSConfig("aws")
+	require.NoError(t, err)
+	assert.False(t, config.InsecureSkipVerify, "a per-key false re-enables verification")
+}
+
+func TestTLSSettingsApplyToHTTPSProxy(t *testing.T) {
+	t.Cleanup(func() { viper.Reset() })
+	viper.Set("retries", 0)
+
+	var requested string
+	proxy := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		_, _ = w.Write([]byte("proxied"))
+	}))
+	defer proxy.Close()
+	viper.Set("proxy", proxy.URL)
+
+	_, err := loadFromEndpoint(context.Background(), "http://upstream.invalid/ranges")
+	assert.Error(t, err, "the proxy's certificate is not trusted by default")
+
+	viper.Set("tls_ca_file", writeCertPEM(t, proxy.Certificate()))
+	body, err := loadFromEndpoint(context.Background(), "http://upstream.invalid/ranges")
+	require.NoError(t, err)
+	assert.Equal(t, "proxied", body)
+	assert.Equal(t, "http://upstream.invalid/ranges", requested)
+}